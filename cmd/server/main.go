@@ -7,18 +7,22 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/savo4ka/ares-api/internal/blobstore"
 	"github.com/savo4ka/ares-api/internal/config"
 	"github.com/savo4ka/ares-api/internal/crypto"
 	"github.com/savo4ka/ares-api/internal/database"
 	"github.com/savo4ka/ares-api/internal/handlers"
 	"github.com/savo4ka/ares-api/internal/metrics"
+	"github.com/savo4ka/ares-api/internal/notify"
 	"github.com/savo4ka/ares-api/internal/repository"
+	"github.com/savo4ka/ares-api/internal/webhook"
 )
 
 func main() {
@@ -27,36 +31,76 @@ func main() {
 		log.Println("No .env file found, using environment variables")
 	}
 
+	// Подкоманды: без аргументов (или "serve") запускается HTTP сервер
+	command := "serve"
+	if len(os.Args) > 1 {
+		command = os.Args[1]
+	}
+
+	switch command {
+	case "serve":
+		runServer()
+	case "rekey":
+		runRekey(os.Args[2:])
+	case "migrate":
+		if err := runMigrate(os.Args[2:]); err != nil {
+			log.Fatalf("Migrate: %v", err)
+		}
+	default:
+		log.Fatalf("Unknown command %q (available: serve, rekey, migrate)", command)
+	}
+}
+
+// runServer запускает HTTP сервер
+func runServer() {
 	// Загружаем конфигурацию
 	cfg, err := config.Load()
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
-	// Подключаемся к базе данных
-	db, err := database.New(cfg.DatabaseURL)
+	// Открываем хранилище секретов
+	secretStore, db, closeStore, err := openSecretStore(cfg)
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		log.Fatalf("Failed to open secret store: %v", err)
 	}
-	defer db.Close()
+	defer closeStore()
 
-	log.Println("Successfully connected to database")
+	// Применяем миграции схемы PostgreSQL
+	if cfg.AutoMigrate && db != nil {
+		if err := autoMigrate(db); err != nil {
+			log.Fatalf("Failed to migrate database: %v", err)
+		}
+	}
 
 	// Инициализируем сервис шифрования
-	encryptionService, err := crypto.NewEncryptionService(cfg.EncryptionKey)
+	encryptionService, err := newEncryptionService(cfg)
 	if err != nil {
 		log.Fatalf("Failed to create encryption service: %v", err)
 	}
 
+	log.Printf("Encryption initialized: key provider %q, active key %q", cfg.KeyProvider, encryptionService.ActiveKeyID())
+
 	// Инициализируем метрики
 	appMetrics := metrics.New()
-
-	// Создаём репозиторий
-	secretRepo := repository.NewSecretRepository(db)
+	if db != nil {
+		appMetrics.RegisterDBPool(db.Pool)
+	}
 
 	// Создаём handlers
 	baseURL := fmt.Sprintf("http://localhost:%s", cfg.ServerPort)
-	secretHandler := handlers.NewSecretHandler(secretRepo, encryptionService, baseURL, appMetrics)
+	notifier, err := newNotifier(cfg)
+	if err != nil {
+		log.Fatalf("Failed to create email notifier: %v", err)
+	}
+	blobs, err := newBlobStore(cfg, db)
+	if err != nil {
+		log.Fatalf("Failed to create blob store: %v", err)
+	}
+	secretHandler, err := handlers.NewSecretHandler(secretStore, blobs, encryptionService, baseURL, cfg, appMetrics, notifier)
+	if err != nil {
+		log.Fatalf("Failed to create secret handler: %v", err)
+	}
 
 	// Настраиваем роутер
 	router := mux.NewRouter()
@@ -69,7 +113,13 @@ func main() {
 	// API routes
 	api := router.PathPrefix("/api").Subrouter()
 	api.HandleFunc("/secrets", secretHandler.CreateSecret).Methods("POST", "OPTIONS")
+	api.HandleFunc("/secrets/{id}", secretHandler.HeadSecret).Methods("HEAD")
 	api.HandleFunc("/secrets/{id}", secretHandler.GetSecret).Methods("GET", "OPTIONS")
+	api.HandleFunc("/secrets/{id}", secretHandler.UpdateSecret).Methods("PATCH")
+	api.HandleFunc("/secrets/{id}", secretHandler.RevokeSecret).Methods("DELETE")
+	api.HandleFunc("/secrets/{id}/status", secretHandler.GetSecretStatus).Methods("GET", "OPTIONS")
+	api.HandleFunc("/secrets/{id}/receipt", secretHandler.GetSecretReceipt).Methods("GET", "OPTIONS")
+	api.HandleFunc("/secrets/{id}/reveal", secretHandler.RevealSecret).Methods("POST", "OPTIONS")
 
 	// Health check
 	router.HandleFunc("/health", secretHandler.HealthCheck).Methods("GET")
@@ -77,8 +127,36 @@ func main() {
 	// Metrics endpoint
 	router.Handle("/metrics", promhttp.Handler()).Methods("GET")
 
+	// Фоновые задачи останавливаются при завершении сервера; хранилище
+	// закрывается только после того, как они закончат текущую работу
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	var background sync.WaitGroup
+
+	// Очередь уведомлений есть только в PostgreSQL
+	var webhooks *repository.WebhookRepository
+	if db != nil {
+		webhooks = repository.NewWebhookRepository(db, cfg.DBQueryTimeout)
+	}
+
 	// Запускаем фоновую задачу по очистке истёкших секретов
-	go cleanupExpiredSecrets(secretRepo, appMetrics)
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		cleanupExpiredSecrets(backgroundCtx, secretStore, blobs, webhooks, cfg.WebhookFailedTTL, appMetrics)
+	}()
+
+	// Запускаем отправку уведомлений о событиях секретов
+	if cfg.WebhooksEnabled() {
+		dispatcher := webhook.NewDispatcher(webhooks, cfg.WebhookSigningSecret,
+			cfg.WebhookMaxAttempts, cfg.WebhookTimeout, cfg.WebhookAllowPrivate, appMetrics)
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			dispatcher.Run(backgroundCtx)
+		}()
+		log.Println("Webhook dispatcher started")
+	}
 
 	// Настраиваем HTTP сервер
 	srv := &http.Server{
@@ -106,6 +184,7 @@ func main() {
 	// Ждём сигнала о завершении
 	<-done
 	log.Println("Server is shutting down...")
+	stopBackground()
 
 	// Graceful shutdown с таймаутом
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
@@ -115,16 +194,149 @@ func main() {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
 
+	background.Wait()
+
 	log.Println("Server exited properly")
 }
 
-// cleanupExpiredSecrets периодически удаляет истёкшие секреты из БД
-func cleanupExpiredSecrets(repo *repository.SecretRepository, m *metrics.Metrics) {
+// newEncryptionService создаёт сервис шифрования с провайдером мастер-ключей из конфигурации.
+// При KEY_PROVIDER=vault локальные ключи (если заданы) используются только для расшифровки.
+func newEncryptionService(cfg *config.Config) (*crypto.EncryptionService, error) {
+	var local *crypto.LocalKeyProvider
+	var err error
+
+	switch {
+	case cfg.EncryptionKeyFile != "":
+		local, err = crypto.NewFileKeyProvider(cfg.EncryptionKeyFile, cfg.ActiveKeyID)
+	case len(cfg.EncryptionKeys) > 0:
+		local, err = crypto.NewLocalKeyProvider(cfg.EncryptionKeys, cfg.ActiveKeyID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.KeyProvider != config.KeyProviderVault {
+		return crypto.NewEncryptionService(local), nil
+	}
+
+	vault, err := crypto.NewVaultTransitProvider(crypto.VaultTransitConfig{
+		Address:   cfg.VaultAddress,
+		Token:     cfg.VaultToken,
+		Namespace: cfg.VaultNamespace,
+		Mount:     cfg.VaultTransitMount,
+		KeyName:   cfg.VaultTransitKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if local == nil {
+		return crypto.NewEncryptionService(vault), nil
+	}
+	return crypto.NewEncryptionService(vault, local), nil
+}
+
+// newNotifier создаёт уведомитель по электронной почте. Без SMTP_HOST письма не отправляются.
+func newNotifier(cfg *config.Config) (notify.Notifier, error) {
+	if cfg.SMTPHost == "" {
+		return notify.NoopNotifier{}, nil
+	}
+
+	notifier, err := notify.NewSMTPNotifier(notify.SMTPConfig{
+		Host:     cfg.SMTPHost,
+		Port:     cfg.SMTPPort,
+		Username: cfg.SMTPUsername,
+		Password: cfg.SMTPPassword,
+		From:     cfg.SMTPFrom,
+		Timeout:  cfg.SMTPTimeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Email notifications enabled via %s:%d", cfg.SMTPHost, cfg.SMTPPort)
+	return notifier, nil
+}
+
+// openSecretStore открывает хранилище секретов по STORAGE_BACKEND. Для postgres
+// возвращает также подключение к БД (очередь уведомлений, блобы), для остальных - nil.
+func openSecretStore(cfg *config.Config) (repository.SecretStore, *database.DB, func(), error) {
+	switch cfg.StorageBackend {
+	case config.StorageBackendSQLite:
+		store, err := repository.NewSQLiteStore(cfg.SQLitePath, cfg.DBQueryTimeout)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		log.Printf("Secret store: sqlite (%s)", cfg.SQLitePath)
+		return store, nil, func() { store.Close() }, nil
+	case config.StorageBackendRedis:
+		store, err := repository.NewRedisStore(cfg.RedisURL, cfg.RedisPrefix, cfg.DBQueryTimeout)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		log.Println("Secret store: redis")
+		return store, nil, func() { store.Close() }, nil
+	case config.StorageBackendMemory:
+		log.Println("Secret store: memory (secrets are lost on restart)")
+		return repository.NewMemoryStore(), nil, func() {}, nil
+	default:
+		db, err := openDatabase(cfg)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to connect to database: %w", err)
+		}
+		log.Println("Successfully connected to database")
+		return repository.NewSecretRepository(db, cfg.DBQueryTimeout), db, func() { db.Close() }, nil
+	}
+}
+
+// openDatabase подключается к PostgreSQL с параметрами пула из конфигурации
+func openDatabase(cfg *config.Config) (*database.DB, error) {
+	return database.New(cfg.DatabaseURL, database.PoolConfig{
+		MaxConns:          int32(cfg.DBMaxConns),
+		MinConns:          int32(cfg.DBMinConns),
+		MaxConnLifetime:   cfg.DBMaxConnLifetime,
+		HealthCheckPeriod: cfg.DBHealthCheckPeriod,
+	})
+}
+
+// newBlobStore создаёт хранилище содержимого файлов по BLOB_STORE
+func newBlobStore(cfg *config.Config, db *database.DB) (blobstore.BlobStore, error) {
+	switch cfg.BlobStore {
+	case config.BlobStoreFilesystem:
+		log.Printf("Blob store: filesystem (%s)", cfg.BlobDir)
+		return blobstore.NewFilesystemStore(cfg.BlobDir)
+	case config.BlobStoreS3:
+		log.Printf("Blob store: s3 (%s, bucket %s)", cfg.S3Endpoint, cfg.S3Bucket)
+		return blobstore.NewS3Store(blobstore.S3Config{
+			Endpoint:        cfg.S3Endpoint,
+			Region:          cfg.S3Region,
+			Bucket:          cfg.S3Bucket,
+			Prefix:          cfg.S3Prefix,
+			AccessKeyID:     cfg.S3AccessKeyID,
+			SecretAccessKey: cfg.S3SecretAccessKey,
+			PathStyle:       cfg.S3PathStyle,
+		})
+	default:
+		return blobstore.NewPostgresStore(db), nil
+	}
+}
+
+// cleanupExpiredSecrets периодически удаляет истёкшие секреты из хранилища,
+// содержимое их файлов из хранилища блобов и недоставленные уведомления старше
+// failedWebhookTTL (webhooks равен nil, если очереди уведомлений нет), пока не будет отменён ctx
+func cleanupExpiredSecrets(ctx context.Context, repo repository.SecretStore, blobs blobstore.BlobStore,
+	webhooks *repository.WebhookRepository, failedWebhookTTL time.Duration, m *metrics.Metrics) {
 	ticker := time.NewTicker(1 * time.Hour)
 	defer ticker.Stop()
 
-	for range ticker.C {
-		deleted, err := repo.CleanupExpired()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		deleted, blobKeys, err := repo.CleanupExpired(ctx)
 		if err != nil {
 			log.Printf("Failed to cleanup expired secrets: %v", err)
 			continue
@@ -136,9 +348,85 @@ func cleanupExpiredSecrets(repo *repository.SecretRepository, m *metrics.Metrics
 			log.Printf("Cleaned up %d expired secrets", deleted)
 		}
 
+		for _, key := range blobKeys {
+			if err := blobs.Delete(ctx, key); err != nil {
+				log.Printf("Failed to delete blob %s: %v", key, err)
+			}
+		}
+
+		// Блобы без секрета остаются после сбоев и ошибок удаления
+		orphans, err := deleteOrphanedBlobs(ctx, repo, blobs)
+		if err != nil {
+			log.Printf("Failed to cleanup orphaned blobs: %v", err)
+		}
+		if orphans > 0 {
+			m.BlobsOrphanedDeletedTotal.Add(float64(orphans))
+			log.Printf("Deleted %d orphaned blobs", orphans)
+		}
+
+		// Доставленные уведомления удаляются сразу, недоставленные - по истечении срока хранения
+		if webhooks != nil && failedWebhookTTL > 0 {
+			failed, err := webhooks.DeleteFailedBefore(ctx, time.Now().Add(-failedWebhookTTL))
+			if err != nil {
+				log.Printf("Failed to cleanup failed webhooks: %v", err)
+			}
+			if failed > 0 {
+				log.Printf("Deleted %d failed webhooks", failed)
+			}
+		}
+
 		// Обновляем метрику активных секретов
-		if count, err := repo.GetActiveSecretsCount(); err == nil {
+		if count, err := repo.GetActiveSecretsCount(ctx); err == nil {
 			m.UpdateActiveSecretsGauge(count)
 		}
 	}
 }
+
+const (
+	// orphanBlobGracePeriod - блобы моложе этого срока не проверяются:
+	// их секрет может ещё создаваться
+	orphanBlobGracePeriod = time.Hour
+
+	// orphanBlobBatchSize - сколько ключей блобов проверять в БД за один запрос
+	orphanBlobBatchSize = 500
+)
+
+// deleteOrphanedBlobs удаляет блобы, на которые не ссылается ни один секрет,
+// и возвращает их количество
+func deleteOrphanedBlobs(ctx context.Context, repo repository.SecretStore, blobs blobstore.BlobStore) (int, error) {
+	deleted := 0
+	var batch []string
+
+	flush := func() error {
+		referenced, err := repo.ReferencedBlobKeys(ctx, batch)
+		if err != nil {
+			return err
+		}
+
+		for _, key := range batch {
+			if referenced[key] {
+				continue
+			}
+			if err := blobs.Delete(ctx, key); err != nil {
+				return err
+			}
+			deleted++
+		}
+
+		batch = batch[:0]
+		return nil
+	}
+
+	err := blobs.List(ctx, time.Now().Add(-orphanBlobGracePeriod), func(key string) error {
+		batch = append(batch, key)
+		if len(batch) < orphanBlobBatchSize {
+			return nil
+		}
+		return flush()
+	})
+	if err == nil && len(batch) > 0 {
+		err = flush()
+	}
+
+	return deleted, err
+}
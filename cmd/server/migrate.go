@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/savo4ka/ares-api/internal/config"
+	"github.com/savo4ka/ares-api/internal/database"
+	"github.com/savo4ka/ares-api/internal/migrate"
+	"github.com/savo4ka/ares-api/migrations"
+)
+
+// runMigrate управляет схемой БД встроенными миграциями (ares-api migrate up|down [N]|version).
+// Ошибки возвращаются вызывающему, чтобы отложенное закрытие БД выполнилось до выхода.
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: ares-api migrate up|down [N]|version")
+	}
+
+	// Команде нужны только настройки БД, ключи шифрования не загружаются
+	cfg, err := config.LoadDatabase()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if cfg.StorageBackend != config.StorageBackendPostgres {
+		return fmt.Errorf("migrations apply only to STORAGE_BACKEND %q", config.StorageBackendPostgres)
+	}
+
+	// Подключаемся к базе данных
+	db, err := openDatabase(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	migrator, err := migrate.New(db, migrations.FS)
+	if err != nil {
+		return fmt.Errorf("failed to load migrations: %w", err)
+	}
+
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return fmt.Errorf("migration failed: %w", err)
+		}
+		version, _, err := migrator.Version(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get schema version: %w", err)
+		}
+		log.Printf("Applied %d migration(s), schema is at version %d", applied, version)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps <= 0 {
+				return fmt.Errorf("number of migrations to revert must be a positive integer")
+			}
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		if err != nil {
+			return fmt.Errorf("migration failed: %w", err)
+		}
+		log.Printf("Reverted %d migration(s)", reverted)
+	case "version":
+		version, dirty, err := migrator.Version(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get schema version: %w", err)
+		}
+		log.Printf("Schema version: %d (dirty: %t, latest available: %d)", version, dirty, migrator.Latest())
+	default:
+		return fmt.Errorf("unknown migrate command %q (available: up, down, version)", args[0])
+	}
+
+	return nil
+}
+
+// autoMigrate применяет миграции при запуске сервера (AUTO_MIGRATE=true)
+func autoMigrate(db *database.DB) error {
+	migrator, err := migrate.New(db, migrations.FS)
+	if err != nil {
+		return err
+	}
+
+	applied, err := migrator.Up(context.Background())
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Database schema is up to date (%d migration(s) applied)", applied)
+	return nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/savo4ka/ares-api/internal/config"
+	"github.com/savo4ka/ares-api/internal/metrics"
+	"github.com/savo4ka/ares-api/internal/rekey"
+)
+
+// runRekey перешифровывает секреты активным ключом (ares-api rekey)
+func runRekey(args []string) {
+	flags := flag.NewFlagSet("rekey", flag.ExitOnError)
+	batchSize := flags.Int("batch-size", 100, "number of secrets re-encrypted per transaction")
+	metricsAddr := flags.String("metrics-addr", "", "address to expose progress metrics on while running (e.g. :9091)")
+	flags.Parse(args)
+
+	if *batchSize <= 0 {
+		log.Fatalf("batch-size must be positive")
+	}
+
+	// Загружаем конфигурацию
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	// В памяти процесса перешифровывать нечего: секреты не переживают перезапуск
+	if cfg.StorageBackend == config.StorageBackendMemory {
+		log.Fatalf("Rekey is not supported with STORAGE_BACKEND %q", config.StorageBackendMemory)
+	}
+
+	secretStore, _, closeStore, err := openSecretStore(cfg)
+	if err != nil {
+		log.Fatalf("Failed to open secret store: %v", err)
+	}
+	defer closeStore()
+
+	encryptionService, err := newEncryptionService(cfg)
+	if err != nil {
+		log.Fatalf("Failed to create encryption service: %v", err)
+	}
+
+	appMetrics := metrics.New()
+
+	// Метрики прогресса доступны, пока идёт перешифрование
+	if *metricsAddr != "" {
+		go func() {
+			mux := http.NewServeMux()
+			mux.Handle("/metrics", promhttp.Handler())
+			if err := http.ListenAndServe(*metricsAddr, mux); err != nil {
+				log.Printf("Rekey metrics server stopped: %v", err)
+			}
+		}()
+	}
+
+	// Прерывание останавливает задачу после текущей порции; повторный запуск продолжит с того же места
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	job := rekey.NewJob(secretStore, encryptionService, appMetrics, *batchSize)
+	result, err := job.Run(ctx)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			log.Printf("Rekey interrupted: %d re-encrypted, %d skipped, %d failed; run again to resume",
+				result.Reencrypted, result.Skipped, result.Failed)
+			return
+		}
+		log.Fatalf("Rekey failed: %v", err)
+	}
+
+	log.Printf("Rekey finished: %d re-encrypted, %d skipped, %d failed",
+		result.Reencrypted, result.Skipped, result.Failed)
+
+	if result.Failed > 0 {
+		os.Exit(1)
+	}
+}
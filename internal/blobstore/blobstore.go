@@ -0,0 +1,52 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"time"
+)
+
+// ErrBlobNotFound возвращается, если блоба с таким ключом нет
+var ErrBlobNotFound = errors.New("blob not found")
+
+// keyPattern - допустимые ключи блобов (без разделителей путей и ведущей точки)
+var keyPattern = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9._-]{0,199}$`)
+
+// BlobStore хранит большие зашифрованные данные секретов (содержимое файлов)
+// вне таблицы secrets; строка секрета хранит только ключ блоба.
+// Хранилище получает уже зашифрованные данные и не видит открытого текста.
+type BlobStore interface {
+	// Create начинает запись блоба. Блоб становится доступен только после Commit.
+	Create(ctx context.Context, key string) (Writer, error)
+
+	// Open открывает блоб для чтения. Если блоба нет, возвращает ErrBlobNotFound.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+
+	// Delete удаляет блоб. Удаление несуществующего блоба не считается ошибкой.
+	Delete(ctx context.Context, key string) error
+
+	// List передаёт в fn ключи блобов, записанных раньше before
+	List(ctx context.Context, before time.Time, fn func(key string) error) error
+}
+
+// Writer записывает блоб потоком
+type Writer interface {
+	io.Writer
+
+	// Commit завершает запись и делает блоб доступным
+	Commit() error
+
+	// Abort отменяет запись и удаляет записанные данные
+	Abort() error
+}
+
+// validateKey проверяет ключ блоба
+func validateKey(key string) error {
+	if !keyPattern.MatchString(key) {
+		return fmt.Errorf("invalid blob key %q", key)
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// tempPrefix - префикс временных файлов незавершённой записи
+const tempPrefix = ".upload-"
+
+// FilesystemStore хранит блобы файлами в локальном каталоге. Подходит для
+// одного экземпляра сервиса или общего тома (NFS и т.п.).
+type FilesystemStore struct {
+	dir string
+}
+
+// NewFilesystemStore создаёт хранилище в каталоге dir (создаётся при необходимости)
+func NewFilesystemStore(dir string) (*FilesystemStore, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("blob directory is required")
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create blob directory: %w", err)
+	}
+
+	return &FilesystemStore{dir: dir}, nil
+}
+
+// Create начинает запись блоба во временный файл; Commit переименовывает его
+func (s *FilesystemStore) Create(ctx context.Context, key string) (Writer, error) {
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+
+	file, err := os.CreateTemp(s.dir, tempPrefix+"*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create blob file: %w", err)
+	}
+
+	return &fileWriter{file: file, path: filepath.Join(s.dir, key)}, nil
+}
+
+// Open открывает файл блоба
+func (s *FilesystemStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(filepath.Join(s.dir, key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrBlobNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open blob: %w", err)
+	}
+	return file, nil
+}
+
+// Delete удаляет файл блоба
+func (s *FilesystemStore) Delete(ctx context.Context, key string) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+
+	if err := os.Remove(filepath.Join(s.dir, key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to delete blob: %w", err)
+	}
+	return nil
+}
+
+// List перечисляет файлы блобов, изменённые раньше before. Временные файлы
+// незавершённой записи старше before удаляются: их запись была прервана.
+func (s *FilesystemStore) List(ctx context.Context, before time.Time, fn func(key string) error) error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return fmt.Errorf("failed to list blobs: %w", err)
+	}
+
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() || !info.ModTime().Before(before) {
+			continue
+		}
+
+		if strings.HasPrefix(entry.Name(), tempPrefix) {
+			os.Remove(filepath.Join(s.dir, entry.Name()))
+			continue
+		}
+
+		if validateKey(entry.Name()) != nil {
+			continue
+		}
+
+		if err := fn(entry.Name()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// fileWriter записывает блоб во временный файл
+type fileWriter struct {
+	file *os.File
+	path string
+}
+
+func (w *fileWriter) Write(p []byte) (int, error) {
+	return w.file.Write(p)
+}
+
+// Commit сбрасывает данные на диск и атомарно переименовывает файл
+func (w *fileWriter) Commit() error {
+	if err := w.file.Sync(); err != nil {
+		w.Abort()
+		return fmt.Errorf("failed to sync blob: %w", err)
+	}
+
+	if err := w.file.Close(); err != nil {
+		os.Remove(w.file.Name())
+		return fmt.Errorf("failed to close blob: %w", err)
+	}
+
+	if err := os.Rename(w.file.Name(), w.path); err != nil {
+		os.Remove(w.file.Name())
+		return fmt.Errorf("failed to store blob: %w", err)
+	}
+
+	return nil
+}
+
+// Abort удаляет временный файл
+func (w *fileWriter) Abort() error {
+	w.file.Close()
+	if err := os.Remove(w.file.Name()); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove blob: %w", err)
+	}
+	return nil
+}
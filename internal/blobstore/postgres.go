@@ -0,0 +1,165 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/savo4ka/ares-api/internal/database"
+)
+
+// PostgresStore хранит блобы в таблице blob_chunks той же БД, что и секреты.
+// Не требует дополнительной инфраструктуры, но увеличивает размер БД.
+type PostgresStore struct {
+	db *database.DB
+}
+
+// NewPostgresStore создаёт хранилище блобов в PostgreSQL
+func NewPostgresStore(db *database.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+// Create начинает запись блоба в транзакции; каждый Write сохраняется отдельной строкой
+func (s *PostgresStore) Create(ctx context.Context, key string) (Writer, error) {
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	return &postgresWriter{ctx: ctx, tx: tx, key: key}, nil
+}
+
+// Open читает блоб по строкам, не загружая его в память целиком
+func (s *PostgresStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	rows, err := s.db.Query(ctx, `SELECT data FROM blob_chunks WHERE blob_key = $1 ORDER BY seq`, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open blob: %w", err)
+	}
+
+	if !rows.Next() {
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("failed to open blob: %w", err)
+		}
+		return nil, ErrBlobNotFound
+	}
+
+	return &postgresReader{rows: rows}, nil
+}
+
+// Delete удаляет строки блоба
+func (s *PostgresStore) Delete(ctx context.Context, key string) error {
+	if _, err := s.db.Exec(ctx, `DELETE FROM blob_chunks WHERE blob_key = $1`, key); err != nil {
+		return fmt.Errorf("failed to delete blob: %w", err)
+	}
+	return nil
+}
+
+// List перечисляет блобы, последняя строка которых записана раньше before
+func (s *PostgresStore) List(ctx context.Context, before time.Time, fn func(key string) error) error {
+	rows, err := s.db.Query(ctx, `
+		SELECT blob_key FROM blob_chunks
+		GROUP BY blob_key
+		HAVING MAX(created_at) < $1
+	`, before)
+	if err != nil {
+		return fmt.Errorf("failed to list blobs: %w", err)
+	}
+
+	// Ключи собираются заранее: fn может удалять блобы
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan blob key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to list blobs: %w", err)
+	}
+
+	for _, key := range keys {
+		if err := fn(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// postgresWriter записывает строки блоба в транзакции
+type postgresWriter struct {
+	ctx context.Context
+	tx  pgx.Tx
+	key string
+	seq int
+}
+
+func (w *postgresWriter) Write(p []byte) (int, error) {
+	_, err := w.tx.Exec(w.ctx, `INSERT INTO blob_chunks (blob_key, seq, data, created_at) VALUES ($1, $2, $3, $4)`,
+		w.key, w.seq, p, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to store blob chunk: %w", err)
+	}
+	w.seq++
+	return len(p), nil
+}
+
+func (w *postgresWriter) Commit() error {
+	if err := w.tx.Commit(w.ctx); err != nil {
+		return fmt.Errorf("failed to commit blob: %w", err)
+	}
+	return nil
+}
+
+func (w *postgresWriter) Abort() error {
+	// Контекст запроса мог быть отменён, а транзакцию нужно откатить
+	if err := w.tx.Rollback(context.WithoutCancel(w.ctx)); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+		return fmt.Errorf("failed to abort blob: %w", err)
+	}
+	return nil
+}
+
+// postgresReader последовательно читает строки блоба. Перед первым Read
+// курсор уже стоит на первой строке.
+type postgresReader struct {
+	rows    pgx.Rows
+	pending []byte
+	started bool
+}
+
+func (r *postgresReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if r.started && !r.rows.Next() {
+			if err := r.rows.Err(); err != nil {
+				return 0, fmt.Errorf("failed to read blob: %w", err)
+			}
+			return 0, io.EOF
+		}
+		r.started = true
+
+		var data []byte
+		if err := r.rows.Scan(&data); err != nil {
+			return 0, fmt.Errorf("failed to read blob: %w", err)
+		}
+		r.pending = data
+	}
+
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+func (r *postgresReader) Close() error {
+	r.rows.Close()
+	return nil
+}
@@ -0,0 +1,445 @@
+package blobstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// s3PartSize - размер части multipart загрузки (минимум S3 - 5 MiB).
+	// Блоб меньше одной части загружается одним PUT.
+	s3PartSize = 5 * 1024 * 1024
+
+	// s3UnsignedPayload - тело запроса не входит в подпись (передаётся по TLS)
+	s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+
+	// s3MaxErrorBody - сколько байт ответа с ошибкой читать для сообщения
+	s3MaxErrorBody = 4096
+)
+
+// S3Config содержит параметры подключения к S3-совместимому хранилищу
+type S3Config struct {
+	Endpoint        string // Адрес сервиса, например https://s3.eu-central-1.amazonaws.com или http://localhost:9000
+	Region          string // Регион для подписи запросов (по умолчанию us-east-1)
+	Bucket          string
+	Prefix          string // Префикс ключей объектов (каталог внутри бакета)
+	AccessKeyID     string
+	SecretAccessKey string
+	PathStyle       bool // Адресация bucket в пути (нужна MinIO и большинству S3-совместимых сервисов)
+}
+
+// S3Store хранит блобы объектами в S3-совместимом хранилище (AWS S3, MinIO и т.п.).
+// Запросы подписываются AWS Signature Version 4.
+type S3Store struct {
+	endpoint *url.URL
+	cfg      S3Config
+	client   *http.Client
+}
+
+// NewS3Store создаёт хранилище блобов в S3
+func NewS3Store(cfg S3Config) (*S3Store, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" || cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return nil, fmt.Errorf("S3 endpoint, bucket and credentials are required")
+	}
+
+	endpoint, err := url.Parse(strings.TrimRight(cfg.Endpoint, "/"))
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", cfg.Endpoint)
+	}
+
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+
+	return &S3Store{
+		endpoint: endpoint,
+		cfg:      cfg,
+		client:   &http.Client{},
+	}, nil
+}
+
+// Create начинает загрузку блоба. Данные накапливаются частями по s3PartSize,
+// поэтому в памяти находится не больше одной части.
+func (s *S3Store) Create(ctx context.Context, key string) (Writer, error) {
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+
+	return &s3Writer{ctx: ctx, store: s, key: s.cfg.Prefix + key}, nil
+}
+
+// Open открывает объект блоба для чтения
+func (s *S3Store) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+
+	resp, err := s.do(ctx, http.MethodGet, s.cfg.Prefix+key, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open blob: %w", err)
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, ErrBlobNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to open blob: %w", s3Error(resp))
+	}
+
+	return resp.Body, nil
+}
+
+// Delete удаляет объект блоба
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+
+	resp, err := s.do(ctx, http.MethodDelete, s.cfg.Prefix+key, nil, nil)
+	if err != nil {
+		return fmt.Errorf("failed to delete blob: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("failed to delete blob: %w", s3Error(resp))
+	}
+	return nil
+}
+
+// s3ListResult - ответ ListObjectsV2
+type s3ListResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// List перечисляет объекты с префиксом хранилища, изменённые раньше before
+func (s *S3Store) List(ctx context.Context, before time.Time, fn func(key string) error) error {
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {s.cfg.Prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		var result s3ListResult
+		if err := s.doXML(ctx, http.MethodGet, "", query, nil, &result); err != nil {
+			return fmt.Errorf("failed to list blobs: %w", err)
+		}
+
+		for _, object := range result.Contents {
+			key := strings.TrimPrefix(object.Key, s.cfg.Prefix)
+			if !object.LastModified.Before(before) || validateKey(key) != nil {
+				continue
+			}
+			if err := fn(key); err != nil {
+				return err
+			}
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// s3Writer загружает блоб: небольшой - одним PUT, большой - multipart загрузкой
+type s3Writer struct {
+	ctx      context.Context
+	store    *S3Store
+	key      string
+	buf      []byte
+	uploadID string
+	parts    []s3CompletedPart
+}
+
+// s3CompletedPart - загруженная часть multipart загрузки
+type s3CompletedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+func (w *s3Writer) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		// Полная часть отправляется, только когда известно, что за ней есть данные
+		if len(w.buf) == s3PartSize {
+			if err := w.uploadPart(); err != nil {
+				return written, err
+			}
+		}
+
+		n := min(len(p), s3PartSize-len(w.buf))
+		w.buf = append(w.buf, p[:n]...)
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+// Commit загружает оставшиеся данные и завершает загрузку
+func (w *s3Writer) Commit() error {
+	if w.uploadID == "" {
+		resp, err := w.store.do(w.ctx, http.MethodPut, w.key, nil, w.buf)
+		if err != nil {
+			return fmt.Errorf("failed to upload blob: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("failed to upload blob: %w", s3Error(resp))
+		}
+		return nil
+	}
+
+	if len(w.buf) > 0 {
+		if err := w.uploadPart(); err != nil {
+			w.Abort()
+			return err
+		}
+	}
+
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name          `xml:"CompleteMultipartUpload"`
+		Parts   []s3CompletedPart `xml:"Part"`
+	}{Parts: w.parts})
+	if err != nil {
+		w.Abort()
+		return fmt.Errorf("failed to encode upload parts: %w", err)
+	}
+
+	// Ошибка завершения может прийти в теле ответа со статусом 200
+	var result struct {
+		XMLName xml.Name
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if err := w.store.doXML(w.ctx, http.MethodPost, w.key, url.Values{"uploadId": {w.uploadID}}, body, &result); err != nil {
+		w.Abort()
+		return fmt.Errorf("failed to complete blob upload: %w", err)
+	}
+	if result.XMLName.Local == "Error" {
+		w.Abort()
+		return fmt.Errorf("failed to complete blob upload: %s: %s", result.Code, result.Message)
+	}
+
+	return nil
+}
+
+// Abort отменяет multipart загрузку (небольшой блоб ещё не отправлялся)
+func (w *s3Writer) Abort() error {
+	w.buf = nil
+	if w.uploadID == "" {
+		return nil
+	}
+
+	// Контекст запроса мог быть отменён, а незавершённую загрузку нужно удалить
+	ctx := context.WithoutCancel(w.ctx)
+	resp, err := w.store.do(ctx, http.MethodDelete, w.key, url.Values{"uploadId": {w.uploadID}}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to abort blob upload: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("failed to abort blob upload: %w", s3Error(resp))
+	}
+	return nil
+}
+
+// uploadPart отправляет накопленную часть, при необходимости начиная multipart загрузку
+func (w *s3Writer) uploadPart() error {
+	if w.uploadID == "" {
+		var result struct {
+			UploadID string `xml:"UploadId"`
+		}
+		if err := w.store.doXML(w.ctx, http.MethodPost, w.key, url.Values{"uploads": {""}}, nil, &result); err != nil {
+			return fmt.Errorf("failed to start blob upload: %w", err)
+		}
+		if result.UploadID == "" {
+			return fmt.Errorf("failed to start blob upload: empty upload ID")
+		}
+		w.uploadID = result.UploadID
+	}
+
+	partNumber := len(w.parts) + 1
+	query := url.Values{"partNumber": {strconv.Itoa(partNumber)}, "uploadId": {w.uploadID}}
+
+	resp, err := w.store.do(w.ctx, http.MethodPut, w.key, query, w.buf)
+	if err != nil {
+		return fmt.Errorf("failed to upload blob part: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to upload blob part: %w", s3Error(resp))
+	}
+
+	w.parts = append(w.parts, s3CompletedPart{PartNumber: partNumber, ETag: resp.Header.Get("ETag")})
+	w.buf = w.buf[:0]
+	return nil
+}
+
+// doXML выполняет запрос и разбирает XML ответ
+func (s *S3Store) doXML(ctx context.Context, method, key string, query url.Values, body []byte, result any) error {
+	resp, err := s.do(ctx, method, key, query, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return s3Error(resp)
+	}
+
+	if err := xml.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// do выполняет подписанный запрос к объекту key (пустой key - запрос к бакету)
+func (s *S3Store) do(ctx context.Context, method, key string, query url.Values, body []byte) (*http.Response, error) {
+	target := *s.endpoint
+	path := "/" + key
+	if s.cfg.PathStyle {
+		path = "/" + s.cfg.Bucket + path
+	} else {
+		target.Host = s.cfg.Bucket + "." + target.Host
+	}
+	target.Path = s.endpoint.Path + path
+	target.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+
+	signV4(req, s.cfg.AccessKeyID, s.cfg.SecretAccessKey, s.cfg.Region, s3UnsignedPayload, time.Now())
+
+	return s.client.Do(req)
+}
+
+// s3Error формирует ошибку из ответа S3 и закрывает его тело
+func s3Error(resp *http.Response) error {
+	defer resp.Body.Close()
+
+	var body struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, s3MaxErrorBody))
+	if xml.Unmarshal(data, &body) == nil && body.Code != "" {
+		return fmt.Errorf("S3 returned status %d: %s: %s", resp.StatusCode, body.Code, body.Message)
+	}
+	return fmt.Errorf("S3 returned status %d", resp.StatusCode)
+}
+
+// signV4 подписывает запрос AWS Signature Version 4 для сервиса s3.
+// В подпись входят Host и все заголовки запроса.
+func signV4(req *http.Request, accessKeyID, secretAccessKey, region, payloadHash string, now time.Time) {
+	timestamp := now.UTC().Format("20060102T150405Z")
+	date := timestamp[:8]
+
+	req.Header.Set("X-Amz-Date", timestamp)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		timestamp,
+		scope,
+		hexSHA256([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+secretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKeyID, scope, signedHeaders, signature))
+}
+
+// canonicalQuery кодирует параметры запроса так, как этого требует подпись:
+// по RFC 3986, отсортированными по имени
+func canonicalQuery(query url.Values) string {
+	if len(query) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		for _, value := range query[name] {
+			pairs = append(pairs, uriEncode(name)+"="+uriEncode(value))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode кодирует строку по RFC 3986 (url.QueryEscape кодирует пробел как "+")
+func uriEncode(value string) string {
+	return strings.ReplaceAll(url.QueryEscape(value), "+", "%20")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func hexSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -2,42 +2,439 @@ package config
 
 import (
 	"fmt"
+	"math"
 	"os"
 	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultKeyID - ID ключа, заданного через ENCRYPTION_KEY
+const defaultKeyID = "default"
+
+// Режимы сохранения сведений о получателе секрета
+const (
+	ReaderInfoNone       = "none"       // Не сохранять
+	ReaderInfoAnonymized = "anonymized" // Сохранять IP с обнулённым адресом хоста и User-Agent
+	ReaderInfoFull       = "full"       // Сохранять полный IP и User-Agent
+)
+
+// Хранилища секретов
+const (
+	StorageBackendPostgres = "postgres"
+	StorageBackendSQLite   = "sqlite"
+	StorageBackendRedis    = "redis"
+	StorageBackendMemory   = "memory"
+)
+
+// Драйверы хранилища содержимого файлов
+const (
+	BlobStorePostgres   = "postgres"
+	BlobStoreFilesystem = "filesystem"
+	BlobStoreS3         = "s3"
+)
+
+// Провайдеры мастер-ключей
+const (
+	KeyProviderLocal = "local"
+	KeyProviderVault = "vault"
 )
 
 type Config struct {
-	ServerPort      string
-	DatabaseURL     string
-	EncryptionKey   string
-	AllowedOrigins  string
+	ServerPort     string
+	DatabaseURL    string
+	AllowedOrigins string
+
+	// Хранилище секретов
+	StorageBackend string // postgres, sqlite, redis или memory
+	SQLitePath     string // Файл БД для хранилища sqlite
+	RedisURL       string // Адрес Redis для хранилища redis
+	RedisPrefix    string // Префикс ключей в Redis
+	AutoMigrate    bool   // Применять миграции PostgreSQL при запуске
+
+	// Максимальное время одного запроса к хранилищу секретов (0 - без ограничения)
+	DBQueryTimeout time.Duration
+
+	// Пул подключений к PostgreSQL (0 - не задано: значение из DATABASE_URL или по умолчанию)
+	DBMaxConns          int           // Максимальное количество подключений
+	DBMinConns          int           // Количество подключений, которые пул держит открытыми
+	DBMaxConnLifetime   time.Duration // Время жизни подключения
+	DBHealthCheckPeriod time.Duration // Период проверки простаивающих подключений
+
+	// Количество неверных парольных фраз, после которого секрет уничтожается
+	PassphraseMaxAttempts int
+
+	// Максимальное количество просмотров одного секрета
+	MaxViews int
+
+	// Политика времени жизни секретов
+	MinTTL     time.Duration
+	MaxTTL     time.Duration
+	DefaultTTL time.Duration
+
+	// Максимальный размер файла секрета в байтах
+	MaxUploadSize int64
+
+	// Хранилище содержимого файлов
+	BlobStore         string // Драйвер: postgres, filesystem или s3
+	BlobDir           string // Каталог для драйвера filesystem
+	S3Endpoint        string
+	S3Region          string
+	S3Bucket          string
+	S3Prefix          string
+	S3AccessKeyID     string
+	S3SecretAccessKey string
+	S3PathStyle       bool
+
+	// Двухшаговое открытие секрета (защита от ботов предпросмотра ссылок)
+	RevealRequired    bool          // GET возвращает nonce, контент выдаёт только POST /reveal
+	RevealNonceTTL    time.Duration // Время жизни nonce
+	RevealSecret      string        // Ключ HMAC для nonce (общий для всех экземпляров сервиса)
+	BlockedUserAgents []string      // Дополнительные User-Agent ботов, которым секреты не выдаются
+
+	// Сведения о получателе для подтверждения прочтения
+	ReaderInfo string // Режим: none, anonymized или full
+	TrustProxy bool   // Брать IP клиента из X-Forwarded-For (только за доверенным прокси)
+
+	// Уведомления о событиях секретов (webhooks)
+	WebhookDefaultURL    string        // URL по умолчанию для секретов без notify_webhook_url
+	WebhookSigningSecret string        // Ключ HMAC-SHA256 для подписи уведомлений (без него уведомления выключены)
+	WebhookMaxAttempts   int           // Количество попыток доставки
+	WebhookTimeout       time.Duration // Таймаут одной попытки
+	WebhookAllowPrivate  bool          // Разрешить доставку на адреса внутренней сети
+	WebhookFailedTTL     time.Duration // Сколько хранить недоставленные уведомления (0 - бессрочно)
+
+	// Отправка писем (без SMTP_HOST выключена)
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+	SMTPTimeout  time.Duration
+
+	// Мастер-ключи шифрования
+	KeyProvider       string            // Провайдер мастер-ключей: local или vault
+	EncryptionKeys    map[string]string // Локальная связка ключей: ID ключа -> ключ
+	EncryptionKeyFile string            // Файл с локальной связкой ключей (вместо ENCRYPTION_KEY/ENCRYPTION_KEYS)
+	ActiveKeyID       string            // ID локального ключа, которым шифруются новые секреты
+
+	// Vault Transit (KEY_PROVIDER=vault)
+	VaultAddress      string
+	VaultToken        string
+	VaultNamespace    string
+	VaultTransitMount string
+	VaultTransitKey   string
 }
 
 // Load загружает конфигурацию из переменных окружения
 func Load() (*Config, error) {
 	config := &Config{
 		ServerPort:     getEnv("SERVER_PORT", "8080"),
-		DatabaseURL:    getEnv("DATABASE_URL", ""),
-		EncryptionKey:  getEnv("ENCRYPTION_KEY", ""),
 		AllowedOrigins: getEnv("ALLOWED_ORIGINS", "*"),
+		SQLitePath:     getEnv("SQLITE_PATH", "./data/ares.db"),
+		RedisURL:       getEnv("REDIS_URL", "redis://localhost:6379/0"),
+		RedisPrefix:    getEnv("REDIS_PREFIX", "ares:"),
+		AutoMigrate:    getEnvAsBool("AUTO_MIGRATE", false),
+
+		PassphraseMaxAttempts: getEnvAsInt("PASSPHRASE_MAX_ATTEMPTS", 5),
+		MaxViews:              getEnvAsInt("MAX_VIEWS", 10),
+		MaxUploadSize:         getEnvAsInt64("MAX_UPLOAD_SIZE", 10*1024*1024),
+	}
+
+	if err := loadDatabaseConfig(config); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if config.MinTTL, err = getEnvAsDuration("MIN_TTL", 5*time.Minute); err != nil {
+		return nil, err
+	}
+	if config.MaxTTL, err = getEnvAsDuration("MAX_TTL", 7*24*time.Hour); err != nil {
+		return nil, err
+	}
+	if config.DefaultTTL, err = getEnvAsDuration("DEFAULT_TTL", 24*time.Hour); err != nil {
+		return nil, err
+	}
+
+	// Без PostgreSQL блобы по умолчанию хранятся в файлах
+	defaultBlobStore := BlobStorePostgres
+	if config.StorageBackend != StorageBackendPostgres {
+		defaultBlobStore = BlobStoreFilesystem
+	}
+	config.BlobStore = getEnv("BLOB_STORE", defaultBlobStore)
+	config.BlobDir = getEnv("BLOB_DIR", "./data/blobs")
+	config.S3Endpoint = getEnv("S3_ENDPOINT", "")
+	config.S3Region = getEnv("S3_REGION", "us-east-1")
+	config.S3Bucket = getEnv("S3_BUCKET", "")
+	config.S3Prefix = getEnv("S3_PREFIX", "ares/")
+	config.S3AccessKeyID = getEnv("S3_ACCESS_KEY_ID", "")
+	config.S3SecretAccessKey = getEnv("S3_SECRET_ACCESS_KEY", "")
+	config.S3PathStyle = getEnvAsBool("S3_PATH_STYLE", false)
+	config.RevealRequired = getEnvAsBool("REVEAL_REQUIRED", false)
+	config.RevealSecret = getEnv("REVEAL_SECRET", "")
+	config.BlockedUserAgents = splitList(getEnv("BLOCKED_USER_AGENTS", ""))
+	config.ReaderInfo = getEnv("READER_INFO", ReaderInfoNone)
+	config.TrustProxy = getEnvAsBool("TRUST_PROXY", false)
+	config.WebhookDefaultURL = getEnv("WEBHOOK_DEFAULT_URL", "")
+	config.WebhookSigningSecret = getEnv("WEBHOOK_SIGNING_SECRET", "")
+	config.WebhookMaxAttempts = getEnvAsInt("WEBHOOK_MAX_ATTEMPTS", 10)
+	config.WebhookAllowPrivate = getEnvAsBool("WEBHOOK_ALLOW_PRIVATE", false)
+	if config.WebhookTimeout, err = getEnvAsDuration("WEBHOOK_TIMEOUT", 10*time.Second); err != nil {
+		return nil, err
+	}
+	if config.WebhookFailedTTL, err = getEnvAsDuration("WEBHOOK_FAILED_TTL", 7*24*time.Hour); err != nil {
+		return nil, err
+	}
+	config.SMTPHost = getEnv("SMTP_HOST", "")
+	config.SMTPPort = getEnvAsInt("SMTP_PORT", 587)
+	config.SMTPUsername = getEnv("SMTP_USERNAME", "")
+	config.SMTPPassword = getEnv("SMTP_PASSWORD", "")
+	config.SMTPFrom = getEnv("SMTP_FROM", "")
+	if config.SMTPTimeout, err = getEnvAsDuration("SMTP_TIMEOUT", 10*time.Second); err != nil {
+		return nil, err
+	}
+	if config.RevealNonceTTL, err = getEnvAsDuration("REVEAL_NONCE_TTL", 5*time.Minute); err != nil {
+		return nil, err
+	}
+
+	switch config.StorageBackend {
+	case StorageBackendPostgres:
+	case StorageBackendSQLite, StorageBackendRedis, StorageBackendMemory:
+		if config.BlobStore == BlobStorePostgres {
+			return nil, fmt.Errorf("BLOB_STORE %q requires STORAGE_BACKEND %q", BlobStorePostgres, StorageBackendPostgres)
+		}
+	default:
+		return nil, fmt.Errorf("STORAGE_BACKEND must be %q, %q, %q or %q",
+			StorageBackendPostgres, StorageBackendSQLite, StorageBackendRedis, StorageBackendMemory)
 	}
 
-	if config.DatabaseURL == "" {
-		return nil, fmt.Errorf("DATABASE_URL is required")
+	if config.PassphraseMaxAttempts <= 0 {
+		return nil, fmt.Errorf("PASSPHRASE_MAX_ATTEMPTS must be positive")
 	}
 
-	if config.EncryptionKey == "" {
-		return nil, fmt.Errorf("ENCRYPTION_KEY is required")
+	if config.MaxViews <= 0 {
+		return nil, fmt.Errorf("MAX_VIEWS must be positive")
 	}
 
-	// Проверяем, что ключ шифрования имеет правильную длину (16 байт для AES-128)
-	if len(config.EncryptionKey) != 16 {
-		return nil, fmt.Errorf("ENCRYPTION_KEY must be exactly 16 characters for AES-128")
+	if config.MaxUploadSize <= 0 {
+		return nil, fmt.Errorf("MAX_UPLOAD_SIZE must be positive")
 	}
 
+	switch config.BlobStore {
+	case BlobStorePostgres, BlobStoreFilesystem:
+	case BlobStoreS3:
+		if config.S3Endpoint == "" || config.S3Bucket == "" || config.S3AccessKeyID == "" || config.S3SecretAccessKey == "" {
+			return nil, fmt.Errorf("S3_ENDPOINT, S3_BUCKET, S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY are required when BLOB_STORE is s3")
+		}
+	default:
+		return nil, fmt.Errorf("BLOB_STORE must be %q, %q or %q", BlobStorePostgres, BlobStoreFilesystem, BlobStoreS3)
+	}
+
+	if config.MinTTL <= 0 || config.MinTTL > config.MaxTTL {
+		return nil, fmt.Errorf("MIN_TTL must be positive and not greater than MAX_TTL")
+	}
+
+	if config.DefaultTTL < config.MinTTL || config.DefaultTTL > config.MaxTTL {
+		return nil, fmt.Errorf("DEFAULT_TTL must be between MIN_TTL and MAX_TTL")
+	}
+
+	if config.RevealNonceTTL <= 0 {
+		return nil, fmt.Errorf("REVEAL_NONCE_TTL must be positive")
+	}
+
+	switch config.ReaderInfo {
+	case ReaderInfoNone, ReaderInfoAnonymized, ReaderInfoFull:
+	default:
+		return nil, fmt.Errorf("READER_INFO must be %q, %q or %q", ReaderInfoNone, ReaderInfoAnonymized, ReaderInfoFull)
+	}
+
+	if config.WebhookDefaultURL != "" && config.WebhookSigningSecret == "" {
+		return nil, fmt.Errorf("WEBHOOK_SIGNING_SECRET is required when WEBHOOK_DEFAULT_URL is set")
+	}
+
+	// Очередь уведомлений хранится в PostgreSQL
+	if config.WebhookSigningSecret != "" && config.StorageBackend != StorageBackendPostgres {
+		return nil, fmt.Errorf("webhooks require STORAGE_BACKEND %q", StorageBackendPostgres)
+	}
+
+	if config.WebhookMaxAttempts <= 0 || config.WebhookTimeout <= 0 {
+		return nil, fmt.Errorf("WEBHOOK_MAX_ATTEMPTS and WEBHOOK_TIMEOUT must be positive")
+	}
+
+	if config.WebhookFailedTTL < 0 {
+		return nil, fmt.Errorf("WEBHOOK_FAILED_TTL must not be negative")
+	}
+
+	if config.SMTPHost != "" && config.SMTPFrom == "" {
+		return nil, fmt.Errorf("SMTP_FROM is required when SMTP_HOST is set")
+	}
+
+	if err := loadKeyConfig(config); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// LoadDatabase загружает только настройки хранилища и подключения к БД.
+// Используется командами, которым не нужны ключи шифрования и настройки сервера.
+func LoadDatabase() (*Config, error) {
+	config := &Config{}
+	if err := loadDatabaseConfig(config); err != nil {
+		return nil, err
+	}
 	return config, nil
 }
 
+// loadDatabaseConfig загружает настройки хранилища секретов и пула подключений к PostgreSQL
+func loadDatabaseConfig(config *Config) error {
+	config.StorageBackend = getEnv("STORAGE_BACKEND", StorageBackendPostgres)
+	config.DatabaseURL = getEnv("DATABASE_URL", "")
+
+	var err error
+	if config.DBMaxConns, err = getEnvAsConnCount("DB_MAX_CONNS", 1); err != nil {
+		return err
+	}
+	if config.DBMinConns, err = getEnvAsConnCount("DB_MIN_CONNS", 0); err != nil {
+		return err
+	}
+	if config.DBQueryTimeout, err = getEnvAsDuration("DB_QUERY_TIMEOUT", 5*time.Second); err != nil {
+		return err
+	}
+	if config.DBMaxConnLifetime, err = getEnvAsDuration("DB_MAX_CONN_LIFETIME", 0); err != nil {
+		return err
+	}
+	if config.DBHealthCheckPeriod, err = getEnvAsDuration("DB_HEALTH_CHECK_PERIOD", 0); err != nil {
+		return err
+	}
+
+	if config.StorageBackend == StorageBackendPostgres && config.DatabaseURL == "" {
+		return fmt.Errorf("DATABASE_URL is required")
+	}
+
+	if config.DBQueryTimeout < 0 {
+		return fmt.Errorf("DB_QUERY_TIMEOUT must not be negative")
+	}
+
+	if config.DBMaxConns > 0 && config.DBMinConns > config.DBMaxConns {
+		return fmt.Errorf("DB_MIN_CONNS must not exceed DB_MAX_CONNS")
+	}
+
+	if config.DBMaxConnLifetime < 0 || config.DBHealthCheckPeriod < 0 {
+		return fmt.Errorf("DB_MAX_CONN_LIFETIME and DB_HEALTH_CHECK_PERIOD must not be negative")
+	}
+
+	return nil
+}
+
+// WebhooksEnabled сообщает, включены ли уведомления о событиях секретов
+func (c *Config) WebhooksEnabled() bool {
+	return c.WebhookSigningSecret != ""
+}
+
+// loadKeyConfig загружает настройки мастер-ключей шифрования
+func loadKeyConfig(config *Config) error {
+	config.KeyProvider = getEnv("KEY_PROVIDER", KeyProviderLocal)
+	config.EncryptionKeyFile = getEnv("ENCRYPTION_KEY_FILE", "")
+	config.ActiveKeyID = getEnv("ENCRYPTION_ACTIVE_KEY_ID", "")
+
+	keys, err := parseEncryptionKeys(getEnv("ENCRYPTION_KEYS", ""))
+	if err != nil {
+		return err
+	}
+
+	// ENCRYPTION_KEY - ключ с ID "default" (им зашифрованы записи, созданные до связки ключей)
+	if key := getEnv("ENCRYPTION_KEY", ""); key != "" {
+		if _, exists := keys[defaultKeyID]; exists {
+			return fmt.Errorf("ENCRYPTION_KEYS must not contain key %q when ENCRYPTION_KEY is set", defaultKeyID)
+		}
+		keys[defaultKeyID] = key
+	}
+
+	if config.EncryptionKeyFile != "" && len(keys) > 0 {
+		return fmt.Errorf("ENCRYPTION_KEY_FILE cannot be combined with ENCRYPTION_KEY or ENCRYPTION_KEYS")
+	}
+
+	// Проверяем длину ключей шифрования: 32 байта для AES-256-GCM или 16 байт
+	// (из него выводится ключ AES-256-GCM, старые записи AES-128-CBC остаются читаемыми)
+	for id, key := range keys {
+		if len(key) != 16 && len(key) != 32 {
+			return fmt.Errorf("encryption key %q must be exactly 16 or 32 characters", id)
+		}
+	}
+	config.EncryptionKeys = keys
+
+	switch config.KeyProvider {
+	case KeyProviderLocal:
+		if len(keys) == 0 && config.EncryptionKeyFile == "" {
+			return fmt.Errorf("ENCRYPTION_KEY, ENCRYPTION_KEYS or ENCRYPTION_KEY_FILE is required")
+		}
+
+	case KeyProviderVault:
+		// Локальные ключи (если заданы) используются только для расшифровки старых секретов
+		config.VaultAddress = getEnv("VAULT_ADDR", "")
+		config.VaultToken = getEnv("VAULT_TOKEN", "")
+		config.VaultNamespace = getEnv("VAULT_NAMESPACE", "")
+		config.VaultTransitMount = getEnv("VAULT_TRANSIT_MOUNT", "transit")
+		config.VaultTransitKey = getEnv("VAULT_TRANSIT_KEY", "")
+
+		if config.VaultAddress == "" || config.VaultToken == "" || config.VaultTransitKey == "" {
+			return fmt.Errorf("VAULT_ADDR, VAULT_TOKEN and VAULT_TRANSIT_KEY are required when KEY_PROVIDER is vault")
+		}
+
+	default:
+		return fmt.Errorf("KEY_PROVIDER must be %q or %q", KeyProviderLocal, KeyProviderVault)
+	}
+
+	// Активный ключ связки из файла проверяется при чтении файла
+	if len(keys) == 0 {
+		return nil
+	}
+
+	if config.ActiveKeyID == "" {
+		if len(keys) > 1 && config.KeyProvider == KeyProviderLocal {
+			return fmt.Errorf("ENCRYPTION_ACTIVE_KEY_ID is required when more than one encryption key is configured")
+		}
+		for id := range keys {
+			config.ActiveKeyID = id
+			break
+		}
+	}
+
+	if _, ok := keys[config.ActiveKeyID]; !ok {
+		return fmt.Errorf("ENCRYPTION_ACTIVE_KEY_ID %q does not match any configured encryption key", config.ActiveKeyID)
+	}
+
+	return nil
+}
+
+// parseEncryptionKeys разбирает связку ключей в формате "id1:key1,id2:key2"
+func parseEncryptionKeys(value string) (map[string]string, error) {
+	keys := make(map[string]string)
+	if value == "" {
+		return keys, nil
+	}
+
+	for _, entry := range strings.Split(value, ",") {
+		id, key, found := strings.Cut(strings.TrimSpace(entry), ":")
+		if !found || id == "" || key == "" {
+			return nil, fmt.Errorf("ENCRYPTION_KEYS entries must have the form id:key")
+		}
+
+		if len(id) > 64 {
+			return nil, fmt.Errorf("encryption key ID %q must be at most 64 characters", id)
+		}
+
+		if _, exists := keys[id]; exists {
+			return nil, fmt.Errorf("duplicate encryption key ID %q in ENCRYPTION_KEYS", id)
+		}
+
+		keys[id] = key
+	}
+
+	return keys, nil
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -52,3 +449,97 @@ func getEnvAsInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+func getEnvAsInt64(key string, defaultValue int64) int64 {
+	valueStr := getEnv(key, "")
+	if value, err := strconv.ParseInt(valueStr, 10, 64); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvAsBool(key string, defaultValue bool) bool {
+	valueStr := getEnv(key, "")
+	if value, err := strconv.ParseBool(valueStr); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+// splitList разбирает список значений, разделённых запятой, пропуская пустые
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func getEnvAsDuration(key string, defaultValue time.Duration) (time.Duration, error) {
+	valueStr := getEnv(key, "")
+	if valueStr == "" {
+		return defaultValue, nil
+	}
+
+	value, err := ParseDuration(valueStr)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", key, err)
+	}
+	return value, nil
+}
+
+// getEnvAsConnCount читает размер пула подключений. Пустое значение - параметр
+// не задан (0); иначе значение должно быть целым от minValue до math.MaxInt32.
+func getEnvAsConnCount(key string, minValue int64) (int, error) {
+	valueStr := getEnv(key, "")
+	if valueStr == "" {
+		return 0, nil
+	}
+
+	value, err := strconv.ParseInt(valueStr, 10, 64)
+	if err != nil || value < minValue || value > math.MaxInt32 {
+		return 0, fmt.Errorf("%s must be an integer between %d and %d", key, minValue, math.MaxInt32)
+	}
+	return int(value), nil
+}
+
+// day - единица "d" в ParseDuration
+const day = 24 * time.Hour
+
+// ParseDuration разбирает длительность в формате time.ParseDuration,
+// дополнительно поддерживая дни: "7d", "1d12h"
+func ParseDuration(value string) (time.Duration, error) {
+	before, after, found := strings.Cut(value, "d")
+	if !found {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		return duration, nil
+	}
+
+	n, err := strconv.ParseInt(before, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	// Количество дней, которое не помещается в time.Duration, отклоняется явно:
+	// иначе произведение переполнилось бы в отрицательное значение
+	if n > int64(math.MaxInt64/day) {
+		return 0, fmt.Errorf("duration %q is too large", value)
+	}
+	days := time.Duration(n) * day
+	if after == "" {
+		return days, nil
+	}
+
+	duration, err := time.ParseDuration(after)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	if duration > math.MaxInt64-days {
+		return 0, fmt.Errorf("duration %q is too large", value)
+	}
+	return days + duration, nil
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"10m", 10 * time.Minute},
+		{"36h", 36 * time.Hour},
+		{"7d", 7 * 24 * time.Hour},
+		{"1d12h", 36 * time.Hour},
+		{"0d", 0},
+		{"106751d", 106751 * 24 * time.Hour},
+		{"106751d23h", 106751*24*time.Hour + 23*time.Hour},
+	}
+	for _, tt := range tests {
+		got, err := ParseDuration(tt.value)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseDuration(%q) = %v, %v, want %v", tt.value, got, err, tt.want)
+		}
+	}
+
+	// 106752 дня не помещаются в time.Duration
+	for _, value := range []string{"", "d", "-1d", "1.5d", "7dd", "1d12", "ten", "106752d", "106751d24h", "9999999999999999999d"} {
+		if got, err := ParseDuration(value); err == nil {
+			t.Errorf("ParseDuration(%q) = %v, want an error", value, got)
+		}
+	}
+}
+
+func TestLoadDatabasePoolSize(t *testing.T) {
+	tests := []struct {
+		maxConns string
+		minConns string
+		wantMax  int
+		wantMin  int
+		wantErr  string
+	}{
+		{maxConns: "", minConns: "", wantMax: 0, wantMin: 0},
+		{maxConns: "40", minConns: "0", wantMax: 40, wantMin: 0},
+		{maxConns: "2147483647", minConns: "2147483647", wantMax: 2147483647, wantMin: 2147483647},
+		{maxConns: "2147483648", wantErr: "DB_MAX_CONNS must be an integer between 1 and 2147483647"},
+		{maxConns: "0", wantErr: "DB_MAX_CONNS must be an integer between 1 and 2147483647"},
+		{maxConns: "many", wantErr: "DB_MAX_CONNS must be an integer between 1 and 2147483647"},
+		{minConns: "-1", wantErr: "DB_MIN_CONNS must be an integer between 0 and 2147483647"},
+		{minConns: "99999999999999999999", wantErr: "DB_MIN_CONNS must be an integer between 0 and 2147483647"},
+		{maxConns: "4", minConns: "5", wantErr: "DB_MIN_CONNS must not exceed DB_MAX_CONNS"},
+	}
+
+	for _, tt := range tests {
+		t.Setenv("DATABASE_URL", "postgres://user@localhost/db")
+		t.Setenv("DB_MAX_CONNS", tt.maxConns)
+		t.Setenv("DB_MIN_CONNS", tt.minConns)
+
+		cfg, err := LoadDatabase()
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("DB_MAX_CONNS=%q DB_MIN_CONNS=%q: err = %v, want %q", tt.maxConns, tt.minConns, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("DB_MAX_CONNS=%q DB_MIN_CONNS=%q: %v", tt.maxConns, tt.minConns, err)
+			continue
+		}
+		if cfg.DBMaxConns != tt.wantMax || cfg.DBMinConns != tt.wantMin {
+			t.Errorf("DB_MAX_CONNS=%q DB_MIN_CONNS=%q: got %d, %d", tt.maxConns, tt.minConns, cfg.DBMaxConns, cfg.DBMinConns)
+		}
+	}
+}
@@ -1,149 +1,255 @@
 package crypto
 
 import (
+	"context"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
+	"strings"
 )
 
-// EncryptionService предоставляет методы для шифрования и расшифровки данных
+// Версии формата шифртекста. Шифртекст без префикса версии — устаревший
+// AES-128-CBC мастер-ключом, "v2:..." — AES-256-GCM мастер-ключом,
+// "v3:..." — AES-256-GCM собственным ключом данных секрета (envelope-шифрование).
+const (
+	envelopeVersionGCM     = "v2"
+	envelopeVersionDataKey = "v3"
+	envelopeSeparator      = ":"
+	dataKeySize            = 32
+)
+
+// DefaultKeyID - ID ключа, заданного через ENCRYPTION_KEY, и ключа записей,
+// созданных до появления связки ключей
+const DefaultKeyID = "default"
+
+// ErrAuthenticationFailed возвращается, когда шифртекст не прошёл проверку
+// подлинности (данные или связанный с ними ID секрета были изменены)
+var ErrAuthenticationFailed = errors.New("ciphertext authentication failed")
+
+// ErrUnknownKey возвращается, когда ключ, которым зашифрована запись, отсутствует
+// в связке ключей (например, был выведен из эксплуатации)
+var ErrUnknownKey = errors.New("unknown encryption key")
+
+// EncryptionService предоставляет методы для шифрования и расшифровки данных.
+// Каждый секрет шифруется собственным случайным ключом данных, который
+// оборачивается мастер-ключом активного KeyProvider. Дополнительные провайдеры
+// используются только для расшифровки ранее созданных секретов.
 type EncryptionService struct {
-	key []byte
+	provider    KeyProvider
+	decryptOnly []KeyProvider
 }
 
 // EncryptedData содержит зашифрованные данные и IV
 type EncryptedData struct {
-	Ciphertext string // base64-закодированный шифртекст
-	IV         string // base64-закодированный initialization vector
+	Ciphertext string // Версионированный шифртекст ("v3:" + base64) или base64 для старых записей
+	IV         string // base64-закодированный nonce (AEAD) или initialization vector (CBC)
+	KeyID      string // ID мастер-ключа, которым обёрнут ключ данных (или зашифрованы данные)
+	WrappedKey string // Обёрнутый ключ данных (пусто для записей, зашифрованных мастер-ключом напрямую)
 }
 
-// NewEncryptionService создаёт новый сервис шифрования с заданным ключом
-func NewEncryptionService(key string) (*EncryptionService, error) {
-	keyBytes := []byte(key)
-
-	// Проверяем длину ключа для AES-128 (16 байт)
-	if len(keyBytes) != 16 {
-		return nil, fmt.Errorf("encryption key must be exactly 16 bytes for AES-128, got %d bytes", len(keyBytes))
+// NewEncryptionService создаёт новый сервис шифрования с активным провайдером
+// мастер-ключей и провайдерами, ключи которых используются только для расшифровки
+func NewEncryptionService(provider KeyProvider, decryptOnly ...KeyProvider) *EncryptionService {
+	return &EncryptionService{
+		provider:    provider,
+		decryptOnly: decryptOnly,
 	}
+}
 
-	return &EncryptionService{
-		key: keyBytes,
-	}, nil
+// ActiveKeyID возвращает ID мастер-ключа, которым оборачиваются новые ключи данных
+func (e *EncryptionService) ActiveKeyID() string {
+	return e.provider.ActiveKeyID()
 }
 
-// Encrypt шифрует plaintext с использованием AES-128-CBC
-func (e *EncryptionService) Encrypt(plaintext string) (*EncryptedData, error) {
-	plaintextBytes := []byte(plaintext)
+// Encrypt шифрует plaintext случайным ключом данных с использованием AES-256-GCM
+// и оборачивает ключ данных активным мастер-ключом.
+// associatedData (ID секрета) не шифруется, но связывается с шифртекстом:
+// расшифровать данные с другим associatedData не получится.
+func (e *EncryptionService) Encrypt(ctx context.Context, plaintext, associatedData string) (*EncryptedData, error) {
+	// Генерируем ключ данных для секрета
+	dataKey := make([]byte, dataKeySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	defer clear(dataKey)
 
-	// Создаём AES cipher block
-	block, err := aes.NewCipher(e.key)
+	aead, err := newGCM(dataKey)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create cipher: %w", err)
+		return nil, err
 	}
 
-	// Добавляем PKCS7 padding
-	plaintextBytes = pkcs7Pad(plaintextBytes, aes.BlockSize)
-
-	// Генерируем случайный IV (initialization vector)
-	iv := make([]byte, aes.BlockSize)
-	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
-		return nil, fmt.Errorf("failed to generate IV: %w", err)
+	// Генерируем случайный nonce
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
 	}
 
-	// Создаём CBC mode encrypter
-	mode := cipher.NewCBCEncrypter(block, iv)
+	// Шифруем данные и добавляем тег аутентификации
+	ciphertext := aead.Seal(nil, nonce, []byte(plaintext), []byte(associatedData))
 
-	// Шифруем данные
-	ciphertext := make([]byte, len(plaintextBytes))
-	mode.CryptBlocks(ciphertext, plaintextBytes)
+	// Оборачиваем ключ данных мастер-ключом
+	keyID, wrappedKey, err := e.provider.WrapKey(ctx, dataKey, associatedData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap data key: %w", err)
+	}
 
-	// Кодируем в base64 для хранения
+	// Кодируем в base64 для хранения и добавляем версию формата
 	return &EncryptedData{
-		Ciphertext: base64.StdEncoding.EncodeToString(ciphertext),
-		IV:         base64.StdEncoding.EncodeToString(iv),
+		Ciphertext: envelopeVersionDataKey + envelopeSeparator + base64.StdEncoding.EncodeToString(ciphertext),
+		IV:         base64.StdEncoding.EncodeToString(nonce),
+		KeyID:      keyID,
+		WrappedKey: wrappedKey,
 	}, nil
 }
 
-// Decrypt расшифровывает данные с использованием AES-128-CBC
-func (e *EncryptionService) Decrypt(encrypted *EncryptedData) (string, error) {
-	// Декодируем из base64
-	ciphertext, err := base64.StdEncoding.DecodeString(encrypted.Ciphertext)
-	if err != nil {
-		return "", fmt.Errorf("failed to decode ciphertext: %w", err)
+// Decrypt расшифровывает данные. Формат определяется по версии шифртекста:
+// ключ данных секрета для новых записей, мастер-ключ напрямую (AES-256-GCM
+// или AES-128-CBC) для записей, созданных до перехода на envelope-шифрование.
+// Мастер-ключ выбирается по encrypted.KeyID (пустой ID соответствует DefaultKeyID).
+func (e *EncryptionService) Decrypt(ctx context.Context, encrypted *EncryptedData, associatedData string) (string, error) {
+	data := *encrypted
+	if data.KeyID == "" {
+		data.KeyID = DefaultKeyID
 	}
 
-	iv, err := base64.StdEncoding.DecodeString(encrypted.IV)
+	provider, err := e.providerFor(data.KeyID)
 	if err != nil {
-		return "", fmt.Errorf("failed to decode IV: %w", err)
+		return "", err
+	}
+
+	version, payload := splitEnvelope(data.Ciphertext)
+
+	switch version {
+	case envelopeVersionDataKey:
+		dataKey, err := provider.UnwrapKey(ctx, data.KeyID, data.WrappedKey, associatedData)
+		if err != nil {
+			return "", fmt.Errorf("failed to unwrap data key: %w", err)
+		}
+		defer clear(dataKey)
+
+		aead, err := newGCM(dataKey)
+		if err != nil {
+			return "", err
+		}
+
+		return openGCM(aead, payload, data.IV, associatedData)
+
+	case envelopeVersionGCM, "":
+		// Записи, зашифрованные мастер-ключом напрямую, может расшифровать только локальная связка ключей
+		local, ok := provider.(*LocalKeyProvider)
+		if !ok {
+			return "", fmt.Errorf("key %q cannot decrypt records encrypted directly with a master key", data.KeyID)
+		}
+
+		return local.decryptDirect(&data, version, payload, associatedData)
+
+	default:
+		return "", fmt.Errorf("unsupported ciphertext version: %q", version)
+	}
+}
+
+// Reencrypt переводит данные на активный мастер-ключ. Для записей с ключом
+// данных перешифровывается только ключ данных, остальные записи
+// расшифровываются и шифруются заново.
+func (e *EncryptionService) Reencrypt(ctx context.Context, encrypted *EncryptedData, associatedData string) (*EncryptedData, error) {
+	if version, _ := splitEnvelope(encrypted.Ciphertext); version != envelopeVersionDataKey {
+		plaintext, err := e.Decrypt(ctx, encrypted, associatedData)
+		if err != nil {
+			return nil, err
+		}
+		return e.Encrypt(ctx, plaintext, associatedData)
 	}
 
-	// Создаём AES cipher block
-	block, err := aes.NewCipher(e.key)
+	provider, err := e.providerFor(encrypted.KeyID)
 	if err != nil {
-		return "", fmt.Errorf("failed to create cipher: %w", err)
+		return nil, err
 	}
 
-	// Проверяем длину IV
-	if len(iv) != aes.BlockSize {
-		return "", fmt.Errorf("invalid IV length: %d", len(iv))
+	dataKey, err := provider.UnwrapKey(ctx, encrypted.KeyID, encrypted.WrappedKey, associatedData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
 	}
+	defer clear(dataKey)
 
-	// Проверяем, что ciphertext кратен размеру блока
-	if len(ciphertext)%aes.BlockSize != 0 {
-		return "", fmt.Errorf("ciphertext is not a multiple of block size")
+	keyID, wrappedKey, err := e.provider.WrapKey(ctx, dataKey, associatedData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap data key: %w", err)
 	}
 
-	// Создаём CBC mode decrypter
-	mode := cipher.NewCBCDecrypter(block, iv)
+	return &EncryptedData{
+		Ciphertext: encrypted.Ciphertext,
+		IV:         encrypted.IV,
+		KeyID:      keyID,
+		WrappedKey: wrappedKey,
+	}, nil
+}
 
-	// Расшифровываем данные
-	plaintext := make([]byte, len(ciphertext))
-	mode.CryptBlocks(plaintext, ciphertext)
+// providerFor возвращает провайдер, которому принадлежит мастер-ключ keyID
+func (e *EncryptionService) providerFor(keyID string) (KeyProvider, error) {
+	if e.provider.HasKey(keyID) {
+		return e.provider, nil
+	}
 
-	// Убираем PKCS7 padding
-	plaintext, err = pkcs7Unpad(plaintext, aes.BlockSize)
-	if err != nil {
-		return "", fmt.Errorf("failed to unpad: %w", err)
+	for _, provider := range e.decryptOnly {
+		if provider.HasKey(keyID) {
+			return provider, nil
+		}
 	}
 
-	return string(plaintext), nil
+	return nil, fmt.Errorf("%w: %q", ErrUnknownKey, keyID)
 }
 
-// pkcs7Pad добавляет PKCS7 padding к данным
-func pkcs7Pad(data []byte, blockSize int) []byte {
-	padding := blockSize - (len(data) % blockSize)
-	padText := make([]byte, padding)
-	for i := range padText {
-		padText[i] = byte(padding)
+// splitEnvelope отделяет версию формата от шифртекста (пустая версия для AES-128-CBC)
+func splitEnvelope(ciphertext string) (version, payload string) {
+	version, payload, found := strings.Cut(ciphertext, envelopeSeparator)
+	if !found {
+		return "", ciphertext
 	}
-	return append(data, padText...)
+	return version, payload
 }
 
-// pkcs7Unpad убирает PKCS7 padding из данных
-func pkcs7Unpad(data []byte, blockSize int) ([]byte, error) {
-	length := len(data)
-	if length == 0 {
-		return nil, fmt.Errorf("data is empty")
+// newGCM создаёт AES-256-GCM для ключа
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
 	}
 
-	if length%blockSize != 0 {
-		return nil, fmt.Errorf("data length is not a multiple of block size")
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
 	}
 
-	padding := int(data[length-1])
-	if padding > blockSize || padding == 0 {
-		return nil, fmt.Errorf("invalid padding")
+	return aead, nil
+}
+
+// openGCM расшифровывает base64-закодированный шифртекст AEAD и проверяет тег аутентификации
+func openGCM(aead cipher.AEAD, payload, iv, associatedData string) (string, error) {
+	// Декодируем из base64
+	ciphertext, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode ciphertext: %w", err)
 	}
 
-	// Проверяем, что все байты padding имеют правильное значение
-	for i := length - padding; i < length; i++ {
-		if data[i] != byte(padding) {
-			return nil, fmt.Errorf("invalid padding")
-		}
+	nonce, err := base64.StdEncoding.DecodeString(iv)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode nonce: %w", err)
+	}
+
+	// Проверяем длину nonce
+	if len(nonce) != aead.NonceSize() {
+		return "", fmt.Errorf("invalid nonce length: %d", len(nonce))
 	}
 
-	return data[:length-padding], nil
+	// Расшифровываем и проверяем тег аутентификации
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(associatedData))
+	if err != nil {
+		return "", ErrAuthenticationFailed
+	}
+
+	return string(plaintext), nil
 }
@@ -0,0 +1,204 @@
+package crypto
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"io"
+	"testing"
+)
+
+const (
+	testKey16 = "0123456789abcdef"
+	testKey32 = "0123456789abcdef0123456789abcdef"
+)
+
+func newTestEncryptionService(t *testing.T, keys map[string]string, activeKeyID string) (*EncryptionService, *LocalKeyProvider) {
+	t.Helper()
+	provider, err := NewLocalKeyProvider(keys, activeKeyID)
+	if err != nil {
+		t.Fatalf("NewLocalKeyProvider: %v", err)
+	}
+	return NewEncryptionService(provider), provider
+}
+
+// encryptLegacyCBC шифрует данные так, как это делали версии до перехода на AEAD
+func encryptLegacyCBC(t *testing.T, key, plaintext string) *EncryptedData {
+	t.Helper()
+	block, err := aes.NewCipher([]byte(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	padding := aes.BlockSize - len(plaintext)%aes.BlockSize
+	padded := append([]byte(plaintext), bytes.Repeat([]byte{byte(padding)}, padding)...)
+
+	iv := make([]byte, aes.BlockSize)
+	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
+		t.Fatal(err)
+	}
+	ciphertext := make([]byte, len(padded))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, padded)
+
+	return &EncryptedData{
+		Ciphertext: base64.StdEncoding.EncodeToString(ciphertext),
+		IV:         base64.StdEncoding.EncodeToString(iv),
+	}
+}
+
+// encryptDirectGCM шифрует данные мастер-ключом напрямую в формате "v2"
+func encryptDirectGCM(t *testing.T, provider *LocalKeyProvider, keyID, plaintext, associatedData string) *EncryptedData {
+	t.Helper()
+	aead := provider.keys[keyID].aead
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		t.Fatal(err)
+	}
+	ciphertext := aead.Seal(nil, nonce, []byte(plaintext), []byte(associatedData))
+
+	return &EncryptedData{
+		Ciphertext: envelopeVersionGCM + envelopeSeparator + base64.StdEncoding.EncodeToString(ciphertext),
+		IV:         base64.StdEncoding.EncodeToString(nonce),
+		KeyID:      keyID,
+	}
+}
+
+// flipCiphertextByte меняет один бит шифртекста, сохраняя версию формата
+func flipCiphertextByte(t *testing.T, encrypted *EncryptedData) *EncryptedData {
+	t.Helper()
+	version, payload := splitEnvelope(encrypted.Ciphertext)
+	raw, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw[0] ^= 1
+
+	flipped := *encrypted
+	flipped.Ciphertext = version + envelopeSeparator + base64.StdEncoding.EncodeToString(raw)
+	return &flipped
+}
+
+func TestDecryptLegacyCBC(t *testing.T) {
+	service, _ := newTestEncryptionService(t, map[string]string{DefaultKeyID: testKey16}, DefaultKeyID)
+
+	for _, plaintext := range []string{"a", "exactly 16 bytes", "секрет длиннее одного блока AES"} {
+		encrypted := encryptLegacyCBC(t, testKey16, plaintext)
+
+		// Старые записи хранятся без ID ключа
+		decrypted, err := service.Decrypt(context.Background(), encrypted, "secret-1")
+		if err != nil {
+			t.Errorf("Decrypt(%q): %v", plaintext, err)
+			continue
+		}
+		if decrypted != plaintext {
+			t.Errorf("Decrypt = %q, want %q", decrypted, plaintext)
+		}
+	}
+}
+
+func TestDecryptLegacyCBCRequires16ByteKey(t *testing.T) {
+	service, _ := newTestEncryptionService(t, map[string]string{DefaultKeyID: testKey32}, DefaultKeyID)
+
+	encrypted := encryptLegacyCBC(t, testKey16, "secret")
+	if _, err := service.Decrypt(context.Background(), encrypted, "secret-1"); err == nil {
+		t.Errorf("Decrypt succeeded without a 16-byte key")
+	}
+}
+
+func TestDecryptDirectGCM(t *testing.T) {
+	for _, key := range []string{testKey16, testKey32} {
+		service, provider := newTestEncryptionService(t, map[string]string{"k1": key}, "k1")
+		encrypted := encryptDirectGCM(t, provider, "k1", "секрет", "secret-1")
+
+		decrypted, err := service.Decrypt(context.Background(), encrypted, "secret-1")
+		if err != nil || decrypted != "секрет" {
+			t.Errorf("key of %d bytes: Decrypt = %q, %v", len(key), decrypted, err)
+		}
+	}
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	service, _ := newTestEncryptionService(t, map[string]string{"k1": testKey32}, "k1")
+	ctx := context.Background()
+
+	encrypted, err := service.Encrypt(ctx, "секрет", "secret-1")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if version, _ := splitEnvelope(encrypted.Ciphertext); version != envelopeVersionDataKey {
+		t.Errorf("ciphertext version = %q, want %q", version, envelopeVersionDataKey)
+	}
+	if encrypted.KeyID != "k1" || encrypted.WrappedKey == "" {
+		t.Errorf("KeyID = %q, WrappedKey = %q", encrypted.KeyID, encrypted.WrappedKey)
+	}
+
+	decrypted, err := service.Decrypt(ctx, encrypted, "secret-1")
+	if err != nil || decrypted != "секрет" {
+		t.Errorf("Decrypt = %q, %v", decrypted, err)
+	}
+
+	// Один и тот же текст шифруется по-разному
+	again, err := service.Encrypt(ctx, "секрет", "secret-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Ciphertext == encrypted.Ciphertext || again.WrappedKey == encrypted.WrappedKey {
+		t.Errorf("repeated encryption produced identical ciphertext or wrapped key")
+	}
+}
+
+func TestDecryptRejectsTamperedData(t *testing.T) {
+	service, provider := newTestEncryptionService(t, map[string]string{"k1": testKey32}, "k1")
+	ctx := context.Background()
+
+	envelope, err := service.Encrypt(ctx, "секрет", "secret-1")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	direct := encryptDirectGCM(t, provider, "k1", "секрет", "secret-1")
+
+	for name, encrypted := range map[string]*EncryptedData{"v3": envelope, "v2": direct} {
+		if _, err := service.Decrypt(ctx, flipCiphertextByte(t, encrypted), "secret-1"); !errors.Is(err, ErrAuthenticationFailed) {
+			t.Errorf("%s, flipped ciphertext byte: err = %v, want ErrAuthenticationFailed", name, err)
+		}
+
+		if _, err := service.Decrypt(ctx, encrypted, "secret-2"); !errors.Is(err, ErrAuthenticationFailed) {
+			t.Errorf("%s, other secret ID: err = %v, want ErrAuthenticationFailed", name, err)
+		}
+
+		truncated := *encrypted
+		truncated.Ciphertext = encrypted.Ciphertext[:len(encrypted.Ciphertext)-8]
+		if _, err := service.Decrypt(ctx, &truncated, "secret-1"); err == nil {
+			t.Errorf("%s, truncated ciphertext: Decrypt succeeded", name)
+		}
+	}
+
+	// Обёрнутый ключ данных из другой записи не подходит
+	other, err := service.Encrypt(ctx, "другой секрет", "secret-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	swapped := *envelope
+	swapped.WrappedKey = other.WrappedKey
+	if _, err := service.Decrypt(ctx, &swapped, "secret-1"); !errors.Is(err, ErrAuthenticationFailed) {
+		t.Errorf("swapped wrapped key: err = %v, want ErrAuthenticationFailed", err)
+	}
+}
+
+func TestDecryptUnknownKey(t *testing.T) {
+	service, _ := newTestEncryptionService(t, map[string]string{"k1": testKey32}, "k1")
+
+	encrypted, err := service.Encrypt(context.Background(), "секрет", "secret-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted.KeyID = "retired"
+
+	if _, err := service.Decrypt(context.Background(), encrypted, "secret-1"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("err = %v, want ErrUnknownKey", err)
+	}
+}
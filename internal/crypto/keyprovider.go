@@ -0,0 +1,309 @@
+package crypto
+
+import (
+	"bufio"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// gcmKeyInfo используется при выводе ключа AES-256 из 16-байтного мастер-ключа
+const gcmKeyInfo = "ares-api/aes-256-gcm"
+
+// boundKeyPrefix отмечает обёрнутые ключи данных, связанные с ID мастер-ключа
+// и ID секрета. Ключи без префикса не принимаются: их можно было бы перенести
+// в другую запись.
+const boundKeyPrefix = "aad:"
+
+// KeyProvider предоставляет мастер-ключи, которыми оборачиваются (шифруются)
+// ключи данных отдельных секретов
+type KeyProvider interface {
+	// ActiveKeyID возвращает ID мастер-ключа, которым оборачиваются новые ключи данных
+	ActiveKeyID() string
+
+	// HasKey сообщает, может ли провайдер развернуть ключ данных, обёрнутый ключом keyID
+	HasKey(keyID string) bool
+
+	// WrapKey шифрует ключ данных активным мастер-ключом. Обёрнутый ключ связывается
+	// с ID мастер-ключа и associatedData (ID секрета): подставить его в другую запись не получится.
+	WrapKey(ctx context.Context, dataKey []byte, associatedData string) (keyID, wrappedKey string, err error)
+
+	// UnwrapKey расшифровывает ключ данных, обёрнутый мастер-ключом keyID
+	UnwrapKey(ctx context.Context, keyID, wrappedKey, associatedData string) ([]byte, error)
+}
+
+// wrapAssociatedData возвращает связанные данные обёрнутого ключа: ID мастер-ключа и ID секрета
+func wrapAssociatedData(keyID, associatedData string) []byte {
+	return []byte(keyID + "\x00" + associatedData)
+}
+
+// LocalKeyProvider хранит связку мастер-ключей в памяти процесса.
+// Новые ключи данных оборачиваются активным ключом, остальные ключи
+// используются только для разворачивания.
+type LocalKeyProvider struct {
+	keys        map[string]*keyMaterial
+	activeKeyID string
+}
+
+// keyMaterial содержит подготовленный ключ из связки
+type keyMaterial struct {
+	aead      cipher.AEAD
+	legacyKey []byte // Ключ AES-128-CBC для расшифровки старых записей (nil, если недоступен)
+}
+
+// NewLocalKeyProvider создаёт провайдер со связкой ключей (ID ключа -> ключ)
+// и ID активного ключа.
+// Ключ длиной 32 байта используется для AES-256-GCM напрямую; из ключа длиной
+// 16 байт ключ AES-256-GCM выводится через HKDF-SHA256, а сам ключ остаётся
+// доступным для расшифровки записей, созданных до перехода на AEAD.
+func NewLocalKeyProvider(keys map[string]string, activeKeyID string) (*LocalKeyProvider, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("at least one encryption key is required")
+	}
+
+	if _, ok := keys[activeKeyID]; !ok {
+		return nil, fmt.Errorf("active encryption key %q is not in the key ring", activeKeyID)
+	}
+
+	provider := &LocalKeyProvider{
+		keys:        make(map[string]*keyMaterial, len(keys)),
+		activeKeyID: activeKeyID,
+	}
+
+	for id, key := range keys {
+		material, err := newKeyMaterial([]byte(key))
+		if err != nil {
+			return nil, fmt.Errorf("invalid encryption key %q: %w", id, err)
+		}
+		provider.keys[id] = material
+	}
+
+	return provider, nil
+}
+
+// NewFileKeyProvider создаёт локальный провайдер со связкой ключей из файла.
+// Формат файла - по одному ключу в строке в виде "id:key", строки,
+// начинающиеся с "#", игнорируются. Если activeKeyID пуст, а ключ в файле
+// один, он становится активным.
+func NewFileKeyProvider(path, activeKeyID string) (*LocalKeyProvider, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open key file: %w", err)
+	}
+	defer file.Close()
+
+	keys := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		id, key, found := strings.Cut(line, ":")
+		if !found || id == "" || key == "" {
+			return nil, fmt.Errorf("key file entries must have the form id:key")
+		}
+
+		if _, exists := keys[id]; exists {
+			return nil, fmt.Errorf("duplicate key ID %q in key file", id)
+		}
+		keys[id] = key
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	if activeKeyID == "" {
+		if len(keys) != 1 {
+			return nil, fmt.Errorf("active key ID is required when the key file contains %d keys", len(keys))
+		}
+		for id := range keys {
+			activeKeyID = id
+		}
+	}
+
+	return NewLocalKeyProvider(keys, activeKeyID)
+}
+
+// newKeyMaterial подготавливает AEAD (и при необходимости ключ CBC) для ключа
+func newKeyMaterial(keyBytes []byte) (*keyMaterial, error) {
+	var gcmKey, legacyKey []byte
+	switch len(keyBytes) {
+	case 16:
+		derived, err := hkdf.Key(sha256.New, keyBytes, nil, gcmKeyInfo, 32)
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive encryption key: %w", err)
+		}
+		gcmKey = derived
+		legacyKey = keyBytes
+	case 32:
+		gcmKey = keyBytes
+	default:
+		return nil, fmt.Errorf("encryption key must be exactly 16 or 32 bytes, got %d bytes", len(keyBytes))
+	}
+
+	aead, err := newGCM(gcmKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &keyMaterial{
+		aead:      aead,
+		legacyKey: legacyKey,
+	}, nil
+}
+
+// ActiveKeyID возвращает ID ключа, которым оборачиваются новые ключи данных
+func (p *LocalKeyProvider) ActiveKeyID() string {
+	return p.activeKeyID
+}
+
+// HasKey сообщает, есть ли ключ keyID в связке
+func (p *LocalKeyProvider) HasKey(keyID string) bool {
+	_, ok := p.keys[keyID]
+	return ok
+}
+
+// WrapKey шифрует ключ данных активным ключом (AES-256-GCM, nonce + шифртекст в base64)
+func (p *LocalKeyProvider) WrapKey(ctx context.Context, dataKey []byte, associatedData string) (string, string, error) {
+	key := p.keys[p.activeKeyID]
+
+	nonce := make([]byte, key.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	wrapped := key.aead.Seal(nonce, nonce, dataKey, wrapAssociatedData(p.activeKeyID, associatedData))
+	return p.activeKeyID, boundKeyPrefix + base64.StdEncoding.EncodeToString(wrapped), nil
+}
+
+// UnwrapKey расшифровывает ключ данных ключом keyID, проверяя его связь с
+// keyID и ID секрета
+func (p *LocalKeyProvider) UnwrapKey(ctx context.Context, keyID, wrappedKey, associatedData string) ([]byte, error) {
+	key, ok := p.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, keyID)
+	}
+
+	payload, bound := strings.CutPrefix(wrappedKey, boundKeyPrefix)
+	if !bound {
+		return nil, fmt.Errorf("%w: wrapped key is not bound to the secret", ErrAuthenticationFailed)
+	}
+
+	wrapped, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode wrapped key: %w", err)
+	}
+
+	nonceSize := key.aead.NonceSize()
+	if len(wrapped) < nonceSize {
+		return nil, fmt.Errorf("wrapped key is too short")
+	}
+
+	dataKey, err := key.aead.Open(nil, wrapped[:nonceSize], wrapped[nonceSize:], wrapAssociatedData(keyID, associatedData))
+	if err != nil {
+		return nil, ErrAuthenticationFailed
+	}
+
+	return dataKey, nil
+}
+
+// decryptDirect расшифровывает записи, зашифрованные мастер-ключом напрямую
+// (до перехода на envelope-шифрование): AES-256-GCM ("v2") или AES-128-CBC
+func (p *LocalKeyProvider) decryptDirect(encrypted *EncryptedData, version, payload, associatedData string) (string, error) {
+	key, ok := p.keys[encrypted.KeyID]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownKey, encrypted.KeyID)
+	}
+
+	if version == "" {
+		return key.decryptLegacy(encrypted)
+	}
+
+	return openGCM(key.aead, payload, encrypted.IV, associatedData)
+}
+
+// decryptLegacy расшифровывает записи, созданные до перехода на AEAD (AES-128-CBC)
+func (k *keyMaterial) decryptLegacy(encrypted *EncryptedData) (string, error) {
+	if k.legacyKey == nil {
+		return "", fmt.Errorf("legacy AES-128-CBC ciphertext requires a 16-byte encryption key")
+	}
+
+	// Декодируем из base64
+	ciphertext, err := base64.StdEncoding.DecodeString(encrypted.Ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode ciphertext: %w", err)
+	}
+
+	iv, err := base64.StdEncoding.DecodeString(encrypted.IV)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode IV: %w", err)
+	}
+
+	// Создаём AES cipher block
+	block, err := aes.NewCipher(k.legacyKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	// Проверяем длину IV
+	if len(iv) != aes.BlockSize {
+		return "", fmt.Errorf("invalid IV length: %d", len(iv))
+	}
+
+	// Проверяем, что ciphertext кратен размеру блока
+	if len(ciphertext) == 0 || len(ciphertext)%aes.BlockSize != 0 {
+		return "", fmt.Errorf("ciphertext is not a multiple of block size")
+	}
+
+	// Создаём CBC mode decrypter
+	mode := cipher.NewCBCDecrypter(block, iv)
+
+	// Расшифровываем данные
+	plaintext := make([]byte, len(ciphertext))
+	mode.CryptBlocks(plaintext, ciphertext)
+
+	// Убираем PKCS7 padding
+	plaintext, err = pkcs7Unpad(plaintext, aes.BlockSize)
+	if err != nil {
+		return "", fmt.Errorf("failed to unpad: %w", err)
+	}
+
+	return string(plaintext), nil
+}
+
+// pkcs7Unpad убирает PKCS7 padding из данных
+func pkcs7Unpad(data []byte, blockSize int) ([]byte, error) {
+	length := len(data)
+	if length == 0 {
+		return nil, fmt.Errorf("data is empty")
+	}
+
+	if length%blockSize != 0 {
+		return nil, fmt.Errorf("data length is not a multiple of block size")
+	}
+
+	padding := int(data[length-1])
+	if padding > blockSize || padding == 0 {
+		return nil, fmt.Errorf("invalid padding")
+	}
+
+	// Проверяем, что все байты padding имеют правильное значение
+	for i := length - padding; i < length; i++ {
+		if data[i] != byte(padding) {
+			return nil, fmt.Errorf("invalid padding")
+		}
+	}
+
+	return data[:length-padding], nil
+}
@@ -0,0 +1,193 @@
+package crypto
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocalKeyProviderBindsWrappedKey(t *testing.T) {
+	provider, err := NewLocalKeyProvider(map[string]string{
+		"k1": "0123456789abcdef0123456789abcdef",
+		"k2": "fedcba9876543210fedcba9876543210",
+	}, "k1")
+	if err != nil {
+		t.Fatalf("NewLocalKeyProvider: %v", err)
+	}
+	ctx := context.Background()
+
+	dataKey := []byte("data-key-data-key-data-key-data!")
+	keyID, wrapped, err := provider.WrapKey(ctx, dataKey, "secret-1")
+	if err != nil {
+		t.Fatalf("WrapKey: %v", err)
+	}
+	if !strings.HasPrefix(wrapped, boundKeyPrefix) {
+		t.Errorf("wrapped key %q has no bound prefix", wrapped)
+	}
+
+	unwrapped, err := provider.UnwrapKey(ctx, keyID, wrapped, "secret-1")
+	if err != nil || string(unwrapped) != string(dataKey) {
+		t.Fatalf("UnwrapKey = %q, %v, want %q", unwrapped, err, dataKey)
+	}
+
+	// Перенос в другую запись или под другой ID ключа обнаруживается
+	if _, err := provider.UnwrapKey(ctx, keyID, wrapped, "secret-2"); !errors.Is(err, ErrAuthenticationFailed) {
+		t.Errorf("UnwrapKey with another secret ID: err = %v, want ErrAuthenticationFailed", err)
+	}
+
+	// Снятие префикса не позволяет развернуть ключ без связанных данных
+	if _, err := provider.UnwrapKey(ctx, keyID, strings.TrimPrefix(wrapped, boundKeyPrefix), "secret-1"); !errors.Is(err, ErrAuthenticationFailed) {
+		t.Errorf("UnwrapKey without prefix: err = %v, want ErrAuthenticationFailed", err)
+	}
+}
+
+func TestLocalKeyProviderRejectsUnboundKey(t *testing.T) {
+	provider, err := NewLocalKeyProvider(map[string]string{"k1": "0123456789abcdef0123456789abcdef"}, "k1")
+	if err != nil {
+		t.Fatalf("NewLocalKeyProvider: %v", err)
+	}
+
+	// Ключ, обёрнутый без связанных данных и без префикса
+	aead := provider.keys["k1"].aead
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		t.Fatal(err)
+	}
+	unbound := base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte("data-key"), nil))
+
+	for name, wrapped := range map[string]string{"no prefix": unbound, "prefix": boundKeyPrefix + unbound} {
+		if _, err := provider.UnwrapKey(context.Background(), "k1", wrapped, "secret-1"); !errors.Is(err, ErrAuthenticationFailed) {
+			t.Errorf("%s: err = %v, want ErrAuthenticationFailed", name, err)
+		}
+	}
+}
+
+func TestKeyRingDecryptsWithNonActiveKey(t *testing.T) {
+	ctx := context.Background()
+	keys := map[string]string{
+		"2024": "0123456789abcdef0123456789abcdef",
+		"2025": "fedcba9876543210fedcba9876543210",
+	}
+
+	// Секрет записан, когда активным был ключ 2024
+	before, _ := newTestEncryptionService(t, keys, "2024")
+	encrypted, err := before.Encrypt(ctx, "секрет", "secret-1")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if encrypted.KeyID != "2024" {
+		t.Fatalf("KeyID = %q, want 2024", encrypted.KeyID)
+	}
+
+	// После ротации ключ 2024 используется только для расшифровки
+	after, _ := newTestEncryptionService(t, keys, "2025")
+	decrypted, err := after.Decrypt(ctx, encrypted, "secret-1")
+	if err != nil || decrypted != "секрет" {
+		t.Errorf("Decrypt = %q, %v", decrypted, err)
+	}
+
+	fresh, err := after.Encrypt(ctx, "новый", "secret-2")
+	if err != nil || fresh.KeyID != "2025" {
+		t.Errorf("Encrypt after rotation: KeyID = %q, %v, want 2025", fresh.KeyID, err)
+	}
+
+	// Ключ, выведенный из связки, больше не расшифровывает записи
+	retired, _ := newTestEncryptionService(t, map[string]string{"2025": keys["2025"]}, "2025")
+	if _, err := retired.Decrypt(ctx, encrypted, "secret-1"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Decrypt with retired key: err = %v, want ErrUnknownKey", err)
+	}
+}
+
+func TestKeyRingDecryptOnlyProvider(t *testing.T) {
+	ctx := context.Background()
+
+	old, oldProvider := newTestEncryptionService(t, map[string]string{"old": testKey32}, "old")
+	encrypted, err := old.Encrypt(ctx, "секрет", "secret-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	active, err := NewLocalKeyProvider(map[string]string{"new": "fedcba9876543210fedcba9876543210"}, "new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := NewEncryptionService(active, oldProvider)
+
+	if decrypted, err := service.Decrypt(ctx, encrypted, "secret-1"); err != nil || decrypted != "секрет" {
+		t.Errorf("Decrypt = %q, %v", decrypted, err)
+	}
+
+	// Перешифрование переводит запись на активный ключ
+	reencrypted, err := service.Reencrypt(ctx, encrypted, "secret-1")
+	if err != nil {
+		t.Fatalf("Reencrypt: %v", err)
+	}
+	if reencrypted.KeyID != "new" || reencrypted.Ciphertext != encrypted.Ciphertext {
+		t.Errorf("Reencrypt: KeyID = %q, ciphertext changed = %t", reencrypted.KeyID, reencrypted.Ciphertext != encrypted.Ciphertext)
+	}
+	onlyNew := NewEncryptionService(active)
+	if decrypted, err := onlyNew.Decrypt(ctx, reencrypted, "secret-1"); err != nil || decrypted != "секрет" {
+		t.Errorf("Decrypt after Reencrypt = %q, %v", decrypted, err)
+	}
+}
+
+func TestReencryptDirectRecords(t *testing.T) {
+	ctx := context.Background()
+	service, provider := newTestEncryptionService(t, map[string]string{
+		DefaultKeyID: testKey16,
+		"new":        testKey32,
+	}, "new")
+
+	for name, encrypted := range map[string]*EncryptedData{
+		"cbc": encryptLegacyCBC(t, testKey16, "секрет"),
+		"v2":  encryptDirectGCM(t, provider, DefaultKeyID, "секрет", "secret-1"),
+	} {
+		reencrypted, err := service.Reencrypt(ctx, encrypted, "secret-1")
+		if err != nil {
+			t.Errorf("%s: Reencrypt: %v", name, err)
+			continue
+		}
+		if version, _ := splitEnvelope(reencrypted.Ciphertext); version != envelopeVersionDataKey || reencrypted.KeyID != "new" {
+			t.Errorf("%s: version %q, key %q, want envelope under the active key", name, version, reencrypted.KeyID)
+		}
+		if decrypted, err := service.Decrypt(ctx, reencrypted, "secret-1"); err != nil || decrypted != "секрет" {
+			t.Errorf("%s: Decrypt = %q, %v", name, decrypted, err)
+		}
+	}
+}
+
+func TestNewFileKeyProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys")
+	content := "# ключи шифрования\n2024:0123456789abcdef0123456789abcdef\n\n2025:fedcba9876543210\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	provider, err := NewFileKeyProvider(path, "2025")
+	if err != nil {
+		t.Fatalf("NewFileKeyProvider: %v", err)
+	}
+	if provider.ActiveKeyID() != "2025" || !provider.HasKey("2024") || provider.HasKey("2023") {
+		t.Errorf("active key %q, has 2024 = %t", provider.ActiveKeyID(), provider.HasKey("2024"))
+	}
+
+	// Без активного ключа файл с несколькими ключами не принимается
+	if _, err := NewFileKeyProvider(path, ""); err == nil {
+		t.Errorf("NewFileKeyProvider accepted several keys without an active key")
+	}
+	if _, err := NewFileKeyProvider(path, "2023"); err == nil {
+		t.Errorf("NewFileKeyProvider accepted an active key missing from the file")
+	}
+
+	duplicate := filepath.Join(t.TempDir(), "keys")
+	os.WriteFile(duplicate, []byte("k:0123456789abcdef\nk:fedcba9876543210\n"), 0o600)
+	if _, err := NewFileKeyProvider(duplicate, "k"); err == nil {
+		t.Errorf("NewFileKeyProvider accepted a duplicate key ID")
+	}
+}
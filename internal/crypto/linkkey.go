@@ -0,0 +1,80 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// linkKeyVersion - версия формата данных, зашифрованных ключом из ссылки
+const linkKeyVersion = "k1"
+
+// ErrInvalidLinkKey возвращается, когда ключ из ссылки имеет неверный формат
+var ErrInvalidLinkKey = errors.New("invalid link key")
+
+// SealWithLinkKey шифрует plaintext одноразовым случайным ключом (AES-256-GCM).
+// Ключ возвращается в base64url и не сохраняется сервером: он передаётся
+// получателю только во фрагменте ссылки (#...), поэтому дампа БД и
+// мастер-ключа недостаточно, чтобы прочитать секрет.
+func SealWithLinkKey(plaintext, associatedData string) (sealed, linkKey string, err error) {
+	key := make([]byte, dataKeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return "", "", fmt.Errorf("failed to generate link key: %w", err)
+	}
+	defer clear(key)
+
+	aead, err := newGCM(key)
+	if err != nil {
+		return "", "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	// Nonce хранится перед шифртекстом
+	ciphertext := aead.Seal(nonce, nonce, []byte(plaintext), []byte(associatedData))
+
+	sealed = linkKeyVersion + envelopeSeparator + base64.StdEncoding.EncodeToString(ciphertext)
+	return sealed, base64.RawURLEncoding.EncodeToString(key), nil
+}
+
+// OpenWithLinkKey расшифровывает данные, зашифрованные SealWithLinkKey.
+// Неверный ключ приводит к ErrAuthenticationFailed.
+func OpenWithLinkKey(sealed, linkKey, associatedData string) (string, error) {
+	key, err := base64.RawURLEncoding.DecodeString(linkKey)
+	if err != nil || len(key) != dataKeySize {
+		return "", ErrInvalidLinkKey
+	}
+	defer clear(key)
+
+	version, payload := splitEnvelope(sealed)
+	if version != linkKeyVersion {
+		return "", fmt.Errorf("unsupported link key ciphertext version: %q", version)
+	}
+
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode ciphertext: %w", err)
+	}
+
+	aead, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonceSize := aead.NonceSize()
+	if len(data) < nonceSize {
+		return "", fmt.Errorf("ciphertext is too short")
+	}
+
+	plaintext, err := aead.Open(nil, data[:nonceSize], data[nonceSize:], []byte(associatedData))
+	if err != nil {
+		return "", ErrAuthenticationFailed
+	}
+
+	return string(plaintext), nil
+}
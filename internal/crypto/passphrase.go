@@ -0,0 +1,139 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// passphraseVersion - версия формата данных, зашифрованных ключом из парольной фразы
+const passphraseVersion = "p1"
+
+// Ограничения параметров Argon2id, прочитанных из БД (защита от подменённых параметров)
+const (
+	argon2SaltSize   = 16
+	argon2MaxTime    = 10
+	argon2MaxMemory  = 256 * 1024
+	argon2MaxThreads = 16
+)
+
+// Argon2Params содержит параметры Argon2id
+type Argon2Params struct {
+	Time    uint32 // Количество проходов
+	Memory  uint32 // Память в KiB
+	Threads uint8  // Степень параллелизма
+}
+
+// DefaultArgon2Params - параметры Argon2id по рекомендациям OWASP
+var DefaultArgon2Params = Argon2Params{
+	Time:    2,
+	Memory:  19 * 1024,
+	Threads: 1,
+}
+
+// SealWithPassphrase шифрует plaintext ключом, выведенным из парольной фразы
+// через Argon2id со случайной солью. Возвращает шифртекст и строку параметров KDF
+// (алгоритм, параметры и соль), которую нужно сохранить для расшифровки.
+func SealWithPassphrase(plaintext, passphrase, associatedData string, params Argon2Params) (sealed, kdf string, err error) {
+	salt := make([]byte, argon2SaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return "", "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	key := argon2.IDKey([]byte(passphrase), salt, params.Time, params.Memory, params.Threads, dataKeySize)
+	defer clear(key)
+
+	aead, err := newGCM(key)
+	if err != nil {
+		return "", "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	// Nonce хранится перед шифртекстом
+	ciphertext := aead.Seal(nonce, nonce, []byte(plaintext), []byte(associatedData))
+
+	sealed = passphraseVersion + envelopeSeparator + base64.StdEncoding.EncodeToString(ciphertext)
+	kdf = fmt.Sprintf("argon2id$v=%d$m=%d,t=%d,p=%d$%s",
+		argon2.Version, params.Memory, params.Time, params.Threads, base64.RawStdEncoding.EncodeToString(salt))
+
+	return sealed, kdf, nil
+}
+
+// OpenWithPassphrase расшифровывает данные, зашифрованные SealWithPassphrase.
+// Неверная парольная фраза приводит к ErrAuthenticationFailed.
+func OpenWithPassphrase(sealed, passphrase, kdf, associatedData string) (string, error) {
+	params, salt, err := parseArgon2KDF(kdf)
+	if err != nil {
+		return "", err
+	}
+
+	version, payload := splitEnvelope(sealed)
+	if version != passphraseVersion {
+		return "", fmt.Errorf("unsupported passphrase ciphertext version: %q", version)
+	}
+
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode ciphertext: %w", err)
+	}
+
+	key := argon2.IDKey([]byte(passphrase), salt, params.Time, params.Memory, params.Threads, dataKeySize)
+	defer clear(key)
+
+	aead, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonceSize := aead.NonceSize()
+	if len(data) < nonceSize {
+		return "", fmt.Errorf("ciphertext is too short")
+	}
+
+	plaintext, err := aead.Open(nil, data[:nonceSize], data[nonceSize:], []byte(associatedData))
+	if err != nil {
+		return "", ErrAuthenticationFailed
+	}
+
+	return string(plaintext), nil
+}
+
+// parseArgon2KDF разбирает строку параметров вида "argon2id$v=19$m=19456,t=2,p=1$<соль>"
+func parseArgon2KDF(kdf string) (Argon2Params, []byte, error) {
+	var params Argon2Params
+
+	parts := strings.Split(kdf, "$")
+	if len(parts) != 4 || parts[0] != "argon2id" {
+		return params, nil, fmt.Errorf("unsupported passphrase KDF")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[1], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, fmt.Errorf("unsupported argon2 version")
+	}
+
+	if _, err := fmt.Sscanf(parts[2], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads); err != nil {
+		return params, nil, fmt.Errorf("invalid argon2 parameters: %w", err)
+	}
+
+	if params.Time == 0 || params.Time > argon2MaxTime ||
+		params.Memory == 0 || params.Memory > argon2MaxMemory ||
+		params.Threads == 0 || params.Threads > argon2MaxThreads {
+		return params, nil, fmt.Errorf("argon2 parameters out of range")
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil || len(salt) == 0 {
+		return params, nil, fmt.Errorf("invalid argon2 salt")
+	}
+
+	return params, salt, nil
+}
@@ -0,0 +1,202 @@
+package crypto
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// StreamChunkSize - размер открытого текста в одном фрагменте потока
+const StreamChunkSize = 64 * 1024
+
+// streamSealedChunkSize - размер зашифрованного фрагмента (кроме последнего): открытый текст и тег GCM
+const streamSealedChunkSize = StreamChunkSize + 16
+
+// ErrTruncatedStream возвращается, если поток закончился без последнего фрагмента
+var ErrTruncatedStream = errors.New("encrypted stream is truncated")
+
+// ErrTrailingData возвращается, если после последнего фрагмента в потоке есть данные
+var ErrTrailingData = errors.New("encrypted stream has data after the final chunk")
+
+// NewStreamKey генерирует случайный ключ для шифрования потока
+func NewStreamKey() ([]byte, error) {
+	key := make([]byte, dataKeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, fmt.Errorf("failed to generate stream key: %w", err)
+	}
+	return key, nil
+}
+
+// StreamWriter шифрует поток фрагментами по StreamChunkSize байт (схема STREAM):
+// каждый фрагмент шифруется AES-256-GCM с nonce из номера фрагмента и признака
+// последнего фрагмента. Переставить, удалить или отрезать фрагменты незаметно
+// нельзя. Ключ должен быть уникальным для каждого потока.
+type StreamWriter struct {
+	aead           cipher.AEAD
+	associatedData []byte
+	emit           func([]byte) error
+	buf            []byte
+	counter        uint64
+	closed         bool
+}
+
+// NewStreamWriter создаёт шифратор потока. Каждый зашифрованный фрагмент
+// передаётся в emit; срез нельзя использовать после возврата из emit.
+func NewStreamWriter(key []byte, associatedData string, emit func([]byte) error) (*StreamWriter, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &StreamWriter{
+		aead:           aead,
+		associatedData: []byte(associatedData),
+		emit:           emit,
+		buf:            make([]byte, 0, StreamChunkSize),
+	}, nil
+}
+
+// Write шифрует данные по мере заполнения фрагментов
+func (s *StreamWriter) Write(p []byte) (int, error) {
+	if s.closed {
+		return 0, fmt.Errorf("write to closed stream")
+	}
+
+	written := 0
+	for len(p) > 0 {
+		// Полный фрагмент отправляется, только когда известно, что он не последний
+		if len(s.buf) == StreamChunkSize {
+			if err := s.flush(false); err != nil {
+				return written, err
+			}
+		}
+
+		n := copy(s.buf[len(s.buf):StreamChunkSize], p)
+		s.buf = s.buf[:len(s.buf)+n]
+		p = p[n:]
+		written += n
+	}
+
+	return written, nil
+}
+
+// Close шифрует последний фрагмент (он может быть пустым)
+func (s *StreamWriter) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return s.flush(true)
+}
+
+// flush шифрует накопленный фрагмент
+func (s *StreamWriter) flush(last bool) error {
+	nonce := streamNonce(s.counter, last)
+	sealed := s.aead.Seal(nil, nonce, s.buf, s.associatedData)
+	s.counter++
+	s.buf = s.buf[:0]
+	return s.emit(sealed)
+}
+
+// StreamReader расшифровывает поток, зашифрованный StreamWriter
+type StreamReader struct {
+	aead           cipher.AEAD
+	associatedData []byte
+	next           func() ([]byte, error)
+	plain          []byte
+	counter        uint64
+	done           bool  // Последний фрагмент расшифрован
+	end            error // Результат проверки конца потока после последнего фрагмента
+}
+
+// NewStreamReader создаёт расшифровщик потока. next возвращает очередной
+// зашифрованный фрагмент или io.EOF, когда фрагменты закончились.
+func NewStreamReader(key []byte, associatedData string, next func() ([]byte, error)) (*StreamReader, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &StreamReader{
+		aead:           aead,
+		associatedData: []byte(associatedData),
+		next:           next,
+	}, nil
+}
+
+// Read возвращает расшифрованные данные. Ошибка аутентификации фрагмента
+// приводит к ErrAuthenticationFailed, обрыв потока - к ErrTruncatedStream,
+// данные после последнего фрагмента - к ErrTrailingData.
+func (s *StreamReader) Read(p []byte) (int, error) {
+	for len(s.plain) == 0 {
+		if s.done {
+			if s.end == nil {
+				s.end = s.checkEnd()
+			}
+			return 0, s.end
+		}
+
+		chunk, err := s.next()
+		if err == io.EOF {
+			return 0, ErrTruncatedStream
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		// Фрагмент может оказаться последним: пробуем оба варианта nonce
+		plain, err := s.aead.Open(nil, streamNonce(s.counter, false), chunk, s.associatedData)
+		if err != nil {
+			plain, err = s.aead.Open(nil, streamNonce(s.counter, true), chunk, s.associatedData)
+			if err != nil {
+				return 0, ErrAuthenticationFailed
+			}
+			s.done = true
+		}
+
+		s.counter++
+		s.plain = plain
+	}
+
+	n := copy(p, s.plain)
+	s.plain = s.plain[n:]
+	return n, nil
+}
+
+// checkEnd проверяет, что за последним фрагментом поток закончился
+func (s *StreamReader) checkEnd() error {
+	_, err := s.next()
+	if err == nil {
+		return ErrTrailingData
+	}
+	return err
+}
+
+// SealedChunks разбивает записанный подряд зашифрованный поток на фрагменты
+// для NewStreamReader: все фрагменты, кроме последнего, имеют одинаковый размер
+func SealedChunks(r io.Reader) func() ([]byte, error) {
+	buf := make([]byte, streamSealedChunkSize)
+	return func() ([]byte, error) {
+		n, err := io.ReadFull(r, buf)
+		if err == io.ErrUnexpectedEOF {
+			err = nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	}
+}
+
+// streamNonce формирует nonce фрагмента: 11 байт номера фрагмента и признак последнего
+func streamNonce(counter uint64, last bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[3:11], counter)
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
@@ -0,0 +1,132 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+// sealStream шифрует data и возвращает поток фрагментов, записанных подряд
+func sealStream(t *testing.T, key []byte, data []byte) []byte {
+	t.Helper()
+	var sealed bytes.Buffer
+	writer, err := NewStreamWriter(key, "secret-1", func(chunk []byte) error {
+		sealed.Write(chunk)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("NewStreamWriter: %v", err)
+	}
+	if _, err := writer.Write(data); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return sealed.Bytes()
+}
+
+func openStream(t *testing.T, key []byte, sealed []byte) ([]byte, error) {
+	t.Helper()
+	reader, err := NewStreamReader(key, "secret-1", SealedChunks(bytes.NewReader(sealed)))
+	if err != nil {
+		t.Fatalf("NewStreamReader: %v", err)
+	}
+	return io.ReadAll(reader)
+}
+
+func TestStreamRoundTrip(t *testing.T) {
+	key, err := NewStreamKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, size := range []int{1, StreamChunkSize - 1, StreamChunkSize, StreamChunkSize + 1, 3*StreamChunkSize + 7} {
+		data := bytes.Repeat([]byte{byte(size)}, size)
+		plain, err := openStream(t, key, sealStream(t, key, data))
+		if err != nil {
+			t.Errorf("size %d: %v", size, err)
+			continue
+		}
+		if !bytes.Equal(plain, data) {
+			t.Errorf("size %d: decrypted data differs", size)
+		}
+	}
+}
+
+// sliceChunks возвращает источник фрагментов из готового списка
+func sliceChunks(chunks [][]byte) func() ([]byte, error) {
+	return func() ([]byte, error) {
+		if len(chunks) == 0 {
+			return nil, io.EOF
+		}
+		chunk := chunks[0]
+		chunks = chunks[1:]
+		return chunk, nil
+	}
+}
+
+func TestStreamRejectsChunksAfterFinal(t *testing.T) {
+	key, err := NewStreamKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var chunks [][]byte
+	writer, err := NewStreamWriter(key, "secret-1", func(chunk []byte) error {
+		chunks = append(chunks, bytes.Clone(chunk))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer.Write([]byte("data"))
+	writer.Close()
+
+	reader, err := NewStreamReader(key, "secret-1", sliceChunks(append(chunks, []byte("extra"))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(reader); !errors.Is(err, ErrTrailingData) {
+		t.Errorf("err = %v, want ErrTrailingData", err)
+	}
+	// Ошибка повторяется при следующих чтениях
+	if _, err := reader.Read(make([]byte, 1)); !errors.Is(err, ErrTrailingData) {
+		t.Errorf("second Read: err = %v, want ErrTrailingData", err)
+	}
+}
+
+func TestStreamRejectsTamperedStreams(t *testing.T) {
+	key, err := NewStreamKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Последний фрагмент полного размера: лишние данные читаются отдельным фрагментом
+	sealed := sealStream(t, key, bytes.Repeat([]byte("a"), StreamChunkSize))
+	if _, err := openStream(t, key, append(bytes.Clone(sealed), "garbage"...)); !errors.Is(err, ErrTrailingData) {
+		t.Errorf("full final chunk, appended data: err = %v, want ErrTrailingData", err)
+	}
+
+	for _, size := range []int{100, 2*StreamChunkSize + 100} {
+		sealed := sealStream(t, key, bytes.Repeat([]byte("a"), size))
+
+		// Короткий последний фрагмент склеивается с лишними данными и не проходит аутентификацию
+		if _, err := openStream(t, key, append(bytes.Clone(sealed), "garbage"...)); !errors.Is(err, ErrAuthenticationFailed) {
+			t.Errorf("size %d, appended data: err = %v, want ErrAuthenticationFailed", size, err)
+		}
+
+		if size > StreamChunkSize {
+			if _, err := openStream(t, key, sealed[:streamSealedChunkSize]); !errors.Is(err, ErrTruncatedStream) {
+				t.Errorf("size %d, truncated: err = %v, want ErrTruncatedStream", size, err)
+			}
+		}
+
+		flipped := bytes.Clone(sealed)
+		flipped[len(flipped)-1] ^= 1
+		if _, err := openStream(t, key, flipped); !errors.Is(err, ErrAuthenticationFailed) {
+			t.Errorf("size %d, modified: err = %v, want ErrAuthenticationFailed", size, err)
+		}
+	}
+}
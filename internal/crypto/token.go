@@ -0,0 +1,41 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+)
+
+// managementTokenSize - размер токена управления секретом в байтах
+const managementTokenSize = 32
+
+// GenerateManagementToken создаёт случайный токен управления секретом (base64url).
+// Сервер хранит только его хеш (HashManagementToken).
+func GenerateManagementToken() (string, error) {
+	token := make([]byte, managementTokenSize)
+	if _, err := io.ReadFull(rand.Reader, token); err != nil {
+		return "", fmt.Errorf("failed to generate management token: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+// HashManagementToken возвращает SHA-256 хеш токена в hex. Токен случайный и
+// длинный, поэтому медленная KDF не нужна.
+func HashManagementToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifyManagementToken сравнивает токен с сохранённым хешем за постоянное время
+func VerifyManagementToken(token, hash string) bool {
+	if token == "" || hash == "" {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(HashManagementToken(token)), []byte(hash)) == 1
+}
@@ -0,0 +1,218 @@
+package crypto
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// vaultKeyIDPrefix отличает ключи Vault Transit от ключей локальной связки в колонке key_id
+	vaultKeyIDPrefix = "vault:"
+
+	// vaultMaxResponseBody - сколько байт ответа Vault читать
+	vaultMaxResponseBody = 1 << 20
+)
+
+// VaultTransitProvider оборачивает ключи данных через API Vault Transit
+// (или совместимый с ним сервис). Мастер-ключ не покидает Vault.
+type VaultTransitProvider struct {
+	address   string
+	token     string
+	namespace string
+	mount     string
+	keyName   string
+	client    *http.Client
+}
+
+// VaultTransitConfig содержит параметры подключения к Vault Transit
+type VaultTransitConfig struct {
+	Address   string // Адрес Vault, например https://vault.example.com:8200
+	Token     string // Токен с правами encrypt/decrypt на ключ
+	Namespace string // Namespace Vault Enterprise (опционально)
+	Mount     string // Путь монтирования transit (по умолчанию "transit")
+	KeyName   string // Имя ключа в transit
+}
+
+// vaultResponse представляет ответ Vault на запросы encrypt/decrypt
+type vaultResponse struct {
+	Data struct {
+		Ciphertext string `json:"ciphertext"`
+		Plaintext  string `json:"plaintext"`
+	} `json:"data"`
+	Errors []string `json:"errors"`
+}
+
+// NewVaultTransitProvider создаёт провайдер Vault Transit
+func NewVaultTransitProvider(cfg VaultTransitConfig) (*VaultTransitProvider, error) {
+	if cfg.Address == "" || cfg.Token == "" || cfg.KeyName == "" {
+		return nil, fmt.Errorf("vault address, token and transit key name are required")
+	}
+
+	if _, err := url.ParseRequestURI(cfg.Address); err != nil {
+		return nil, fmt.Errorf("invalid vault address: %w", err)
+	}
+
+	mount := cfg.Mount
+	if mount == "" {
+		mount = "transit"
+	}
+
+	return &VaultTransitProvider{
+		address:   strings.TrimRight(cfg.Address, "/"),
+		token:     cfg.Token,
+		namespace: cfg.Namespace,
+		mount:     strings.Trim(mount, "/"),
+		keyName:   cfg.KeyName,
+		client:    &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// ActiveKeyID возвращает ID ключа Vault в формате "vault:<имя ключа>".
+// Версии ключа Vault хранит внутри обёрнутого ключа, поэтому ID не меняется при ротации в Vault.
+func (p *VaultTransitProvider) ActiveKeyID() string {
+	return vaultKeyIDPrefix + p.keyName
+}
+
+// HasKey сообщает, обёрнут ли ключ данных ключом Vault этого провайдера
+func (p *VaultTransitProvider) HasKey(keyID string) bool {
+	return keyID == p.ActiveKeyID()
+}
+
+// WrapKey шифрует ключ данных через transit/encrypt. Связанные данные передаются
+// в associated_data и проверяются Vault при расшифровке (ключи aes256-gcm96 и chacha20-poly1305).
+func (p *VaultTransitProvider) WrapKey(ctx context.Context, dataKey []byte, associatedData string) (string, string, error) {
+	var resp vaultResponse
+	err := p.call(ctx, "encrypt", map[string]string{
+		"plaintext":       base64.StdEncoding.EncodeToString(dataKey),
+		"associated_data": base64.StdEncoding.EncodeToString(wrapAssociatedData(p.ActiveKeyID(), associatedData)),
+	}, &resp)
+	if err != nil {
+		return "", "", err
+	}
+
+	if _, err := vaultKeyVersion(resp.Data.Ciphertext); err != nil {
+		return "", "", fmt.Errorf("vault returned invalid ciphertext: %w", err)
+	}
+
+	return p.ActiveKeyID(), boundKeyPrefix + resp.Data.Ciphertext, nil
+}
+
+// UnwrapKey расшифровывает ключ данных через transit/decrypt, передавая в
+// associated_data ID ключа и ID секрета
+func (p *VaultTransitProvider) UnwrapKey(ctx context.Context, keyID, wrappedKey, associatedData string) ([]byte, error) {
+	if !p.HasKey(keyID) {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, keyID)
+	}
+
+	ciphertext, bound := strings.CutPrefix(wrappedKey, boundKeyPrefix)
+	if !bound {
+		return nil, fmt.Errorf("%w: wrapped key is not bound to the secret", ErrAuthenticationFailed)
+	}
+	if _, err := vaultKeyVersion(ciphertext); err != nil {
+		return nil, fmt.Errorf("invalid wrapped key: %w", err)
+	}
+
+	payload := map[string]string{
+		"ciphertext":      ciphertext,
+		"associated_data": base64.StdEncoding.EncodeToString(wrapAssociatedData(keyID, associatedData)),
+	}
+
+	var resp vaultResponse
+	if err := p.call(ctx, "decrypt", payload, &resp); err != nil {
+		return nil, err
+	}
+
+	dataKey, err := base64.StdEncoding.DecodeString(resp.Data.Plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode vault plaintext: %w", err)
+	}
+
+	return dataKey, nil
+}
+
+// call выполняет запрос к transit/<operation>/<ключ>
+func (p *VaultTransitProvider) call(ctx context.Context, operation string, payload map[string]string, out *vaultResponse) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal vault request: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("%s/v1/%s/%s/%s", p.address, p.mount, operation, url.PathEscape(p.keyName))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create vault request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Vault-Token", p.token)
+	if p.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.namespace)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("vault %s request failed: %w", operation, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, vaultMaxResponseBody))
+	if err != nil {
+		return fmt.Errorf("failed to read vault %s response: %w", operation, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return vaultError(operation, resp.StatusCode, respBody)
+	}
+
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("failed to decode vault %s response: %w", operation, err)
+	}
+
+	return nil
+}
+
+// vaultError преобразует ответ Vault с ошибкой. Тело может быть не JSON
+// (например, ответ балансировщика), тогда в ошибку попадает текст статуса.
+// Несовпадение тега при расшифровке соответствует ErrAuthenticationFailed.
+func vaultError(operation string, status int, body []byte) error {
+	var failure vaultResponse
+	message := http.StatusText(status)
+	if json.Unmarshal(body, &failure) == nil && len(failure.Errors) > 0 {
+		message = strings.Join(failure.Errors, "; ")
+	}
+
+	if operation == "decrypt" && status == http.StatusBadRequest && strings.Contains(message, "message authentication failed") {
+		return ErrAuthenticationFailed
+	}
+
+	return fmt.Errorf("vault %s failed with status %d: %s", operation, status, message)
+}
+
+// vaultKeyVersion возвращает версию ключа Vault из шифртекста формата "vault:v<N>:<base64>"
+func vaultKeyVersion(ciphertext string) (int, error) {
+	rest, ok := strings.CutPrefix(ciphertext, vaultKeyIDPrefix+"v")
+	if !ok {
+		return 0, fmt.Errorf("ciphertext must start with %q", vaultKeyIDPrefix+"v")
+	}
+
+	versionStr, payload, found := strings.Cut(rest, ":")
+	if !found || payload == "" {
+		return 0, fmt.Errorf("ciphertext has no payload")
+	}
+
+	version, err := strconv.Atoi(versionStr)
+	if err != nil || version <= 0 {
+		return 0, fmt.Errorf("invalid key version %q", versionStr)
+	}
+
+	return version, nil
+}
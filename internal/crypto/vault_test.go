@@ -0,0 +1,222 @@
+package crypto
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// transitStub имитирует transit/encrypt и transit/decrypt: шифртекст - ссылка
+// на сохранённую пару (ключ данных, associated_data)
+type transitStub struct {
+	t       *testing.T
+	mu      sync.Mutex
+	entries map[string][2]string
+	version int
+}
+
+func newTransitStub(t *testing.T) (*transitStub, *httptest.Server) {
+	stub := &transitStub{t: t, entries: make(map[string][2]string), version: 3}
+	server := httptest.NewServer(stub)
+	t.Cleanup(server.Close)
+	return stub, server
+}
+
+func (s *transitStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-Vault-Token") != "test-token" {
+		writeVault(w, http.StatusForbidden, map[string]any{"errors": []string{"permission denied"}})
+		return
+	}
+	if r.Header.Get("X-Vault-Namespace") != "team" {
+		s.t.Errorf("X-Vault-Namespace = %q, want %q", r.Header.Get("X-Vault-Namespace"), "team")
+	}
+
+	var req map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.t.Fatalf("failed to decode request: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch r.URL.Path {
+	case "/v1/transit/encrypt/ares":
+		ciphertext := fmt.Sprintf("vault:v%d:%s", s.version, base64.StdEncoding.EncodeToString(fmt.Appendf(nil, "entry-%d", len(s.entries))))
+		s.entries[ciphertext] = [2]string{req["plaintext"], req["associated_data"]}
+		writeVault(w, http.StatusOK, map[string]any{"data": map[string]string{"ciphertext": ciphertext}})
+	case "/v1/transit/decrypt/ares":
+		entry, ok := s.entries[req["ciphertext"]]
+		if !ok || entry[1] != req["associated_data"] {
+			writeVault(w, http.StatusBadRequest, map[string]any{"errors": []string{"cipher: message authentication failed"}})
+			return
+		}
+		writeVault(w, http.StatusOK, map[string]any{"data": map[string]string{"plaintext": entry[0]}})
+	default:
+		s.t.Errorf("unexpected path %s", r.URL.Path)
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func writeVault(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func newTestVaultProvider(t *testing.T, address, token string) *VaultTransitProvider {
+	t.Helper()
+	provider, err := NewVaultTransitProvider(VaultTransitConfig{
+		Address:   address,
+		Token:     token,
+		Namespace: "team",
+		KeyName:   "ares",
+	})
+	if err != nil {
+		t.Fatalf("NewVaultTransitProvider: %v", err)
+	}
+	return provider
+}
+
+func TestVaultTransitWrapUnwrap(t *testing.T) {
+	_, server := newTransitStub(t)
+	provider := newTestVaultProvider(t, server.URL, "test-token")
+	ctx := context.Background()
+
+	dataKey := []byte("0123456789abcdef0123456789abcdef")
+	keyID, wrapped, err := provider.WrapKey(ctx, dataKey, "secret-1")
+	if err != nil {
+		t.Fatalf("WrapKey: %v", err)
+	}
+	if keyID != "vault:ares" {
+		t.Errorf("keyID = %q, want %q", keyID, "vault:ares")
+	}
+	if !strings.HasPrefix(wrapped, boundKeyPrefix+"vault:v3:") {
+		t.Errorf("wrapped key %q has no bound prefix", wrapped)
+	}
+
+	unwrapped, err := provider.UnwrapKey(ctx, keyID, wrapped, "secret-1")
+	if err != nil {
+		t.Fatalf("UnwrapKey: %v", err)
+	}
+	if string(unwrapped) != string(dataKey) {
+		t.Errorf("UnwrapKey = %q, want %q", unwrapped, dataKey)
+	}
+
+	// Ключ, перенесённый в другую запись, не разворачивается
+	if _, err := provider.UnwrapKey(ctx, keyID, wrapped, "secret-2"); !errors.Is(err, ErrAuthenticationFailed) {
+		t.Errorf("UnwrapKey with another secret ID: err = %v, want ErrAuthenticationFailed", err)
+	}
+
+	if _, err := provider.UnwrapKey(ctx, "vault:other", wrapped, "secret-1"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("UnwrapKey with another key: err = %v, want ErrUnknownKey", err)
+	}
+}
+
+func TestVaultTransitRejectsUnboundKey(t *testing.T) {
+	stub, server := newTransitStub(t)
+	provider := newTestVaultProvider(t, server.URL, "test-token")
+
+	// Ключ без префикса не отправляется в Vault без связанных данных
+	stub.entries["vault:v1:dW5ib3VuZA=="] = [2]string{base64.StdEncoding.EncodeToString([]byte("data-key")), ""}
+
+	if _, err := provider.UnwrapKey(context.Background(), "vault:ares", "vault:v1:dW5ib3VuZA==", "secret-1"); !errors.Is(err, ErrAuthenticationFailed) {
+		t.Errorf("UnwrapKey without prefix: err = %v, want ErrAuthenticationFailed", err)
+	}
+	if _, err := provider.UnwrapKey(context.Background(), "vault:ares", boundKeyPrefix+"vault:v1:dW5ib3VuZA==", "secret-1"); !errors.Is(err, ErrAuthenticationFailed) {
+		t.Errorf("UnwrapKey of a key wrapped without associated data: err = %v, want ErrAuthenticationFailed", err)
+	}
+}
+
+func TestVaultKeyVersion(t *testing.T) {
+	tests := []struct {
+		ciphertext string
+		version    int
+		wantErr    bool
+	}{
+		{ciphertext: "vault:v1:abc", version: 1},
+		{ciphertext: "vault:v42:abc:def", version: 42},
+		{ciphertext: "vault:v0:abc", wantErr: true},
+		{ciphertext: "vault:vx:abc", wantErr: true},
+		{ciphertext: "vault:v1:", wantErr: true},
+		{ciphertext: "vault:v1", wantErr: true},
+		{ciphertext: "v1:abc", wantErr: true},
+		{ciphertext: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		version, err := vaultKeyVersion(tt.ciphertext)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("vaultKeyVersion(%q) = %d, want error", tt.ciphertext, version)
+			}
+			continue
+		}
+		if err != nil || version != tt.version {
+			t.Errorf("vaultKeyVersion(%q) = %d, %v, want %d", tt.ciphertext, version, err, tt.version)
+		}
+	}
+}
+
+func TestVaultTransitErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		want    string
+	}{
+		{
+			name: "vault errors",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				writeVault(w, http.StatusForbidden, map[string]any{"errors": []string{"permission denied"}})
+			},
+			want: "vault encrypt failed with status 403: permission denied",
+		},
+		{
+			name: "non-JSON body",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusBadGateway)
+				w.Write([]byte("<html>bad gateway</html>"))
+			},
+			want: "vault encrypt failed with status 502: Bad Gateway",
+		},
+		{
+			name: "invalid ciphertext",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				writeVault(w, http.StatusOK, map[string]any{"data": map[string]string{"ciphertext": "not-a-vault-ciphertext"}})
+			},
+			want: "vault returned invalid ciphertext",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.handler)
+			defer server.Close()
+
+			provider := newTestVaultProvider(t, server.URL, "test-token")
+			_, _, err := provider.WrapKey(context.Background(), []byte("key"), "secret-1")
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("WrapKey: err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVaultTransitDecryptErrorIsNotAuthFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeVault(w, http.StatusInternalServerError, map[string]any{"errors": []string{"internal error"}})
+	}))
+	defer server.Close()
+
+	provider := newTestVaultProvider(t, server.URL, "test-token")
+	_, err := provider.UnwrapKey(context.Background(), "vault:ares", boundKeyPrefix+"vault:v1:abc", "secret-1")
+	if err == nil || errors.Is(err, ErrAuthenticationFailed) {
+		t.Errorf("UnwrapKey: err = %v, want a non-authentication error", err)
+	}
+}
@@ -1,39 +1,102 @@
 package database
 
 import (
-	"database/sql"
+	"context"
 	"fmt"
 	"time"
 
-	_ "github.com/jackc/pgx/v5/stdlib" // PostgreSQL driver
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-// DB представляет подключение к базе данных
+// Параметры пула по умолчанию, если они не заданы ни в PoolConfig, ни в DATABASE_URL.
+// Остальные параметры по умолчанию берутся из pgxpool.
+const (
+	DefaultMaxConns        = 25
+	DefaultMaxConnLifetime = 5 * time.Minute
+)
+
+// PoolConfig содержит параметры пула подключений к PostgreSQL.
+// Нулевое значение поля означает, что параметр не задан: используется значение
+// из DATABASE_URL (pool_max_conns, pool_min_conns, pool_max_conn_lifetime,
+// pool_health_check_period) или значение по умолчанию.
+type PoolConfig struct {
+	MaxConns          int32         // Максимальное количество подключений
+	MinConns          int32         // Количество подключений, которые пул держит открытыми
+	MaxConnLifetime   time.Duration // Время жизни подключения, после которого оно пересоздаётся
+	HealthCheckPeriod time.Duration // Период проверки простаивающих подключений
+}
+
+// DB представляет подключение к базе данных через пул pgxpool
 type DB struct {
-	*sql.DB
+	*pgxpool.Pool
 }
 
 // New создаёт новое подключение к базе данных
-func New(databaseURL string) (*DB, error) {
-	db, err := sql.Open("pgx", databaseURL)
+func New(databaseURL string, poolCfg PoolConfig) (*DB, error) {
+	cfg, err := parseConfig(databaseURL, poolCfg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
+		return nil, err
 	}
 
-	// Настройка пула подключений
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(5)
-	db.SetConnMaxLifetime(5 * time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
 
 	// Проверка подключения
-	if err := db.Ping(); err != nil {
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	return &DB{db}, nil
+	return &DB{Pool: pool}, nil
 }
 
-// Close закрывает подключение к базе данных
-func (db *DB) Close() error {
-	return db.DB.Close()
+// parseConfig разбирает DATABASE_URL и применяет параметры пула
+func parseConfig(databaseURL string, poolCfg PoolConfig) (*pgxpool.Config, error) {
+	cfg, err := pgxpool.ParseConfig(databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse database URL: %w", err)
+	}
+
+	// pgxpool удаляет параметры пула из разобранной конфигурации, поэтому
+	// заданы ли они в DATABASE_URL, проверяется по отдельному разбору
+	connCfg, err := pgx.ParseConfig(databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse database URL: %w", err)
+	}
+	inURL := func(param string) bool {
+		_, ok := connCfg.RuntimeParams[param]
+		return ok
+	}
+
+	// Заданные явно параметры пула имеют приоритет над DATABASE_URL
+	switch {
+	case poolCfg.MaxConns > 0:
+		cfg.MaxConns = poolCfg.MaxConns
+	case !inURL("pool_max_conns"):
+		cfg.MaxConns = DefaultMaxConns
+	}
+	if poolCfg.MinConns > 0 {
+		cfg.MinConns = poolCfg.MinConns
+	}
+	switch {
+	case poolCfg.MaxConnLifetime > 0:
+		cfg.MaxConnLifetime = poolCfg.MaxConnLifetime
+	case !inURL("pool_max_conn_lifetime"):
+		cfg.MaxConnLifetime = DefaultMaxConnLifetime
+	}
+	if poolCfg.HealthCheckPeriod > 0 {
+		cfg.HealthCheckPeriod = poolCfg.HealthCheckPeriod
+	}
+
+	if cfg.MinConns > cfg.MaxConns {
+		return nil, fmt.Errorf("pool min connections (%d) exceed max connections (%d)", cfg.MinConns, cfg.MaxConns)
+	}
+
+	return cfg, nil
 }
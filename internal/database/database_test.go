@@ -0,0 +1,76 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseConfigPoolSettings(t *testing.T) {
+	tests := []struct {
+		name        string
+		url         string
+		pool        PoolConfig
+		maxConns    int32
+		minConns    int32
+		lifetime    time.Duration
+		healthCheck time.Duration
+	}{
+		{
+			name:        "defaults",
+			url:         "postgres://user@localhost/db",
+			maxConns:    DefaultMaxConns,
+			lifetime:    DefaultMaxConnLifetime,
+			healthCheck: time.Minute,
+		},
+		{
+			name:        "settings from the URL",
+			url:         "postgres://user@localhost/db?pool_max_conns=7&pool_min_conns=2&pool_max_conn_lifetime=30m&pool_health_check_period=10s",
+			maxConns:    7,
+			minConns:    2,
+			lifetime:    30 * time.Minute,
+			healthCheck: 10 * time.Second,
+		},
+		{
+			name:        "explicit settings override the URL",
+			url:         "postgres://user@localhost/db?pool_max_conns=7&pool_min_conns=2&pool_max_conn_lifetime=30m&pool_health_check_period=10s",
+			pool:        PoolConfig{MaxConns: 40, MinConns: 4, MaxConnLifetime: time.Hour, HealthCheckPeriod: 20 * time.Second},
+			maxConns:    40,
+			minConns:    4,
+			lifetime:    time.Hour,
+			healthCheck: 20 * time.Second,
+		},
+		{
+			name:        "partial explicit settings",
+			url:         "host=localhost user=user dbname=db pool_max_conns=7",
+			pool:        PoolConfig{MinConns: 3},
+			maxConns:    7,
+			minConns:    3,
+			lifetime:    DefaultMaxConnLifetime,
+			healthCheck: time.Minute,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := parseConfig(tt.url, tt.pool)
+			if err != nil {
+				t.Fatalf("parseConfig: %v", err)
+			}
+			if cfg.MaxConns != tt.maxConns || cfg.MinConns != tt.minConns ||
+				cfg.MaxConnLifetime != tt.lifetime || cfg.HealthCheckPeriod != tt.healthCheck {
+				t.Errorf("pool = max %d, min %d, lifetime %v, health check %v; want max %d, min %d, lifetime %v, health check %v",
+					cfg.MaxConns, cfg.MinConns, cfg.MaxConnLifetime, cfg.HealthCheckPeriod,
+					tt.maxConns, tt.minConns, tt.lifetime, tt.healthCheck)
+			}
+			if _, ok := cfg.ConnConfig.RuntimeParams["pool_max_conns"]; ok {
+				t.Errorf("pool setting passed to the server as a runtime parameter")
+			}
+		})
+	}
+}
+
+func TestParseConfigRejectsMinAboveMax(t *testing.T) {
+	if _, err := parseConfig("postgres://user@localhost/db?pool_max_conns=2", PoolConfig{MinConns: 5}); err == nil {
+		t.Errorf("parseConfig accepted min connections above max connections")
+	}
+}
@@ -0,0 +1,436 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/savo4ka/ares-api/internal/crypto"
+	"github.com/savo4ka/ares-api/internal/models"
+)
+
+const (
+	// fileTransferTimeout - таймаут чтения или записи файла (вместо таймаутов сервера)
+	fileTransferTimeout = 10 * time.Minute
+
+	// fileFormOverhead - запас на поля формы и заголовки multipart сверх MAX_UPLOAD_SIZE
+	fileFormOverhead = 1 << 20
+
+	// maxFormFieldLength - максимальная длина значения поля формы
+	maxFormFieldLength = 4096
+
+	// maxFileNameLength - максимальная длина имени файла (размер колонки)
+	maxFileNameLength = 255
+
+	// defaultFileMIME - MIME тип файла, если клиент его не указал
+	defaultFileMIME = "application/octet-stream"
+)
+
+var (
+	errFileTooLarge    = errors.New("file is too large")
+	errEmptyFile       = errors.New("file is empty")
+	errFieldsAfterFile = errors.New("form fields after the file are not supported")
+	errMalformedUpload = errors.New("malformed multipart upload")
+	errContentWithFile = errors.New("content cannot be combined with a file")
+)
+
+// formFieldError - ошибка в значении поля формы; текст отдаётся клиенту
+type formFieldError string
+
+func (e formFieldError) Error() string {
+	return string(e)
+}
+
+// createFileSecret создаёт секрет-файл из запроса multipart/form-data.
+// Параметры секрета передаются полями формы с теми же именами, что и в JSON,
+// и должны идти до части "file". Файл шифруется фрагментами по мере загрузки
+// случайным ключом; этот ключ - контент секрета, он защищается так же, как
+// текстовый секрет (ключ сервера, парольная фраза, ключ из ссылки).
+func (h *SecretHandler) createFileSecret(w http.ResponseWriter, r *http.Request) {
+	// Заведомо слишком большой запрос отклоняется, не читая тело
+	if r.ContentLength > h.cfg.MaxUploadSize+fileFormOverhead {
+		h.respondWithUploadError(w, errFileTooLarge)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, h.cfg.MaxUploadSize+fileFormOverhead)
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid multipart form")
+		return
+	}
+
+	var req models.CreateSecretRequest
+	var file *multipart.Part
+	for file == nil {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			h.respondWithUploadError(w, fmt.Errorf("%w: %w", errMalformedUpload, err))
+			return
+		}
+
+		if part.FormName() == "file" {
+			file = part
+			break
+		}
+
+		if err := readFormField(&req, part); err != nil {
+			h.respondWithUploadError(w, err)
+			return
+		}
+	}
+
+	if file == nil {
+		respondWithError(w, http.StatusBadRequest, "File is required")
+		return
+	}
+
+	fileName := sanitizeFileName(file.FileName())
+	if fileName == "" {
+		respondWithError(w, http.StatusBadRequest, "File name is required")
+		return
+	}
+
+	expiresAt, ok := h.validateCreateRequest(w, &req)
+	if !ok {
+		return
+	}
+
+	// Файл шифрует сервер, поэтому он не может быть зашифрован клиентом
+	if req.EncryptionMode == models.EncryptionModeClient {
+		respondWithError(w, http.StatusBadRequest, "Encryption mode client is not supported for files")
+		return
+	}
+
+	// Таймауты сервера продлеваются, только когда запрос проверен и начинается
+	// загрузка самого файла: иначе медленный клиент удерживал бы соединение,
+	// не отправив ни одного корректного поля
+	extendTransferDeadlines(w)
+
+	fileKey, err := crypto.NewStreamKey()
+	if err != nil {
+		h.metrics.EncryptionErrorsTotal.Inc()
+		respondWithError(w, http.StatusInternalServerError, "Failed to encrypt secret")
+		return
+	}
+
+	secret, managementToken, linkKey, ok := h.sealSecret(w, r, &req, base64.StdEncoding.EncodeToString(fileKey), expiresAt)
+	if !ok {
+		return
+	}
+	secret.FileName = fileName
+	secret.FileMIME = fileMIME(file.Header.Get("Content-Type"))
+	secret.BlobKey = secret.ID
+
+	// Зашифрованный файл записывается в хранилище блобов до строки секрета
+	blob, err := h.blobs.Create(r.Context(), secret.BlobKey)
+	if err != nil {
+		h.respondWithUploadError(w, err)
+		return
+	}
+
+	secret.FileSize, err = h.encryptUpload(file, fileKey, secret.ID, blob)
+	if err == nil {
+		// Поля после файла не поддерживаются: параметры секрета уже применены
+		if _, nextErr := reader.NextPart(); nextErr != io.EOF {
+			err = errFieldsAfterFile
+		}
+	}
+	if err != nil {
+		blob.Abort()
+		h.respondWithUploadError(w, err)
+		return
+	}
+
+	if err := blob.Commit(); err != nil {
+		h.respondWithUploadError(w, err)
+		return
+	}
+
+	// Сохраняем в базу данных
+	if err := h.repo.Create(r.Context(), secret); err != nil {
+		h.deleteBlob(secret.BlobKey)
+		respondWithError(w, http.StatusInternalServerError, "Failed to create secret")
+		return
+	}
+
+	h.metrics.FileBytesTotal.WithLabelValues("upload").Add(float64(secret.FileSize))
+	h.completeCreate(w, r, &req, secret, managementToken, linkKey)
+}
+
+// encryptUpload читает файл из запроса, шифрует его фрагментами и записывает их в dst.
+// Возвращает размер файла.
+func (h *SecretHandler) encryptUpload(file io.Reader, key []byte, id string, dst io.Writer) (int64, error) {
+	stream, err := crypto.NewStreamWriter(key, id, func(chunk []byte) error {
+		_, err := dst.Write(chunk)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	buf := make([]byte, crypto.StreamChunkSize)
+	var size int64
+	for {
+		n, readErr := file.Read(buf)
+		if n > 0 {
+			size += int64(n)
+			if size > h.cfg.MaxUploadSize {
+				return 0, errFileTooLarge
+			}
+			if _, err := stream.Write(buf[:n]); err != nil {
+				return 0, err
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return 0, fmt.Errorf("%w: %w", errMalformedUpload, readErr)
+		}
+	}
+
+	if size == 0 {
+		return 0, errEmptyFile
+	}
+
+	if err := stream.Close(); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+// respondWithUploadError отвечает клиенту по ошибке загрузки файла
+func (h *SecretHandler) respondWithUploadError(w http.ResponseWriter, err error) {
+	var maxBytesErr *http.MaxBytesError
+	var fieldErr formFieldError
+	switch {
+	case errors.Is(err, errFileTooLarge) || errors.As(err, &maxBytesErr):
+		respondWithError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("File must be at most %d bytes", h.cfg.MaxUploadSize))
+	case errors.Is(err, errEmptyFile):
+		respondWithError(w, http.StatusBadRequest, "File is empty")
+	case errors.Is(err, errFieldsAfterFile):
+		respondWithError(w, http.StatusBadRequest, "Form fields must precede the file")
+	case errors.Is(err, errContentWithFile):
+		respondWithError(w, http.StatusBadRequest, "Content cannot be combined with a file")
+	case errors.As(err, &fieldErr):
+		respondWithError(w, http.StatusBadRequest, fieldErr.Error())
+	case errors.Is(err, errMalformedUpload):
+		respondWithError(w, http.StatusBadRequest, "Invalid multipart form")
+	default:
+		log.Printf("Failed to store file secret: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to create secret")
+	}
+}
+
+// readFormField заполняет параметр секрета из поля формы
+func readFormField(req *models.CreateSecretRequest, part *multipart.Part) error {
+	name := part.FormName()
+
+	raw, err := io.ReadAll(io.LimitReader(part, maxFormFieldLength+1))
+	if err != nil {
+		return fmt.Errorf("%w: %w", errMalformedUpload, err)
+	}
+	if len(raw) > maxFormFieldLength {
+		return formFieldError(fmt.Sprintf("Field %s is too long", name))
+	}
+	value := string(raw)
+
+	switch name {
+	case "content":
+		return errContentWithFile
+	case "ttl":
+		req.TTL = value
+	case "expiration_hours":
+		if req.ExpirationHours, err = strconv.Atoi(value); err != nil {
+			return formFieldError("Field expiration_hours must be an integer")
+		}
+	case "max_views":
+		if req.MaxViews, err = strconv.Atoi(value); err != nil {
+			return formFieldError("Field max_views must be an integer")
+		}
+	case "expires_at", "available_at":
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return formFieldError(fmt.Sprintf("Field %s must be an RFC 3339 timestamp", name))
+		}
+		if name == "expires_at" {
+			req.ExpiresAt = &parsed
+		} else {
+			req.AvailableAt = &parsed
+		}
+	case "encryption_mode":
+		req.EncryptionMode = value
+	case "passphrase":
+		req.Passphrase = value
+	case "notify_webhook_url":
+		req.NotifyWebhookURL = value
+	case "recipient_email":
+		req.RecipientEmail = value
+	case "notify_email":
+		req.NotifyEmail = value
+	}
+
+	// Неизвестные поля игнорируются, как и в JSON запросе
+	return nil
+}
+
+// fileDownload - открытый файл секрета с расшифрованным первым фрагментом
+type fileDownload struct {
+	body   io.ReadCloser
+	stream *crypto.StreamReader
+	head   []byte
+	more   bool // Файл длиннее первого фрагмента
+}
+
+// openFile открывает файл секрета и расшифровывает его первый фрагмент. Вызывается
+// до того, как засчитать просмотр: недоступный или повреждённый файл не должен его тратить.
+// При ошибке отправляет ответ клиенту и возвращает false.
+func (h *SecretHandler) openFile(w http.ResponseWriter, r *http.Request, secret *models.Secret, encodedKey string) (*fileDownload, bool) {
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil {
+		h.recordDecryptionError(secret.ID, err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to decrypt secret")
+		return nil, false
+	}
+
+	body, err := h.blobs.Open(r.Context(), secret.BlobKey)
+	if err != nil {
+		log.Printf("Failed to read file of secret %s: %v", secret.ID, err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to read secret")
+		return nil, false
+	}
+
+	stream, err := crypto.NewStreamReader(key, secret.ID, crypto.SealedChunks(body))
+	if err != nil {
+		body.Close()
+		h.recordDecryptionError(secret.ID, err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to decrypt secret")
+		return nil, false
+	}
+
+	// Первый фрагмент расшифровывается до отправки заголовков, чтобы ошибка
+	// в начале файла превратилась в обычный ответ с ошибкой
+	buf := make([]byte, crypto.StreamChunkSize)
+	n, err := io.ReadFull(stream, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		body.Close()
+		h.recordDecryptionError(secret.ID, err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to decrypt secret")
+		return nil, false
+	}
+
+	return &fileDownload{
+		body:   body,
+		stream: stream,
+		head:   buf[:n],
+		more:   n == len(buf),
+	}, true
+}
+
+// streamFile передаёт клиенту потоком файл, открытый openFile.
+// Просмотр уже засчитан: после последнего просмотра содержимое файла удаляется.
+func (h *SecretHandler) streamFile(w http.ResponseWriter, secret *models.Secret, file *fileDownload, viewsRemaining int) {
+	if viewsRemaining == 0 {
+		defer h.deleteBlob(secret.BlobKey)
+	}
+
+	extendTransferDeadlines(w)
+	w.Header().Set("Content-Type", secret.FileMIME)
+	w.Header().Set("Content-Length", strconv.FormatInt(secret.FileSize, 10))
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": secret.FileName})
+	if disposition == "" {
+		disposition = "attachment"
+	}
+	w.Header().Set("Content-Disposition", disposition)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Secret-Views-Remaining", strconv.Itoa(viewsRemaining))
+	w.WriteHeader(http.StatusOK)
+
+	written, err := w.Write(file.head)
+	if err == nil && file.more {
+		var rest int64
+		rest, err = io.Copy(w, file.stream)
+		written += int(rest)
+	}
+	h.metrics.FileBytesTotal.WithLabelValues("download").Add(float64(written))
+
+	// Заголовки уже отправлены: при ошибке остаётся только оборвать ответ
+	if err != nil {
+		if errors.Is(err, crypto.ErrAuthenticationFailed) || errors.Is(err, crypto.ErrTruncatedStream) ||
+			errors.Is(err, crypto.ErrTrailingData) {
+			h.recordDecryptionError(secret.ID, err)
+		} else {
+			log.Printf("Failed to stream file of secret %s: %v", secret.ID, err)
+		}
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// deleteBlob удаляет содержимое файла уничтоженного секрета. Ошибка не критична:
+// блоб без секрета удалит фоновая очистка.
+func (h *SecretHandler) deleteBlob(key string) {
+	if err := h.blobs.Delete(context.Background(), key); err != nil {
+		log.Printf("Failed to delete blob %s: %v", key, err)
+	}
+}
+
+// extendTransferDeadlines продлевает таймауты сервера для передачи файла
+func extendTransferDeadlines(w http.ResponseWriter) {
+	deadline := time.Now().Add(fileTransferTimeout)
+	controller := http.NewResponseController(w)
+	controller.SetReadDeadline(deadline)
+	controller.SetWriteDeadline(deadline)
+}
+
+// sanitizeFileName оставляет от имени файла только базовое имя без управляющих
+// символов и разделителей путей и обрезает его до maxFileNameLength символов
+func sanitizeFileName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == '/' || r == utf8.RuneError {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+
+	if name == "." || name == ".." {
+		return ""
+	}
+
+	if utf8.RuneCountInString(name) > maxFileNameLength {
+		name = string([]rune(name)[:maxFileNameLength])
+	}
+	return name
+}
+
+// fileMIME нормализует MIME тип файла, указанный клиентом
+func fileMIME(value string) string {
+	mediaType, params, err := mime.ParseMediaType(value)
+	if err != nil || !strings.Contains(mediaType, "/") {
+		return defaultFileMIME
+	}
+
+	formatted := mime.FormatMediaType(mediaType, params)
+	if formatted == "" || len(formatted) > maxFileNameLength {
+		return defaultFileMIME
+	}
+	return formatted
+}
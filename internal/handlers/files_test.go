@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/savo4ka/ares-api/internal/blobstore"
+	"github.com/savo4ka/ares-api/internal/models"
+)
+
+// upload создаёт секрет-файл и возвращает ответ сервера
+func (e *testEnv) upload(t *testing.T, fields map[string]string, content []byte) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	e.router.ServeHTTP(rec, newUploadRequest(t, fields, content))
+	return rec
+}
+
+// newUploadRequest формирует запрос multipart/form-data с полями fields и файлом
+func newUploadRequest(t *testing.T, fields map[string]string, content []byte) *http.Request {
+	t.Helper()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for name, value := range fields {
+		form.WriteField(name, value)
+	}
+	part, err := form.CreateFormFile("file", "report.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(content)
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/secrets", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	return req
+}
+
+// uploadFile создаёт секрет-файл и возвращает его ID
+func (e *testEnv) uploadFile(t *testing.T, content []byte, maxViews string) string {
+	t.Helper()
+
+	rec := e.upload(t, map[string]string{"max_views": maxViews}, content)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("upload: status %d: %s", rec.Code, rec.Body)
+	}
+
+	var created models.CreateSecretResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	return created.ID
+}
+
+// replaceBlob перезаписывает блоб секрета результатом modify
+func (e *testEnv) replaceBlob(t *testing.T, id string, modify func([]byte) []byte) {
+	t.Helper()
+	ctx := context.Background()
+
+	reader, err := e.blobs.Open(ctx, id)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	data, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writer, err := e.blobs.Create(ctx, id)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	writer.Write(modify(data))
+	if err := writer.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+}
+
+func TestFileSecretRoundTrip(t *testing.T) {
+	env := newTestEnv(t)
+	content := bytes.Repeat([]byte("0123456789"), 20000)
+	id := env.uploadFile(t, content, "1")
+
+	rec := env.get(id)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET: status %d: %s", rec.Code, rec.Body)
+	}
+	if !bytes.Equal(rec.Body.Bytes(), content) {
+		t.Errorf("downloaded file differs from the uploaded one")
+	}
+
+	// После последнего просмотра содержимое файла удалено
+	if _, err := env.blobs.Open(context.Background(), id); err != blobstore.ErrBlobNotFound {
+		t.Errorf("blob after the last view: err = %v, want ErrBlobNotFound", err)
+	}
+	if rec := env.get(id); rec.Code != http.StatusGone {
+		t.Errorf("second GET: status %d, want %d", rec.Code, http.StatusGone)
+	}
+}
+
+func TestFileSecretReadFailureDoesNotSpendView(t *testing.T) {
+	tests := []struct {
+		name   string
+		damage func(t *testing.T, env *testEnv, id string)
+	}{
+		{
+			name: "missing blob",
+			damage: func(t *testing.T, env *testEnv, id string) {
+				if err := env.blobs.Delete(context.Background(), id); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name: "corrupted first chunk",
+			damage: func(t *testing.T, env *testEnv, id string) {
+				env.replaceBlob(t, id, func(data []byte) []byte {
+					data[0] ^= 1
+					return data
+				})
+			},
+		},
+		{
+			name: "data after the final chunk",
+			damage: func(t *testing.T, env *testEnv, id string) {
+				env.replaceBlob(t, id, func(data []byte) []byte {
+					return append(data, "garbage"...)
+				})
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			id := env.uploadFile(t, []byte("small file"), "1")
+			tt.damage(t, env, id)
+
+			if rec := env.get(id); rec.Code != http.StatusInternalServerError {
+				t.Fatalf("GET: status %d, want %d", rec.Code, http.StatusInternalServerError)
+			}
+			if views := env.viewsRemaining(t, id); views != 1 {
+				t.Errorf("views_remaining = %d after a failed read, want 1", views)
+			}
+		})
+	}
+}
+
+func TestFileSecretRejectsClientMode(t *testing.T) {
+	env := newTestEnv(t)
+
+	rec := env.upload(t, map[string]string{"encryption_mode": models.EncryptionModeClient}, []byte("data"))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("upload: status %d, want %d: %s", rec.Code, http.StatusBadRequest, rec.Body)
+	}
+
+	// Блоб не создаётся
+	count := 0
+	env.blobs.List(context.Background(), time.Now().Add(time.Hour), func(string) error {
+		count++
+		return nil
+	})
+	if count != 0 {
+		t.Errorf("%d blobs stored for a rejected upload", count)
+	}
+}
+
+// deadlineRecorder запоминает, продлевал ли обработчик таймауты соединения
+type deadlineRecorder struct {
+	*httptest.ResponseRecorder
+	extended bool
+}
+
+func (r *deadlineRecorder) SetReadDeadline(time.Time) error {
+	r.extended = true
+	return nil
+}
+
+func (r *deadlineRecorder) SetWriteDeadline(time.Time) error {
+	r.extended = true
+	return nil
+}
+
+func TestFileUploadExtendsDeadlinesOnlyAfterValidation(t *testing.T) {
+	tests := []struct {
+		name     string
+		fields   map[string]string
+		status   int
+		extended bool
+	}{
+		{"valid upload", map[string]string{"max_views": "1"}, http.StatusCreated, true},
+		{"invalid max_views", map[string]string{"max_views": "100"}, http.StatusBadRequest, false},
+		{"client encryption", map[string]string{"encryption_mode": models.EncryptionModeClient}, http.StatusBadRequest, false},
+		{"invalid ttl", map[string]string{"ttl": "forever"}, http.StatusBadRequest, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			rec := &deadlineRecorder{ResponseRecorder: httptest.NewRecorder()}
+			env.router.ServeHTTP(rec, newUploadRequest(t, tt.fields, []byte("data")))
+
+			if rec.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if rec.extended != tt.extended {
+				t.Errorf("deadlines extended = %t, want %t", rec.extended, tt.extended)
+			}
+		})
+	}
+}
+
+func TestFileUploadRejectsDeclaredOversizeBody(t *testing.T) {
+	env := newTestEnv(t)
+
+	req := newUploadRequest(t, nil, []byte("data"))
+	req.ContentLength = env.cfg.MaxUploadSize + fileFormOverhead + 1
+	rec := &deadlineRecorder{ResponseRecorder: httptest.NewRecorder()}
+	env.router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status %d, want %d", rec.Code, http.StatusRequestEntityTooLarge)
+	}
+	if rec.extended {
+		t.Errorf("deadlines extended for a rejected upload")
+	}
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/savo4ka/ares-api/internal/crypto"
+	"github.com/savo4ka/ares-api/internal/models"
+	"github.com/savo4ka/ares-api/internal/repository"
+)
+
+// RevokeSecret обрабатывает DELETE /api/secrets/{id} - уничтожение секрета
+// создателем по токену управления (заголовок Authorization: Bearer <token>)
+func (h *SecretHandler) RevokeSecret(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	secret, ok := h.authorizeManagement(w, r, id)
+	if !ok {
+		return
+	}
+
+	if err := h.repo.Revoke(r.Context(), id); err != nil {
+		if errors.Is(err, repository.ErrSecretNotFound) {
+			respondWithError(w, http.StatusNotFound, "Secret not found")
+			return
+		}
+		log.Printf("Failed to revoke secret %s: %v", id, err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to revoke secret")
+		return
+	}
+
+	h.metrics.SecretsRevokedTotal.Inc()
+
+	if secret.IsFile() {
+		h.deleteBlob(secret.BlobKey)
+	}
+
+	if count, err := h.repo.GetActiveSecretsCount(r.Context()); err == nil {
+		h.metrics.UpdateActiveSecretsGauge(count)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UpdateSecret обрабатывает PATCH /api/secrets/{id} - продление или сокращение
+// срока действия непрочитанного секрета по токену управления
+func (h *SecretHandler) UpdateSecret(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	var req models.UpdateSecretRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if req.IsEmpty() {
+		respondWithError(w, http.StatusBadRequest, "One of ttl, expiration_hours or expires_at is required")
+		return
+	}
+
+	secret, ok := h.authorizeManagement(w, r, id)
+	if !ok {
+		return
+	}
+
+	if !secret.IsValid() {
+		respondWithError(w, http.StatusGone, "Secret has already been accessed or has expired")
+		return
+	}
+
+	expiresAt, err := h.resolveExpiry(&req.ExpirySpec, secret.CreatedAt)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if secret.AvailableAt != nil && !expiresAt.After(*secret.AvailableAt) {
+		respondWithError(w, http.StatusBadRequest, "Expiration must be after the time the secret becomes available")
+		return
+	}
+
+	if err := h.repo.UpdateExpiry(r.Context(), id, expiresAt); err != nil {
+		if errors.Is(err, repository.ErrSecretUnavailable) {
+			respondWithError(w, http.StatusGone, "Secret has already been accessed or has expired")
+			return
+		}
+		log.Printf("Failed to update expiry of secret %s: %v", id, err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to update secret")
+		return
+	}
+
+	direction := "extend"
+	if expiresAt.Before(secret.ExpiresAt) {
+		direction = "shorten"
+	}
+	h.metrics.ExpiryChangesTotal.WithLabelValues(direction).Inc()
+
+	respondWithJSON(w, http.StatusOK, models.UpdateSecretResponse{
+		ID:        id,
+		ExpiresAt: expiresAt,
+	})
+}
+
+// GetSecretReceipt обрабатывает GET /api/secrets/{id}/receipt - подтверждение
+// прочтения для создателя секрета (по токену управления)
+func (h *SecretHandler) GetSecretReceipt(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	secret, ok := h.authorizeManagement(w, r, id)
+	if !ok {
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, models.SecretReceiptResponse{
+		ID:              secret.ID,
+		Status:          secret.Status(),
+		CreatedAt:       secret.CreatedAt,
+		AvailableAt:     secret.AvailableAt,
+		ExpiresAt:       secret.ExpiresAt,
+		AccessedAt:      secret.AccessedAt,
+		MaxViews:        secret.MaxViews,
+		Views:           secret.MaxViews - secret.ViewsRemaining,
+		ViewsRemaining:  secret.ViewsRemaining,
+		FailedAttempts:  secret.FailedAttempts,
+		ReaderIP:        secret.ReaderIP,
+		ReaderUserAgent: secret.ReaderUserAgent,
+	})
+}
+
+// authorizeManagement проверяет токен управления секретом. При ошибке
+// отправляет ответ клиенту и возвращает false.
+func (h *SecretHandler) authorizeManagement(w http.ResponseWriter, r *http.Request, id string) (*models.Secret, bool) {
+	token := bearerToken(r)
+	if token == "" {
+		respondWithError(w, http.StatusUnauthorized, "Management token is required")
+		return nil, false
+	}
+
+	secret, err := h.repo.GetMetadata(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, repository.ErrSecretNotFound) {
+			respondWithError(w, http.StatusNotFound, "Secret not found")
+			return nil, false
+		}
+		respondWithError(w, http.StatusInternalServerError, "Failed to get secret")
+		return nil, false
+	}
+
+	if !crypto.VerifyManagementToken(token, secret.ManagementTokenHash) {
+		respondWithError(w, http.StatusForbidden, "Invalid management token")
+		return nil, false
+	}
+
+	return secret, true
+}
+
+// bearerToken извлекает токен из заголовка Authorization: Bearer <token>
+func bearerToken(r *http.Request) string {
+	scheme, token, found := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/savo4ka/ares-api/internal/models"
+)
+
+// bearer возвращает заголовок Authorization с токеном управления
+func bearer(token string) map[string]string {
+	return map[string]string{"Authorization": "Bearer " + token}
+}
+
+func TestManagementTokenIsStoredHashed(t *testing.T) {
+	env := newTestEnv(t)
+	created := env.create(t, models.CreateSecretRequest{Content: "секрет"})
+
+	if created.ManagementToken == "" {
+		t.Fatal("create returned no management token")
+	}
+	secret, err := env.store.GetMetadata(context.Background(), created.ID)
+	if err != nil {
+		t.Fatalf("GetMetadata: %v", err)
+	}
+	if secret.ManagementTokenHash == "" || secret.ManagementTokenHash == created.ManagementToken {
+		t.Errorf("stored management token hash = %q, want a hash of the token", secret.ManagementTokenHash)
+	}
+
+	// Хеш не подходит в качестве токена
+	if rec := env.do(t, http.MethodDelete, "/api/secrets/"+created.ID, nil, bearer(secret.ManagementTokenHash)); rec.Code != http.StatusForbidden {
+		t.Errorf("DELETE with the stored hash: status %d, want %d", rec.Code, http.StatusForbidden)
+	}
+}
+
+func TestManagementTokenAuthorization(t *testing.T) {
+	env := newTestEnv(t)
+	created := env.create(t, models.CreateSecretRequest{Content: "секрет"})
+	other := env.create(t, models.CreateSecretRequest{Content: "другой секрет"})
+	path := "/api/secrets/" + created.ID
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		headers map[string]string
+		status  int
+	}{
+		{"receipt without token", http.MethodGet, path + "/receipt", nil, http.StatusUnauthorized},
+		{"receipt with basic auth", http.MethodGet, path + "/receipt", map[string]string{"Authorization": "Basic " + created.ManagementToken}, http.StatusUnauthorized},
+		{"receipt with another secret's token", http.MethodGet, path + "/receipt", bearer(other.ManagementToken), http.StatusForbidden},
+		{"receipt of a missing secret", http.MethodGet, "/api/secrets/missing/receipt", bearer(created.ManagementToken), http.StatusNotFound},
+		{"receipt", http.MethodGet, path + "/receipt", bearer(created.ManagementToken), http.StatusOK},
+		{"revoke without token", http.MethodDelete, path, nil, http.StatusUnauthorized},
+		{"revoke with a wrong token", http.MethodDelete, path, bearer("wrong"), http.StatusForbidden},
+		{"revoke with a lowercase scheme", http.MethodDelete, path, map[string]string{"Authorization": "bearer " + created.ManagementToken}, http.StatusNoContent},
+		{"revoke again", http.MethodDelete, path, bearer(created.ManagementToken), http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		rec := env.do(t, tt.method, tt.path, nil, tt.headers)
+		if rec.Code != tt.status {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, rec.Code, tt.status, rec.Body)
+		}
+	}
+
+	if rec := env.get(created.ID); rec.Code != http.StatusNotFound {
+		t.Errorf("GET of a revoked secret: status %d, want %d", rec.Code, http.StatusNotFound)
+	}
+	if rec := env.get(other.ID); rec.Code != http.StatusOK {
+		t.Errorf("GET of another secret: status %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestManagementRejectsSecretWithoutTokenHash(t *testing.T) {
+	env := newTestEnv(t)
+	now := time.Now()
+	// У секретов, созданных до появления токенов управления, хеша нет
+	err := env.store.Create(context.Background(), &models.Secret{
+		ID:             "legacy",
+		EncryptionMode: models.EncryptionModeServer,
+		MaxViews:       1,
+		ViewsRemaining: 1,
+		ExpiresAt:      now.Add(time.Hour),
+		CreatedAt:      now,
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	if rec := env.do(t, http.MethodDelete, "/api/secrets/legacy", nil, bearer("any")); rec.Code != http.StatusForbidden {
+		t.Errorf("DELETE: status %d, want %d", rec.Code, http.StatusForbidden)
+	}
+}
+
+func TestSecretReceipt(t *testing.T) {
+	env := newTestEnv(t)
+	created := env.create(t, models.CreateSecretRequest{Content: "секрет", MaxViews: 2})
+	path := "/api/secrets/" + created.ID + "/receipt"
+
+	receipt := decode[models.SecretReceiptResponse](t, env.do(t, http.MethodGet, path, nil, bearer(created.ManagementToken)))
+	if receipt.Status != models.SecretStatusActive || receipt.Views != 0 || receipt.ViewsRemaining != 2 || receipt.AccessedAt != nil {
+		t.Errorf("receipt of an unread secret = %+v", receipt)
+	}
+
+	if rec := env.get(created.ID); rec.Code != http.StatusOK {
+		t.Fatalf("GET: status %d: %s", rec.Code, rec.Body)
+	}
+
+	receipt = decode[models.SecretReceiptResponse](t, env.do(t, http.MethodGet, path, nil, bearer(created.ManagementToken)))
+	if receipt.Views != 1 || receipt.ViewsRemaining != 1 || receipt.AccessedAt == nil {
+		t.Errorf("receipt after a read = %+v", receipt)
+	}
+}
+
+func TestUpdateSecretExpiry(t *testing.T) {
+	env := newTestEnv(t)
+	created := env.create(t, models.CreateSecretRequest{Content: "секрет", ExpirySpec: models.ExpirySpec{TTL: "1h"}})
+	path := "/api/secrets/" + created.ID
+	token := bearer(created.ManagementToken)
+
+	rec := env.do(t, http.MethodPatch, path, models.UpdateSecretRequest{ExpirySpec: models.ExpirySpec{TTL: "12h"}}, token)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("extend: status %d: %s", rec.Code, rec.Body)
+	}
+	extended := decode[models.UpdateSecretResponse](t, rec)
+	if extended.ID != created.ID || extended.ExpiresAt.Sub(created.ExpiresAt) < 10*time.Hour {
+		t.Errorf("extend: response %+v, created expires_at %v", extended, created.ExpiresAt)
+	}
+
+	rec = env.do(t, http.MethodPatch, path, models.UpdateSecretRequest{ExpirySpec: models.ExpirySpec{TTL: "10m"}}, token)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("shorten: status %d: %s", rec.Code, rec.Body)
+	}
+	shortened := decode[models.UpdateSecretResponse](t, rec)
+	if !shortened.ExpiresAt.Before(extended.ExpiresAt) {
+		t.Errorf("shorten: expires_at %v is not before %v", shortened.ExpiresAt, extended.ExpiresAt)
+	}
+
+	secret, err := env.store.GetMetadata(context.Background(), created.ID)
+	if err != nil {
+		t.Fatalf("GetMetadata: %v", err)
+	}
+	if !secret.ExpiresAt.Equal(shortened.ExpiresAt) {
+		t.Errorf("stored expires_at = %v, want %v", secret.ExpiresAt, shortened.ExpiresAt)
+	}
+}
+
+func TestUpdateSecretRejectsInvalidExpiry(t *testing.T) {
+	env := newTestEnv(t)
+	created := env.create(t, models.CreateSecretRequest{Content: "секрет"})
+	path := "/api/secrets/" + created.ID
+	token := bearer(created.ManagementToken)
+	past := time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		name    string
+		body    any
+		headers map[string]string
+		status  int
+	}{
+		{"empty body", models.UpdateSecretRequest{}, token, http.StatusBadRequest},
+		{"invalid payload", "12h", token, http.StatusBadRequest},
+		{"invalid ttl", models.UpdateSecretRequest{ExpirySpec: models.ExpirySpec{TTL: "forever"}}, token, http.StatusBadRequest},
+		{"below minimum", models.UpdateSecretRequest{ExpirySpec: models.ExpirySpec{TTL: "30s"}}, token, http.StatusBadRequest},
+		{"above maximum", models.UpdateSecretRequest{ExpirySpec: models.ExpirySpec{TTL: "25h"}}, token, http.StatusBadRequest},
+		{"in the past", models.UpdateSecretRequest{ExpirySpec: models.ExpirySpec{ExpiresAt: &past}}, token, http.StatusBadRequest},
+		{"without token", models.UpdateSecretRequest{ExpirySpec: models.ExpirySpec{TTL: "2h"}}, nil, http.StatusUnauthorized},
+		{"wrong token", models.UpdateSecretRequest{ExpirySpec: models.ExpirySpec{TTL: "2h"}}, bearer("wrong"), http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		rec := env.do(t, http.MethodPatch, path, tt.body, tt.headers)
+		if rec.Code != tt.status {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, rec.Code, tt.status, rec.Body)
+		}
+	}
+
+	secret, err := env.store.GetMetadata(context.Background(), created.ID)
+	if err != nil {
+		t.Fatalf("GetMetadata: %v", err)
+	}
+	if !secret.ExpiresAt.Equal(created.ExpiresAt) {
+		t.Errorf("expires_at changed to %v by rejected updates, want %v", secret.ExpiresAt, created.ExpiresAt)
+	}
+}
+
+func TestUpdateSecretMaxTTLCountsFromCreation(t *testing.T) {
+	env := newTestEnv(t)
+	created := env.create(t, models.CreateSecretRequest{Content: "секрет"})
+	path := "/api/secrets/" + created.ID
+	token := bearer(created.ManagementToken)
+
+	// Секрет создан 20 часов назад: продлить его можно лишь до CreatedAt + MAX_TTL
+	secret, err := env.store.GetMetadata(context.Background(), created.ID)
+	if err != nil {
+		t.Fatalf("GetMetadata: %v", err)
+	}
+	if err := env.store.Revoke(context.Background(), created.ID); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	secret.CreatedAt = time.Now().Add(-20 * time.Hour)
+	if err := env.store.Create(context.Background(), secret); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	if rec := env.do(t, http.MethodPatch, path, models.UpdateSecretRequest{ExpirySpec: models.ExpirySpec{TTL: "5h"}}, token); rec.Code != http.StatusBadRequest {
+		t.Errorf("extend past MAX_TTL from creation: status %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	if rec := env.do(t, http.MethodPatch, path, models.UpdateSecretRequest{ExpirySpec: models.ExpirySpec{TTL: "3h"}}, token); rec.Code != http.StatusOK {
+		t.Errorf("extend within MAX_TTL from creation: status %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestUpdateSecretRejectsUnreadableSecret(t *testing.T) {
+	env := newTestEnv(t)
+	update := models.UpdateSecretRequest{ExpirySpec: models.ExpirySpec{TTL: "2h"}}
+
+	read := env.create(t, models.CreateSecretRequest{Content: "секрет"})
+	if rec := env.get(read.ID); rec.Code != http.StatusOK {
+		t.Fatalf("GET: status %d: %s", rec.Code, rec.Body)
+	}
+	if rec := env.do(t, http.MethodPatch, "/api/secrets/"+read.ID, update, bearer(read.ManagementToken)); rec.Code != http.StatusGone {
+		t.Errorf("PATCH of a read secret: status %d, want %d", rec.Code, http.StatusGone)
+	}
+
+	// Срок действия должен оставаться позже available_at
+	availableAt := time.Now().Add(3 * time.Hour)
+	pending := env.create(t, models.CreateSecretRequest{Content: "секрет", AvailableAt: &availableAt, ExpirySpec: models.ExpirySpec{TTL: "6h"}})
+	if rec := env.do(t, http.MethodPatch, "/api/secrets/"+pending.ID, update, bearer(pending.ManagementToken)); rec.Code != http.StatusBadRequest {
+		t.Errorf("PATCH before available_at: status %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	if rec := env.do(t, http.MethodPatch, "/api/secrets/"+pending.ID, models.UpdateSecretRequest{ExpirySpec: models.ExpirySpec{TTL: "4h"}}, bearer(pending.ManagementToken)); rec.Code != http.StatusOK {
+		t.Errorf("PATCH after available_at: status %d: %s", rec.Code, rec.Body)
+	}
+}
@@ -39,8 +39,9 @@ func CORSMiddleware(allowedOrigins string) func(http.Handler) http.Handler {
 				}
 			}
 
-			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, POST, PATCH, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Secret-Key, X-Secret-Passphrase")
+			w.Header().Set("Access-Control-Expose-Headers", "X-Secret-Status, X-Secret-Expires-At, X-Secret-Views-Remaining, X-Secret-Available-At, Content-Disposition")
 			w.Header().Set("Access-Control-Max-Age", "3600")
 
 			// Обработка preflight запросов
@@ -77,6 +78,11 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
+// Unwrap возвращает исходный http.ResponseWriter (нужен http.ResponseController)
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 // MetricsMiddleware отслеживает метрики HTTP запросов
 func MetricsMiddleware(m *metrics.Metrics) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/savo4ka/ares-api/internal/config"
+	"github.com/savo4ka/ares-api/internal/models"
+)
+
+// maxUserAgentLength - максимальная длина сохраняемого User-Agent (размер колонки)
+const maxUserAgentLength = 512
+
+// readerInfo собирает сведения о получателе секрета в соответствии с настройкой READER_INFO
+func (h *SecretHandler) readerInfo(r *http.Request) models.ReaderInfo {
+	if h.cfg.ReaderInfo == config.ReaderInfoNone {
+		return models.ReaderInfo{}
+	}
+
+	ip := clientIP(r, h.cfg.TrustProxy)
+	if h.cfg.ReaderInfo == config.ReaderInfoAnonymized {
+		ip = anonymizeIP(ip)
+	}
+
+	return models.ReaderInfo{
+		IP:        ip,
+		UserAgent: truncateUTF8(r.UserAgent(), maxUserAgentLength),
+	}
+}
+
+// truncateUTF8 обрезает строку до maxBytes байт, не разрывая многобайтовые
+// символы. Некорректные последовательности UTF-8 удаляются: PostgreSQL их не примет.
+func truncateUTF8(value string, maxBytes int) string {
+	value = strings.ToValidUTF8(value, "")
+	if len(value) <= maxBytes {
+		return value
+	}
+
+	end := maxBytes
+	for end > 0 && !utf8.RuneStart(value[end]) {
+		end--
+	}
+	return value[:end]
+}
+
+// clientIP возвращает IP клиента. X-Forwarded-For учитывается, только если
+// сервис работает за доверенным прокси, иначе заголовок легко подделать.
+func clientIP(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			if ip := net.ParseIP(strings.TrimSpace(first)); ip != nil {
+				return ip.String()
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String()
+	}
+	return ""
+}
+
+// anonymizeIP обнуляет адрес хоста: оставляет сеть /24 для IPv4 и /48 для IPv6
+func anonymizeIP(value string) string {
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return ""
+	}
+
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return ip.Mask(net.CIDRMask(48, 128)).String()
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/savo4ka/ares-api/internal/config"
+)
+
+func TestTruncateUTF8(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		maxBytes int
+		want     string
+	}{
+		{"short", "curl/8.0", 16, "curl/8.0"},
+		{"exact", "abcd", 4, "abcd"},
+		{"ascii", "abcdef", 4, "abcd"},
+		{"cut inside a two-byte rune", "abcЖ", 4, "abc"},
+		{"cut after a two-byte rune", "abЖd", 4, "abЖ"},
+		{"cut inside a four-byte rune", "a😀", 4, "a"},
+		{"invalid bytes dropped", "ab\xffcd", 4, "abcd"},
+	}
+
+	for _, tt := range tests {
+		got := truncateUTF8(tt.value, tt.maxBytes)
+		if got != tt.want {
+			t.Errorf("%s: truncateUTF8(%q, %d) = %q, want %q", tt.name, tt.value, tt.maxBytes, got, tt.want)
+		}
+	}
+}
+
+func TestReaderInfoTruncatesUserAgent(t *testing.T) {
+	h := &SecretHandler{cfg: &config.Config{ReaderInfo: config.ReaderInfoFull}}
+
+	// Двухбайтовый символ попадает на границу maxUserAgentLength
+	userAgent := "a" + strings.Repeat("Ж", maxUserAgentLength)
+	req := httptest.NewRequest(http.MethodGet, "/api/secrets/id", nil)
+	req.Header.Set("User-Agent", userAgent)
+
+	got := h.readerInfo(req).UserAgent
+	if len(got) > maxUserAgentLength || !utf8.ValidString(got) {
+		t.Errorf("user agent of %d bytes, valid UTF-8 = %t", len(got), utf8.ValidString(got))
+	}
+	if !strings.HasPrefix(userAgent, got) || len(got) != maxUserAgentLength-1 {
+		t.Errorf("user agent truncated to %d bytes, want %d", len(got), maxUserAgentLength-1)
+	}
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/savo4ka/ares-api/internal/models"
+)
+
+// defaultBlockedUserAgents - подстроки User-Agent ботов, которые открывают ссылки
+// для предпросмотра в мессенджерах и соцсетях
+var defaultBlockedUserAgents = []string{
+	"slackbot",
+	"slack-imgproxy",
+	"skypeuripreview", // Microsoft Teams и Skype
+	"microsoftpreview",
+	"telegrambot",
+	"whatsapp",
+	"discordbot",
+	"twitterbot",
+	"facebookexternalhit",
+	"facebot",
+	"linkedinbot",
+	"mattermost-bot",
+	"vkshare",
+	"viber",
+	"bingpreview",
+	"embedly",
+	"iframely",
+}
+
+// RevealSecret обрабатывает POST /api/secrets/{id}/reveal - открытие секрета
+// по nonce, полученному из GET /api/secrets/{id}
+func (h *SecretHandler) RevealSecret(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	if h.rejectLinkPreviewBot(w, r) {
+		return
+	}
+
+	var req models.RevealSecretRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if req.Nonce == "" {
+		respondWithError(w, http.StatusBadRequest, "Nonce is required")
+		return
+	}
+
+	secret, ok := h.loadReadableSecret(w, r, id)
+	if !ok {
+		return
+	}
+
+	if !h.verifyRevealNonce(id, req.Nonce, time.Now()) {
+		respondWithError(w, http.StatusForbidden, "Invalid or expired nonce")
+		return
+	}
+
+	// Парольную фразу и ключ из ссылки можно передать в теле запроса или в заголовках
+	passphrase := req.Passphrase
+	if passphrase == "" {
+		passphrase = r.Header.Get("X-Secret-Passphrase")
+	}
+	linkKey := req.Key
+	if linkKey == "" {
+		linkKey = r.Header.Get("X-Secret-Key")
+	}
+
+	h.revealSecret(w, r, secret, passphrase, linkKey)
+}
+
+// respondWithRevealChallenge отвечает nonce для открытия секрета, не расшифровывая его
+func (h *SecretHandler) respondWithRevealChallenge(w http.ResponseWriter, secret *models.Secret) {
+	nonceExpiresAt := time.Now().Add(h.cfg.RevealNonceTTL)
+
+	respondWithJSON(w, http.StatusOK, models.RevealChallengeResponse{
+		RevealRequired:     true,
+		Nonce:              h.issueRevealNonce(secret.ID, nonceExpiresAt),
+		NonceExpiresAt:     nonceExpiresAt,
+		RequiresPassphrase: secret.PassphraseKDF != "",
+		EncryptionMode:     secret.EncryptionMode,
+		File:               secret.File(),
+		ViewsRemaining:     secret.ViewsRemaining,
+		ExpiresAt:          secret.ExpiresAt,
+	})
+}
+
+// issueRevealNonce выдаёт nonce вида "<unix-время истечения>.<HMAC>", привязанный
+// к ID секрета. Nonce не хранится на сервере: он проверяется по HMAC.
+func (h *SecretHandler) issueRevealNonce(id string, expiresAt time.Time) string {
+	expiry := strconv.FormatInt(expiresAt.Unix(), 10)
+	return expiry + "." + base64.RawURLEncoding.EncodeToString(h.revealMAC(id, expiry))
+}
+
+// verifyRevealNonce проверяет подпись и срок действия nonce
+func (h *SecretHandler) verifyRevealNonce(id, nonce string, now time.Time) bool {
+	expiry, signature, found := strings.Cut(nonce, ".")
+	if !found {
+		return false
+	}
+
+	expiresAt, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil || now.Unix() > expiresAt {
+		return false
+	}
+
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	return hmac.Equal(mac, h.revealMAC(id, expiry))
+}
+
+// revealMAC вычисляет HMAC-SHA256 от ID секрета и времени истечения nonce
+func (h *SecretHandler) revealMAC(id, expiry string) []byte {
+	mac := hmac.New(sha256.New, h.revealKey)
+	mac.Write([]byte("reveal|" + id + "|" + expiry))
+	return mac.Sum(nil)
+}
+
+// rejectLinkPreviewBot отвечает 403 ботам предпросмотра ссылок и возвращает true,
+// если запрос отклонён
+func (h *SecretHandler) rejectLinkPreviewBot(w http.ResponseWriter, r *http.Request) bool {
+	userAgent := strings.ToLower(r.UserAgent())
+	for _, blocked := range h.blockedUserAgents {
+		if strings.Contains(userAgent, blocked) {
+			h.metrics.BotRequestsBlockedTotal.Inc()
+			respondWithError(w, http.StatusForbidden, "Link previews are not supported for secrets")
+			return true
+		}
+	}
+	return false
+}
+
+// newRevealKey возвращает ключ HMAC для nonce. Без REVEAL_SECRET ключ генерируется
+// при запуске: nonce, выданные до перезапуска или другим экземпляром, недействительны.
+func newRevealKey(secret string) ([]byte, error) {
+	if secret != "" {
+		return []byte(secret), nil
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate reveal key: %w", err)
+	}
+	return key, nil
+}
+
+// blockedUserAgents объединяет встроенный список ботов с BLOCKED_USER_AGENTS
+func blockedUserAgents(extra []string) []string {
+	agents := append([]string{}, defaultBlockedUserAgents...)
+	for _, agent := range extra {
+		agents = append(agents, strings.ToLower(agent))
+	}
+	return agents
+}
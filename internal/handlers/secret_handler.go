@@ -1,39 +1,81 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"mime"
 	"net/http"
+	"net/mail"
+	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
+	"github.com/savo4ka/ares-api/internal/blobstore"
+	"github.com/savo4ka/ares-api/internal/config"
 	"github.com/savo4ka/ares-api/internal/crypto"
 	"github.com/savo4ka/ares-api/internal/metrics"
 	"github.com/savo4ka/ares-api/internal/models"
+	"github.com/savo4ka/ares-api/internal/notify"
 	"github.com/savo4ka/ares-api/internal/repository"
 )
 
+const (
+	// maxPassphraseLength - максимальная длина парольной фразы в байтах
+	maxPassphraseLength = 1024
+
+	// maxWebhookURLLength - максимальная длина URL для уведомлений
+	maxWebhookURLLength = 2048
+
+	// maxEmailLength - максимальная длина адреса электронной почты
+	maxEmailLength = 254
+)
+
 // SecretHandler обрабатывает HTTP запросы для работы с секретами
 type SecretHandler struct {
-	repo              *repository.SecretRepository
+	repo              repository.SecretStore
+	blobs             blobstore.BlobStore
 	encryptionService *crypto.EncryptionService
 	baseURL           string
+	cfg               *config.Config
 	metrics           *metrics.Metrics
+	notifier          notify.Notifier
+	revealKey         []byte   // Ключ HMAC для nonce двухшагового открытия
+	blockedUserAgents []string // Подстроки User-Agent ботов предпросмотра (в нижнем регистре)
 }
 
 // NewSecretHandler создаёт новый обработчик секретов
-func NewSecretHandler(repo *repository.SecretRepository, encryptionService *crypto.EncryptionService, baseURL string, m *metrics.Metrics) *SecretHandler {
+func NewSecretHandler(repo repository.SecretStore, blobs blobstore.BlobStore, encryptionService *crypto.EncryptionService, baseURL string, cfg *config.Config, m *metrics.Metrics, notifier notify.Notifier) (*SecretHandler, error) {
+	revealKey, err := newRevealKey(cfg.RevealSecret)
+	if err != nil {
+		return nil, err
+	}
+
 	return &SecretHandler{
 		repo:              repo,
+		blobs:             blobs,
 		encryptionService: encryptionService,
 		baseURL:           baseURL,
+		cfg:               cfg,
 		metrics:           m,
-	}
+		notifier:          notifier,
+		revealKey:         revealKey,
+		blockedUserAgents: blockedUserAgents(cfg.BlockedUserAgents),
+	}, nil
 }
 
-// CreateSecret обрабатывает POST /api/secrets - создание нового секрета
+// CreateSecret обрабатывает POST /api/secrets - создание нового секрета.
+// Запрос multipart/form-data создаёт секрет-файл (см. createFileSecret).
 func (h *SecretHandler) CreateSecret(w http.ResponseWriter, r *http.Request) {
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+		h.createFileSecret(w, r)
+		return
+	}
+
 	var req models.CreateSecretRequest
 
 	// Парсим JSON из тела запроса
@@ -48,57 +90,218 @@ func (h *SecretHandler) CreateSecret(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.ExpirationHours != 24 && req.ExpirationHours != 48 && req.ExpirationHours != 72 {
-		respondWithError(w, http.StatusBadRequest, "Expiration hours must be 24, 48, or 72")
+	expiresAt, ok := h.validateCreateRequest(w, &req)
+	if !ok {
 		return
 	}
 
+	secret, managementToken, linkKey, ok := h.sealSecret(w, r, &req, req.Content, expiresAt)
+	if !ok {
+		return
+	}
+
+	// Сохраняем в базу данных
+	if err := h.repo.Create(r.Context(), secret); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to create secret")
+		return
+	}
+
+	h.completeCreate(w, r, &req, secret, managementToken, linkKey)
+}
+
+// validateCreateRequest проверяет параметры создания секрета (кроме контента),
+// подставляет значения по умолчанию и возвращает время истечения.
+// При ошибке отправляет ответ клиенту и возвращает false.
+func (h *SecretHandler) validateCreateRequest(w http.ResponseWriter, req *models.CreateSecretRequest) (time.Time, bool) {
+	expiresAt, err := h.resolveExpiry(&req.ExpirySpec, time.Time{})
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return time.Time{}, false
+	}
+
+	// Время активации в прошлом равносильно его отсутствию
+	if req.AvailableAt != nil && !req.AvailableAt.After(time.Now()) {
+		req.AvailableAt = nil
+	}
+
+	if req.AvailableAt != nil && !req.AvailableAt.Before(expiresAt) {
+		respondWithError(w, http.StatusBadRequest, "Available at must be before the expiration time")
+		return time.Time{}, false
+	}
+
+	if req.MaxViews == 0 {
+		req.MaxViews = 1
+	}
+
+	if req.MaxViews < 1 || req.MaxViews > h.cfg.MaxViews {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Max views must be between 1 and %d", h.cfg.MaxViews))
+		return time.Time{}, false
+	}
+
+	if len(req.Passphrase) > maxPassphraseLength {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Passphrase must be at most %d characters", maxPassphraseLength))
+		return time.Time{}, false
+	}
+
+	if req.NotifyWebhookURL != "" {
+		if !h.cfg.WebhooksEnabled() {
+			respondWithError(w, http.StatusBadRequest, "Webhook notifications are not enabled")
+			return time.Time{}, false
+		}
+		if err := validateWebhookURL(req.NotifyWebhookURL); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return time.Time{}, false
+		}
+	} else if h.cfg.WebhooksEnabled() {
+		req.NotifyWebhookURL = h.cfg.WebhookDefaultURL
+	}
+
+	if req.RecipientEmail != "" || req.NotifyEmail != "" {
+		if !h.notifier.Enabled() {
+			respondWithError(w, http.StatusBadRequest, "Email notifications are not enabled")
+			return time.Time{}, false
+		}
+		if req.RecipientEmail, err = normalizeEmail(req.RecipientEmail); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid recipient_email")
+			return time.Time{}, false
+		}
+		if req.NotifyEmail, err = normalizeEmail(req.NotifyEmail); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid notify_email")
+			return time.Time{}, false
+		}
+	}
+
+	switch req.EncryptionMode {
+	case "":
+		req.EncryptionMode = models.EncryptionModeServer
+	case models.EncryptionModeServer, models.EncryptionModeClient, models.EncryptionModeLinkKey:
+	default:
+		respondWithError(w, http.StatusBadRequest, "Encryption mode must be server, client, or link_key")
+		return time.Time{}, false
+	}
+
+	return expiresAt, true
+}
+
+// sealSecret шифрует контент всеми слоями, выбранными в запросе, и формирует
+// модель секрета. Возвращает также токен управления и ключ из ссылки (для link_key).
+// При ошибке отправляет ответ клиенту и возвращает false.
+func (h *SecretHandler) sealSecret(w http.ResponseWriter, r *http.Request, req *models.CreateSecretRequest, content string, expiresAt time.Time) (*models.Secret, string, string, bool) {
+	// ID секрета связывается с шифртекстом как associated data
+	id := uuid.New().String()
+
+	// В режиме link_key контент сначала шифруется одноразовым ключом, который
+	// попадает только во фрагмент ссылки; в режиме client контент уже зашифрован клиентом
+	payload := content
+	var linkKey string
+
+	if req.EncryptionMode == models.EncryptionModeLinkKey {
+		sealed, key, err := crypto.SealWithLinkKey(content, id)
+		if err != nil {
+			h.metrics.EncryptionErrorsTotal.Inc()
+			respondWithError(w, http.StatusInternalServerError, "Failed to encrypt secret")
+			return nil, "", "", false
+		}
+		payload, linkKey = sealed, key
+	}
+
+	// Парольная фраза добавляет ещё один слой шифрования поверх режима шифрования
+	var passphraseKDF string
+	if req.Passphrase != "" {
+		sealed, kdf, err := crypto.SealWithPassphrase(payload, req.Passphrase, id, crypto.DefaultArgon2Params)
+		if err != nil {
+			h.metrics.EncryptionErrorsTotal.Inc()
+			respondWithError(w, http.StatusInternalServerError, "Failed to encrypt secret")
+			return nil, "", "", false
+		}
+		payload, passphraseKDF = sealed, kdf
+	}
+
+	// Токен управления показывается создателю один раз, в БД хранится только хеш
+	managementToken, err := crypto.GenerateManagementToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to create secret")
+		return nil, "", "", false
+	}
+
 	// Шифруем контент
-	encryptedData, err := h.encryptionService.Encrypt(req.Content)
+	encryptedData, err := h.encryptionService.Encrypt(r.Context(), payload, id)
 	if err != nil {
 		h.metrics.EncryptionErrorsTotal.Inc()
 		respondWithError(w, http.StatusInternalServerError, "Failed to encrypt secret")
-		return
+		return nil, "", "", false
 	}
 
 	// Создаём модель секрета
 	secret := &models.Secret{
-		ID:               uuid.New().String(),
-		EncryptedContent: encryptedData.Ciphertext,
-		IV:               encryptedData.IV,
-		ExpiresAt:        time.Now().Add(time.Duration(req.ExpirationHours) * time.Hour),
-		CreatedAt:        time.Now(),
-		IsAccessed:       false,
+		ID:                  id,
+		EncryptedContent:    encryptedData.Ciphertext,
+		IV:                  encryptedData.IV,
+		KeyID:               encryptedData.KeyID,
+		WrappedKey:          encryptedData.WrappedKey,
+		EncryptionMode:      req.EncryptionMode,
+		PassphraseKDF:       passphraseKDF,
+		ManagementTokenHash: crypto.HashManagementToken(managementToken),
+		NotifyWebhookURL:    req.NotifyWebhookURL,
+		NotifyEmail:         req.NotifyEmail,
+		MaxViews:            req.MaxViews,
+		ViewsRemaining:      req.MaxViews,
+		AvailableAt:         req.AvailableAt,
+		ExpiresAt:           expiresAt,
+		CreatedAt:           time.Now(),
+		IsAccessed:          false,
 	}
 
-	// Сохраняем в базу данных
-	if err := h.repo.Create(secret); err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Failed to create secret")
-		return
-	}
+	return secret, managementToken, linkKey, true
+}
 
+// completeCreate обновляет метрики, отправляет ссылку получателю и отвечает
+// создателю после сохранения секрета
+func (h *SecretHandler) completeCreate(w http.ResponseWriter, r *http.Request, req *models.CreateSecretRequest, secret *models.Secret, managementToken, linkKey string) {
 	// Инкрементируем метрику созданных секретов
 	h.metrics.SecretsCreatedTotal.Inc()
 
 	// Обновляем метрику активных секретов
-	if count, err := h.repo.GetActiveSecretsCount(); err == nil {
+	if count, err := h.repo.GetActiveSecretsCount(r.Context()); err == nil {
 		h.metrics.UpdateActiveSecretsGauge(count)
 	}
 
-	// Формируем URL для доступа к секрету
+	// Формируем URL для доступа к секрету. Ключ из фрагмента (#...) браузер не отправляет
+	// на сервер: UI передаёт его в заголовке X-Secret-Key при чтении секрета
 	secretURL := fmt.Sprintf("%s/secret/%s", h.baseURL, secret.ID)
+	if linkKey != "" {
+		secretURL += "#" + linkKey
+	}
 
 	// Возвращаем ответ
 	response := models.CreateSecretResponse{
-		ID:        secret.ID,
-		URL:       secretURL,
-		ExpiresAt: secret.ExpiresAt,
+		ID:              secret.ID,
+		URL:             secretURL,
+		ManagementToken: managementToken,
+		MaxViews:        secret.MaxViews,
+		AvailableAt:     secret.AvailableAt,
+		File:            secret.File(),
+		ExpiresAt:       secret.ExpiresAt,
+	}
+
+	// Отправляем ссылку получателю. Секрет уже создан, поэтому ошибка отправки
+	// не отменяет запрос: создатель узнает о ней из recipient_emailed
+	if req.RecipientEmail != "" {
+		sent := h.sendEmail("secret_link", h.notifier.SendSecretLink(r.Context(), notify.SecretLink{
+			To:        req.RecipientEmail,
+			URL:       secretURL,
+			ExpiresAt: secret.ExpiresAt,
+			MaxViews:  secret.MaxViews,
+		}))
+		response.RecipientEmailed = &sent
 	}
 
 	respondWithJSON(w, http.StatusCreated, response)
 }
 
-// GetSecret обрабатывает GET /api/secrets/:id - получение секрета
+// GetSecret обрабатывает GET /api/secrets/:id - получение секрета.
+// Если включено двухшаговое открытие (REVEAL_REQUIRED), вместо контента
+// возвращается nonce для POST /api/secrets/{id}/reveal.
 func (h *SecretHandler) GetSecret(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
@@ -108,64 +311,405 @@ func (h *SecretHandler) GetSecret(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Боты предпросмотра ссылок никогда не получают секрет и не тратят просмотр
+	if h.rejectLinkPreviewBot(w, r) {
+		return
+	}
+
+	secret, ok := h.loadReadableSecret(w, r, id)
+	if !ok {
+		return
+	}
+
+	if h.cfg.RevealRequired {
+		h.respondWithRevealChallenge(w, secret)
+		return
+	}
+
+	h.revealSecret(w, r, secret, r.Header.Get("X-Secret-Passphrase"), r.Header.Get("X-Secret-Key"))
+}
+
+// loadReadableSecret получает секрет из БД и проверяет, что его ещё можно прочитать.
+// При ошибке отправляет ответ клиенту и возвращает false.
+func (h *SecretHandler) loadReadableSecret(w http.ResponseWriter, r *http.Request, id string) (*models.Secret, bool) {
 	// Получаем секрет из БД
-	secret, err := h.repo.GetByID(id)
+	secret, err := h.repo.GetByID(r.Context(), id)
 	if err != nil {
-		respondWithError(w, http.StatusNotFound, "Secret not found")
-		return
+		if errors.Is(err, repository.ErrSecretNotFound) {
+			respondWithError(w, http.StatusNotFound, "Secret not found")
+			return nil, false
+		}
+		respondWithError(w, http.StatusInternalServerError, "Failed to get secret")
+		return nil, false
 	}
 
 	// Проверяем, не истёк ли срок действия
 	if secret.IsExpired() {
 		h.metrics.SecretsExpiredReadTotal.Inc()
 		respondWithError(w, http.StatusGone, "Secret has expired")
-		return
+		return nil, false
+	}
+
+	// Сожжённый после неверных парольных фраз секрет хранится как прочитанный
+	if secret.IsAccessed && secret.FailedAttempts > 0 && secret.FailedAttempts >= h.cfg.PassphraseMaxAttempts {
+		respondWithError(w, http.StatusGone, "Secret has been destroyed after too many failed passphrase attempts")
+		return nil, false
 	}
 
 	// Проверяем, не был ли уже прочитан
 	if secret.IsAccessed {
 		h.metrics.SecretsAlreadyReadTotal.Inc()
 		respondWithError(w, http.StatusGone, "Secret has already been accessed")
-		return
+		return nil, false
 	}
 
-	// Расшифровываем контент
-	encryptedData := &crypto.EncryptedData{
-		Ciphertext: secret.EncryptedContent,
-		IV:         secret.IV,
+	// Проверяем, наступило ли время активации
+	if !secret.IsAvailable() {
+		h.metrics.SecretsEarlyReadTotal.Inc()
+		respondWithJSON(w, http.StatusTooEarly, map[string]interface{}{
+			"error":        "Secret is not available yet",
+			"available_at": secret.AvailableAt,
+		})
+		return nil, false
 	}
 
-	plaintext, err := h.encryptionService.Decrypt(encryptedData)
-	if err != nil {
-		h.metrics.DecryptionErrorsTotal.Inc()
-		respondWithError(w, http.StatusInternalServerError, "Failed to decrypt secret")
+	return secret, true
+}
+
+// revealSecret расшифровывает секрет, засчитывает просмотр и отправляет контент клиенту
+func (h *SecretHandler) revealSecret(w http.ResponseWriter, r *http.Request, secret *models.Secret, passphrase, linkKey string) {
+	// Расшифровываем контент до того, как пометить секрет прочитанным:
+	// неверная парольная фраза или ключ из ссылки не должны сжигать секрет
+	plaintext, ok := h.unlockSecret(w, r, secret, passphrase, linkKey)
+	if !ok {
 		return
 	}
 
-	// Помечаем секрет как прочитанный
-	if err := h.repo.MarkAsAccessed(id); err != nil {
-		// Логируем ошибку, но не возвращаем пользователю, т.к. секрет уже расшифрован
-		fmt.Printf("Warning: failed to mark secret as accessed: %v\n", err)
+	// Расшифрованный контент секрета-файла - его ключ. Файл открывается заранее
+	// по той же причине: ошибка чтения файла не должна тратить просмотр
+	var file *fileDownload
+	if secret.IsFile() {
+		if file, ok = h.openFile(w, r, secret, plaintext); !ok {
+			return
+		}
+		defer file.body.Close()
+	}
+
+	// Атомарно засчитываем просмотр: из параллельных запросов контент получат
+	// не больше, чем осталось просмотров
+	claimed, err := h.repo.Claim(r.Context(), secret.ID, h.readerInfo(r))
+	if err != nil {
+		if errors.Is(err, repository.ErrSecretUnavailable) {
+			h.metrics.SecretsAlreadyReadTotal.Inc()
+			respondWithError(w, http.StatusGone, "Secret has already been accessed")
+			return
+		}
+		log.Printf("Failed to claim secret %s: %v", secret.ID, err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to read secret")
+		return
 	}
 
 	// Инкрементируем метрику успешно прочитанных секретов
 	h.metrics.SecretsReadTotal.Inc()
 
+	// Письмо о прочтении отправляется в фоне, чтобы не задерживать ответ получателю
+	if claimed.NotifyEmail != "" {
+		go func() {
+			h.sendEmail("secret_opened", h.notifier.SendSecretOpened(context.Background(), notify.SecretOpened{
+				To:             claimed.NotifyEmail,
+				SecretID:       claimed.ID,
+				OpenedAt:       time.Now(),
+				ViewsRemaining: claimed.ViewsRemaining,
+			}))
+		}()
+	}
+
 	// Обновляем метрику активных секретов
-	if count, err := h.repo.GetActiveSecretsCount(); err == nil {
+	if count, err := h.repo.GetActiveSecretsCount(r.Context()); err == nil {
 		h.metrics.UpdateActiveSecretsGauge(count)
 	}
 
+	// Файл передаётся потоком
+	if file != nil {
+		h.streamFile(w, secret, file, claimed.ViewsRemaining)
+		return
+	}
+
 	// Возвращаем расшифрованный контент
 	response := models.GetSecretResponse{
-		Content:   plaintext,
-		ExpiresAt: secret.ExpiresAt,
-		CreatedAt: secret.CreatedAt,
+		Content:        plaintext,
+		EncryptionMode: secret.EncryptionMode,
+		ViewsRemaining: claimed.ViewsRemaining,
+		ExpiresAt:      claimed.ExpiresAt,
+		CreatedAt:      claimed.CreatedAt,
 	}
 
 	respondWithJSON(w, http.StatusOK, response)
 }
 
+// GetSecretStatus обрабатывает GET /api/secrets/{id}/status - состояние секрета
+// без расшифровки и без траты просмотра
+func (h *SecretHandler) GetSecretStatus(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	secret, err := h.repo.GetMetadata(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, repository.ErrSecretNotFound) {
+			respondWithJSON(w, http.StatusNotFound, models.SecretStatusResponse{ID: id})
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Failed to get secret status")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, models.SecretStatusResponse{
+		ID:                 secret.ID,
+		Exists:             true,
+		Status:             secret.Status(),
+		Expired:            secret.IsExpired(),
+		Accessed:           secret.IsAccessed,
+		ViewsRemaining:     secret.ViewsRemaining,
+		RequiresPassphrase: secret.PassphraseKDF != "",
+		EncryptionMode:     secret.EncryptionMode,
+		File:               secret.File(),
+		AvailableAt:        secret.AvailableAt,
+		ExpiresAt:          &secret.ExpiresAt,
+		CreatedAt:          &secret.CreatedAt,
+	})
+}
+
+// HeadSecret обрабатывает HEAD /api/secrets/{id} - проверка ссылки без тела ответа:
+// 200 - секрет можно прочитать, 404 - не найден, 410 - истёк или прочитан,
+// 425 - ещё не активирован. Состояние передаётся в заголовках X-Secret-Status,
+// X-Secret-Expires-At, X-Secret-Views-Remaining и X-Secret-Available-At
+func (h *SecretHandler) HeadSecret(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	secret, err := h.repo.GetMetadata(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, repository.ErrSecretNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	status := secret.Status()
+	w.Header().Set("X-Secret-Status", status)
+	w.Header().Set("X-Secret-Expires-At", secret.ExpiresAt.UTC().Format(time.RFC3339))
+	w.Header().Set("X-Secret-Views-Remaining", strconv.Itoa(secret.ViewsRemaining))
+	if secret.AvailableAt != nil {
+		w.Header().Set("X-Secret-Available-At", secret.AvailableAt.UTC().Format(time.RFC3339))
+	}
+
+	switch status {
+	case models.SecretStatusActive:
+		w.WriteHeader(http.StatusOK)
+	case models.SecretStatusPending:
+		w.WriteHeader(http.StatusTooEarly)
+	default:
+		w.WriteHeader(http.StatusGone)
+	}
+}
+
+// resolveExpiry вычисляет время истечения секрета из ttl, expiration_hours или
+// expires_at (можно указать только одно из них) и проверяет его по политике:
+// не раньше MIN_TTL от текущего момента и не позже MAX_TTL от создания секрета.
+// Если ничего не указано, используется DEFAULT_TTL. Для нового секрета createdAt нулевое.
+func (h *SecretHandler) resolveExpiry(req *models.ExpirySpec, createdAt time.Time) (time.Time, error) {
+	now := time.Now()
+	if createdAt.IsZero() {
+		createdAt = now
+	}
+
+	specified := 0
+	for _, set := range []bool{req.TTL != "", req.ExpirationHours != 0, req.ExpiresAt != nil} {
+		if set {
+			specified++
+		}
+	}
+	if specified > 1 {
+		return time.Time{}, fmt.Errorf("Only one of ttl, expiration_hours or expires_at may be specified")
+	}
+
+	var ttl time.Duration
+	switch {
+	case req.TTL != "":
+		parsed, err := config.ParseDuration(req.TTL)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("Invalid ttl: use a duration such as 10m, 36h or 7d")
+		}
+		ttl = parsed
+	case req.ExpirationHours != 0:
+		ttl = time.Duration(req.ExpirationHours) * time.Hour
+	case req.ExpiresAt != nil:
+		ttl = req.ExpiresAt.Sub(now)
+	default:
+		ttl = h.cfg.DefaultTTL
+	}
+
+	if ttl < h.cfg.MinTTL || now.Add(ttl).After(createdAt.Add(h.cfg.MaxTTL)) {
+		return time.Time{}, fmt.Errorf("Expiration must be at least %s from now and at most %s after the secret was created",
+			h.cfg.MinTTL, h.cfg.MaxTTL)
+	}
+
+	if req.ExpiresAt != nil {
+		return *req.ExpiresAt, nil
+	}
+	return now.Add(ttl), nil
+}
+
+// sendEmail учитывает результат отправки письма в метриках и логирует ошибку
+func (h *SecretHandler) sendEmail(kind string, err error) bool {
+	if err != nil {
+		h.metrics.EmailsSentTotal.WithLabelValues(kind, "failed").Inc()
+		log.Printf("Failed to send %s email: %v", kind, err)
+		return false
+	}
+
+	h.metrics.EmailsSentTotal.WithLabelValues(kind, "sent").Inc()
+	return true
+}
+
+// normalizeEmail проверяет адрес электронной почты и возвращает его без имени
+func normalizeEmail(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+
+	address, err := mail.ParseAddress(value)
+	if err != nil {
+		return "", err
+	}
+
+	if len(address.Address) > maxEmailLength {
+		return "", fmt.Errorf("email address is too long")
+	}
+
+	return address.Address, nil
+}
+
+// validateWebhookURL проверяет URL для уведомлений
+func validateWebhookURL(value string) error {
+	if len(value) > maxWebhookURLLength {
+		return fmt.Errorf("Webhook URL must be at most %d characters", maxWebhookURLLength)
+	}
+
+	parsed, err := url.Parse(value)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("Webhook URL must be an absolute http or https URL")
+	}
+
+	return nil
+}
+
+// unlockSecret расшифровывает контент секрета: ключом сервера, затем парольной
+// фразой и ключом из ссылки, если секрет ими защищён.
+// При ошибке отправляет ответ клиенту и возвращает false.
+func (h *SecretHandler) unlockSecret(w http.ResponseWriter, r *http.Request, secret *models.Secret, passphrase, linkKey string) (string, bool) {
+	encryptedData := &crypto.EncryptedData{
+		Ciphertext: secret.EncryptedContent,
+		IV:         secret.IV,
+		KeyID:      secret.KeyID,
+		WrappedKey: secret.WrappedKey,
+	}
+
+	plaintext, err := h.encryptionService.Decrypt(r.Context(), encryptedData, secret.ID)
+	if err != nil {
+		h.recordDecryptionError(secret.ID, err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to decrypt secret")
+		return "", false
+	}
+
+	// Секрет, защищённый парольной фразой, сжигается после cfg.PassphraseMaxAttempts неверных попыток
+	if secret.PassphraseKDF != "" {
+		if passphrase == "" {
+			respondWithError(w, http.StatusUnauthorized, "Passphrase is required")
+			return "", false
+		}
+
+		plaintext, err = crypto.OpenWithPassphrase(plaintext, passphrase, secret.PassphraseKDF, secret.ID)
+		if errors.Is(err, crypto.ErrAuthenticationFailed) {
+			h.handleFailedPassphrase(w, r, secret)
+			return "", false
+		}
+		if err != nil {
+			h.recordDecryptionError(secret.ID, err)
+			respondWithError(w, http.StatusInternalServerError, "Failed to decrypt secret")
+			return "", false
+		}
+	}
+
+	// Секрет в режиме link_key расшифровывается ключом из ссылки. Неверный ключ
+	// не сжигает секрет: ссылка могла быть скопирована не полностью
+	if secret.EncryptionMode == models.EncryptionModeLinkKey {
+		if linkKey == "" {
+			respondWithError(w, http.StatusUnauthorized, "Decryption key is required")
+			return "", false
+		}
+
+		plaintext, err = crypto.OpenWithLinkKey(plaintext, linkKey, secret.ID)
+		if err != nil {
+			if errors.Is(err, crypto.ErrAuthenticationFailed) || errors.Is(err, crypto.ErrInvalidLinkKey) {
+				respondWithError(w, http.StatusForbidden, "Invalid decryption key")
+				return "", false
+			}
+			h.recordDecryptionError(secret.ID, err)
+			respondWithError(w, http.StatusInternalServerError, "Failed to decrypt secret")
+			return "", false
+		}
+	}
+
+	return plaintext, true
+}
+
+// handleFailedPassphrase учитывает неверную парольную фразу и сжигает секрет
+// после исчерпания попыток
+func (h *SecretHandler) handleFailedPassphrase(w http.ResponseWriter, r *http.Request, secret *models.Secret) {
+	h.metrics.PassphraseFailuresTotal.Inc()
+
+	// Попытка учитывается, даже если клиент уже отключился: иначе перебор
+	// можно вести, обрывая соединение до ответа
+	ctx := context.WithoutCancel(r.Context())
+	attempts, burned, err := h.repo.RegisterFailedAttempt(ctx, secret.ID, h.cfg.PassphraseMaxAttempts)
+	if err != nil {
+		log.Printf("Failed to register passphrase attempt for secret %s: %v", secret.ID, err)
+		respondWithError(w, http.StatusUnauthorized, "Invalid passphrase")
+		return
+	}
+
+	if burned {
+		h.metrics.SecretsBurnedTotal.Inc()
+		if secret.IsFile() {
+			h.deleteBlob(secret.BlobKey)
+		}
+		if count, err := h.repo.GetActiveSecretsCount(r.Context()); err == nil {
+			h.metrics.UpdateActiveSecretsGauge(count)
+		}
+		respondWithError(w, http.StatusGone, "Secret has been destroyed after too many failed passphrase attempts")
+		return
+	}
+
+	respondWithJSON(w, http.StatusUnauthorized, map[string]interface{}{
+		"error":              "Invalid passphrase",
+		"attempts_remaining": h.cfg.PassphraseMaxAttempts - attempts,
+	})
+}
+
+// recordDecryptionError учитывает ошибку расшифровки в метриках и логирует её.
+// Ошибка аутентификации означает, что шифртекст в БД был изменён или повреждён.
+func (h *SecretHandler) recordDecryptionError(id string, err error) {
+	if errors.Is(err, crypto.ErrAuthenticationFailed) {
+		h.metrics.DecryptionErrorsTotal.WithLabelValues("auth_failed").Inc()
+		log.Printf("Secret %s failed ciphertext authentication: data may have been tampered with", id)
+		return
+	}
+
+	h.metrics.DecryptionErrorsTotal.WithLabelValues("error").Inc()
+	log.Printf("Failed to decrypt secret %s: %v", id, err)
+}
+
 // HealthCheck обрабатывает GET /health - проверка здоровья сервиса
 func (h *SecretHandler) HealthCheck(w http.ResponseWriter, r *http.Request) {
 	respondWithJSON(w, http.StatusOK, map[string]string{"status": "ok"})
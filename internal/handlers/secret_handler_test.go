@@ -0,0 +1,435 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/savo4ka/ares-api/internal/blobstore"
+	"github.com/savo4ka/ares-api/internal/config"
+	"github.com/savo4ka/ares-api/internal/crypto"
+	"github.com/savo4ka/ares-api/internal/metrics"
+	"github.com/savo4ka/ares-api/internal/models"
+	"github.com/savo4ka/ares-api/internal/notify"
+	"github.com/savo4ka/ares-api/internal/repository"
+)
+
+// testMetrics регистрируются один раз на весь пакет: promauto не допускает повторной регистрации
+var testMetrics = metrics.New()
+
+type testEnv struct {
+	router *mux.Router
+	cfg    *config.Config
+	store  *repository.MemoryStore
+	blobs  *blobstore.FilesystemStore
+}
+
+// newTestEnv возвращает маршрутизатор API поверх хранилища в памяти
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+
+	provider, err := crypto.NewLocalKeyProvider(map[string]string{"k1": "0123456789abcdef0123456789abcdef"}, "k1")
+	if err != nil {
+		t.Fatalf("NewLocalKeyProvider: %v", err)
+	}
+	blobs, err := blobstore.NewFilesystemStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFilesystemStore: %v", err)
+	}
+
+	cfg := &config.Config{
+		MaxViews:              10,
+		MaxUploadSize:         1 << 20,
+		MinTTL:                time.Minute,
+		MaxTTL:                24 * time.Hour,
+		DefaultTTL:            time.Hour,
+		PassphraseMaxAttempts: 5,
+		ReaderInfo:            config.ReaderInfoNone,
+	}
+
+	store := repository.NewMemoryStore()
+	handler, err := NewSecretHandler(store, blobs, crypto.NewEncryptionService(provider), "http://localhost", cfg, testMetrics, notify.NoopNotifier{})
+	if err != nil {
+		t.Fatalf("NewSecretHandler: %v", err)
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/secrets", handler.CreateSecret).Methods("POST")
+	router.HandleFunc("/api/secrets/{id}", handler.HeadSecret).Methods("HEAD")
+	router.HandleFunc("/api/secrets/{id}", handler.GetSecret).Methods("GET")
+	router.HandleFunc("/api/secrets/{id}", handler.UpdateSecret).Methods("PATCH")
+	router.HandleFunc("/api/secrets/{id}", handler.RevokeSecret).Methods("DELETE")
+	router.HandleFunc("/api/secrets/{id}/status", handler.GetSecretStatus).Methods("GET")
+	router.HandleFunc("/api/secrets/{id}/receipt", handler.GetSecretReceipt).Methods("GET")
+
+	return &testEnv{router: router, cfg: cfg, store: store, blobs: blobs}
+}
+
+// do выполняет запрос к API; body кодируется в JSON
+func (e *testEnv) do(t *testing.T, method, path string, body any, headers map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	rec := httptest.NewRecorder()
+	e.router.ServeHTTP(rec, req)
+	return rec
+}
+
+// create создаёт текстовый секрет и возвращает ответ сервера
+func (e *testEnv) create(t *testing.T, req models.CreateSecretRequest) models.CreateSecretResponse {
+	t.Helper()
+
+	rec := e.do(t, http.MethodPost, "/api/secrets", req, nil)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: status %d: %s", rec.Code, rec.Body)
+	}
+
+	var created models.CreateSecretResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	return created
+}
+
+func (e *testEnv) get(id string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	e.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/secrets/"+id, nil))
+	return rec
+}
+
+// viewsRemaining возвращает число оставшихся просмотров секрета
+func (e *testEnv) viewsRemaining(t *testing.T, id string) int {
+	t.Helper()
+	secret, err := e.store.GetMetadata(context.Background(), id)
+	if err != nil {
+		t.Fatalf("GetMetadata: %v", err)
+	}
+	return secret.ViewsRemaining
+}
+
+// decode разбирает JSON ответа
+func decode[T any](t *testing.T, rec *httptest.ResponseRecorder) T {
+	t.Helper()
+	var v T
+	if err := json.Unmarshal(rec.Body.Bytes(), &v); err != nil {
+		t.Fatalf("failed to decode response %q: %v", rec.Body, err)
+	}
+	return v
+}
+
+func TestPassphraseSecret(t *testing.T) {
+	env := newTestEnv(t)
+	created := env.create(t, models.CreateSecretRequest{Content: "секрет", Passphrase: "correct horse", MaxViews: 2})
+	path := "/api/secrets/" + created.ID
+
+	// Без парольной фразы попытка не засчитывается
+	if rec := env.do(t, http.MethodGet, path, nil, nil); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("GET without passphrase: status %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	// Каждая неверная фраза уменьшает число оставшихся попыток
+	for want := 4; want >= 3; want-- {
+		rec := env.do(t, http.MethodGet, path, nil, map[string]string{"X-Secret-Passphrase": "wrong"})
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("GET with wrong passphrase: status %d, want %d", rec.Code, http.StatusUnauthorized)
+		}
+		if got := decode[map[string]any](t, rec)["attempts_remaining"]; got != float64(want) {
+			t.Errorf("attempts_remaining = %v, want %d", got, want)
+		}
+	}
+	if views := env.viewsRemaining(t, created.ID); views != 2 {
+		t.Errorf("views_remaining = %d after wrong passphrases, want 2", views)
+	}
+
+	rec := env.do(t, http.MethodGet, path, nil, map[string]string{"X-Secret-Passphrase": "correct horse"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET with passphrase: status %d: %s", rec.Code, rec.Body)
+	}
+	if got := decode[models.GetSecretResponse](t, rec); got.Content != "секрет" || got.ViewsRemaining != 1 {
+		t.Errorf("response: content = %q, views_remaining = %d", got.Content, got.ViewsRemaining)
+	}
+
+	secret, err := env.store.GetByID(context.Background(), created.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if secret.FailedAttempts != 2 {
+		t.Errorf("failed_attempts = %d, want 2", secret.FailedAttempts)
+	}
+}
+
+func TestPassphraseSecretBurnsAfterMaxAttempts(t *testing.T) {
+	env := newTestEnv(t)
+	env.cfg.PassphraseMaxAttempts = 3
+	created := env.create(t, models.CreateSecretRequest{Content: "секрет", Passphrase: "correct horse"})
+	path := "/api/secrets/" + created.ID
+	wrong := map[string]string{"X-Secret-Passphrase": "wrong"}
+
+	for range 2 {
+		if rec := env.do(t, http.MethodGet, path, nil, wrong); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("GET with wrong passphrase: status %d, want %d", rec.Code, http.StatusUnauthorized)
+		}
+	}
+	if rec := env.do(t, http.MethodGet, path, nil, wrong); rec.Code != http.StatusGone {
+		t.Fatalf("last wrong passphrase: status %d, want %d", rec.Code, http.StatusGone)
+	}
+
+	// Секрет остаётся надгробием без шифртекста
+	secret, err := env.store.GetByID(context.Background(), created.ID)
+	if err != nil {
+		t.Fatalf("GetByID after burn: %v", err)
+	}
+	if !secret.IsAccessed || secret.EncryptedContent != "" || secret.WrappedKey != "" || secret.PassphraseKDF != "" {
+		t.Errorf("burned secret not wiped: is_accessed = %t", secret.IsAccessed)
+	}
+
+	// Верная фраза больше не помогает, ответ - 410, а не 404
+	rec := env.do(t, http.MethodGet, path, nil, map[string]string{"X-Secret-Passphrase": "correct horse"})
+	if rec.Code != http.StatusGone {
+		t.Fatalf("GET after burn: status %d, want %d", rec.Code, http.StatusGone)
+	}
+	if got := decode[map[string]string](t, rec)["error"]; got != "Secret has been destroyed after too many failed passphrase attempts" {
+		t.Errorf("error = %q", got)
+	}
+}
+
+func TestResolveExpiry(t *testing.T) {
+	h := &SecretHandler{cfg: &config.Config{MinTTL: time.Minute, MaxTTL: 7 * 24 * time.Hour, DefaultTTL: time.Hour}}
+	now := time.Now()
+	at := func(d time.Duration) *time.Time {
+		value := now.Add(d)
+		return &value
+	}
+
+	tests := []struct {
+		name      string
+		spec      models.ExpirySpec
+		createdAt time.Time
+		want      time.Duration // Ожидаемый срок от текущего момента
+		wantErr   bool
+	}{
+		{name: "default", want: time.Hour},
+		{name: "minutes", spec: models.ExpirySpec{TTL: "10m"}, want: 10 * time.Minute},
+		{name: "days", spec: models.ExpirySpec{TTL: "7d"}, want: 7 * 24 * time.Hour},
+		{name: "hours", spec: models.ExpirySpec{ExpirationHours: 48}, want: 48 * time.Hour},
+		{name: "absolute", spec: models.ExpirySpec{ExpiresAt: at(3 * time.Hour)}, want: 3 * time.Hour},
+		{name: "minimum", spec: models.ExpirySpec{TTL: "1m"}, want: time.Minute},
+		{name: "below minimum", spec: models.ExpirySpec{TTL: "30s"}, wantErr: true},
+		{name: "above maximum", spec: models.ExpirySpec{TTL: "8d"}, wantErr: true},
+		{name: "absolute in the past", spec: models.ExpirySpec{ExpiresAt: at(-time.Minute)}, wantErr: true},
+		{name: "negative hours", spec: models.ExpirySpec{ExpirationHours: -1}, wantErr: true},
+		{name: "invalid ttl", spec: models.ExpirySpec{TTL: "forever"}, wantErr: true},
+		{name: "two fields", spec: models.ExpirySpec{TTL: "1h", ExpirationHours: 1}, wantErr: true},
+		// Максимальный срок отсчитывается от создания секрета, а не от изменения
+		{name: "within maximum of an old secret", spec: models.ExpirySpec{TTL: "23h"}, createdAt: now.Add(-6 * 24 * time.Hour), want: 23 * time.Hour},
+		{name: "beyond maximum of an old secret", spec: models.ExpirySpec{TTL: "2d"}, createdAt: now.Add(-6 * 24 * time.Hour), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := h.resolveExpiry(&tt.spec, tt.createdAt)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: resolveExpiry = %v, want an error", tt.name, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: resolveExpiry: %v", tt.name, err)
+			continue
+		}
+		if diff := got.Sub(now.Add(tt.want)); diff < -time.Second || diff > time.Second {
+			t.Errorf("%s: expires in %v, want %v", tt.name, got.Sub(now), tt.want)
+		}
+	}
+}
+
+func TestCreateSecretRejectsTTLOutsidePolicy(t *testing.T) {
+	env := newTestEnv(t)
+
+	for _, ttl := range []string{"30s", "2d"} {
+		rec := env.do(t, http.MethodPost, "/api/secrets", models.CreateSecretRequest{Content: "секрет", ExpirySpec: models.ExpirySpec{TTL: ttl}}, nil)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("ttl %s: status %d, want %d", ttl, rec.Code, http.StatusBadRequest)
+		}
+	}
+
+	created := env.create(t, models.CreateSecretRequest{Content: "секрет", ExpirySpec: models.ExpirySpec{TTL: "10m"}})
+	if until := time.Until(created.ExpiresAt); until <= 9*time.Minute || until > 10*time.Minute {
+		t.Errorf("expires_at in %v, want 10m", until)
+	}
+}
+
+func TestSecretStatus(t *testing.T) {
+	env := newTestEnv(t)
+	created := env.create(t, models.CreateSecretRequest{Content: "секрет", Passphrase: "correct horse", MaxViews: 2})
+	path := "/api/secrets/" + created.ID
+
+	rec := env.do(t, http.MethodGet, path+"/status", nil, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status: %d: %s", rec.Code, rec.Body)
+	}
+	status := decode[models.SecretStatusResponse](t, rec)
+	if !status.Exists || status.Status != models.SecretStatusActive || status.Accessed || status.Expired ||
+		status.ViewsRemaining != 2 || !status.RequiresPassphrase || status.ExpiresAt == nil {
+		t.Errorf("status of an unread secret = %+v", status)
+	}
+
+	rec = env.do(t, http.MethodHead, path, nil, nil)
+	if rec.Code != http.StatusOK || rec.Body.Len() != 0 {
+		t.Errorf("HEAD: status %d, body of %d bytes", rec.Code, rec.Body.Len())
+	}
+	if got := rec.Header().Get("X-Secret-Status"); got != models.SecretStatusActive {
+		t.Errorf("X-Secret-Status = %q", got)
+	}
+	if got := rec.Header().Get("X-Secret-Views-Remaining"); got != "2" {
+		t.Errorf("X-Secret-Views-Remaining = %q", got)
+	}
+
+	// Проверка состояния не тратит просмотры
+	if views := env.viewsRemaining(t, created.ID); views != 2 {
+		t.Errorf("views_remaining = %d after status checks, want 2", views)
+	}
+
+	for range 2 {
+		if rec := env.do(t, http.MethodGet, path, nil, map[string]string{"X-Secret-Passphrase": "correct horse"}); rec.Code != http.StatusOK {
+			t.Fatalf("GET: status %d: %s", rec.Code, rec.Body)
+		}
+	}
+
+	status = decode[models.SecretStatusResponse](t, env.do(t, http.MethodGet, path+"/status", nil, nil))
+	if !status.Exists || status.Status != models.SecretStatusAccessed || !status.Accessed || status.ViewsRemaining != 0 {
+		t.Errorf("status of a read secret = %+v", status)
+	}
+	if rec := env.do(t, http.MethodHead, path, nil, nil); rec.Code != http.StatusGone {
+		t.Errorf("HEAD of a read secret: status %d, want %d", rec.Code, http.StatusGone)
+	}
+}
+
+func TestSecretStatusExpiredAndMissing(t *testing.T) {
+	env := newTestEnv(t)
+	now := time.Now()
+	err := env.store.Create(context.Background(), &models.Secret{
+		ID:             "expired",
+		EncryptionMode: models.EncryptionModeServer,
+		MaxViews:       1,
+		ViewsRemaining: 1,
+		ExpiresAt:      now.Add(-time.Minute),
+		CreatedAt:      now.Add(-time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	status := decode[models.SecretStatusResponse](t, env.do(t, http.MethodGet, "/api/secrets/expired/status", nil, nil))
+	if !status.Exists || status.Status != models.SecretStatusExpired || !status.Expired {
+		t.Errorf("status of an expired secret = %+v", status)
+	}
+	if rec := env.do(t, http.MethodHead, "/api/secrets/expired", nil, nil); rec.Code != http.StatusGone {
+		t.Errorf("HEAD of an expired secret: status %d, want %d", rec.Code, http.StatusGone)
+	}
+
+	rec := env.do(t, http.MethodGet, "/api/secrets/missing/status", nil, nil)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status of a missing secret: %d, want %d", rec.Code, http.StatusNotFound)
+	}
+	if status := decode[models.SecretStatusResponse](t, rec); status.Exists || status.ID != "missing" {
+		t.Errorf("status of a missing secret = %+v", status)
+	}
+	if rec := env.do(t, http.MethodHead, "/api/secrets/missing", nil, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("HEAD of a missing secret: status %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestSecretNotAvailableYet(t *testing.T) {
+	env := newTestEnv(t)
+	availableAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	created := env.create(t, models.CreateSecretRequest{Content: "секрет", AvailableAt: &availableAt, ExpirySpec: models.ExpirySpec{TTL: "2h"}})
+	if created.AvailableAt == nil || !created.AvailableAt.Equal(availableAt) {
+		t.Errorf("create: available_at = %v, want %v", created.AvailableAt, availableAt)
+	}
+
+	rec := env.get(created.ID)
+	if rec.Code != http.StatusTooEarly {
+		t.Fatalf("GET: status %d, want %d: %s", rec.Code, http.StatusTooEarly, rec.Body)
+	}
+	body := decode[struct {
+		Error       string    `json:"error"`
+		AvailableAt time.Time `json:"available_at"`
+	}](t, rec)
+	if body.Error == "" || !body.AvailableAt.Equal(availableAt) {
+		t.Errorf("GET: response %+v, want available_at %v", body, availableAt)
+	}
+	if views := env.viewsRemaining(t, created.ID); views != 1 {
+		t.Errorf("views_remaining = %d after an early read, want 1", views)
+	}
+
+	status := decode[models.SecretStatusResponse](t, env.do(t, http.MethodGet, "/api/secrets/"+created.ID+"/status", nil, nil))
+	if status.Status != models.SecretStatusPending || status.AvailableAt == nil {
+		t.Errorf("status of a pending secret = %+v", status)
+	}
+	rec = env.do(t, http.MethodHead, "/api/secrets/"+created.ID, nil, nil)
+	if rec.Code != http.StatusTooEarly {
+		t.Errorf("HEAD: status %d, want %d", rec.Code, http.StatusTooEarly)
+	}
+	if got := rec.Header().Get("X-Secret-Available-At"); got != availableAt.UTC().Format(time.RFC3339) {
+		t.Errorf("X-Secret-Available-At = %q", got)
+	}
+
+	// Когда время наступило, секрет читается как обычно
+	secret, err := env.store.GetByID(context.Background(), created.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if err := env.store.Revoke(context.Background(), created.ID); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	past := time.Now().Add(-time.Second)
+	secret.AvailableAt = &past
+	if err := env.store.Create(context.Background(), secret); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if rec := env.get(created.ID); rec.Code != http.StatusOK {
+		t.Errorf("GET after available_at: status %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestCreateSecretAvailableAt(t *testing.T) {
+	env := newTestEnv(t)
+
+	// available_at в прошлом означает «доступен сразу»
+	past := time.Now().Add(-time.Hour)
+	created := env.create(t, models.CreateSecretRequest{Content: "секрет", AvailableAt: &past})
+	if created.AvailableAt != nil {
+		t.Errorf("create with available_at in the past: available_at = %v, want nil", created.AvailableAt)
+	}
+	if rec := env.get(created.ID); rec.Code != http.StatusOK {
+		t.Errorf("GET: status %d: %s", rec.Code, rec.Body)
+	}
+
+	// available_at должен быть раньше времени истечения
+	expiresAt := time.Now().Add(time.Hour)
+	for _, availableAt := range []time.Time{expiresAt, expiresAt.Add(time.Hour)} {
+		req := models.CreateSecretRequest{Content: "секрет", AvailableAt: &availableAt, ExpirySpec: models.ExpirySpec{ExpiresAt: &expiresAt}}
+		if rec := env.do(t, http.MethodPost, "/api/secrets", req, nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("available_at %v with expires_at %v: status %d, want %d", availableAt, expiresAt, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
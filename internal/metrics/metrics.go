@@ -1,6 +1,7 @@
 package metrics
 
 import (
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
@@ -12,16 +13,37 @@ type Metrics struct {
 	HTTPRequestDuration *prometheus.HistogramVec
 
 	// Бизнес-метрики секретов
-	SecretsCreatedTotal      prometheus.Counter
-	SecretsReadTotal         prometheus.Counter
-	SecretsAlreadyReadTotal  prometheus.Counter
-	SecretsExpiredReadTotal  prometheus.Counter
-	SecretsCleanedUpTotal    prometheus.Counter
-	ActiveSecretsGauge       prometheus.Gauge
+	SecretsCreatedTotal     prometheus.Counter
+	SecretsReadTotal        prometheus.Counter
+	SecretsAlreadyReadTotal prometheus.Counter
+	SecretsExpiredReadTotal prometheus.Counter
+	SecretsEarlyReadTotal   prometheus.Counter
+	SecretsCleanedUpTotal   prometheus.Counter
+	ActiveSecretsGauge      prometheus.Gauge
+	PassphraseFailuresTotal prometheus.Counter
+	SecretsBurnedTotal      prometheus.Counter
+	BotRequestsBlockedTotal prometheus.Counter
+	SecretsRevokedTotal     prometheus.Counter
+	ExpiryChangesTotal      *prometheus.CounterVec
+	FileBytesTotal          *prometheus.CounterVec
+
+	// Метрики хранилища блобов
+	BlobsOrphanedDeletedTotal prometheus.Counter
 
 	// Метрики шифрования
 	EncryptionErrorsTotal prometheus.Counter
-	DecryptionErrorsTotal prometheus.Counter
+	DecryptionErrorsTotal *prometheus.CounterVec
+
+	// Метрики уведомлений (webhooks)
+	WebhookDeliveriesTotal *prometheus.CounterVec
+	WebhookPendingGauge    prometheus.Gauge
+
+	// Метрики писем
+	EmailsSentTotal *prometheus.CounterVec
+
+	// Метрики перешифрования (rekey)
+	RekeySecretsTotal     *prometheus.CounterVec
+	RekeyRemainingSecrets prometheus.Gauge
 }
 
 // New создаёт и регистрирует все метрики
@@ -69,6 +91,12 @@ func New() *Metrics {
 				Help: "Количество попыток прочитать истекший секрет",
 			},
 		),
+		SecretsEarlyReadTotal: promauto.NewCounter(
+			prometheus.CounterOpts{
+				Name: "ares_secrets_early_read_total",
+				Help: "Количество попыток прочитать секрет до времени его активации",
+			},
+		),
 		SecretsCleanedUpTotal: promauto.NewCounter(
 			prometheus.CounterOpts{
 				Name: "ares_secrets_cleaned_up_total",
@@ -81,6 +109,52 @@ func New() *Metrics {
 				Help: "Текущее количество активных (не прочитанных и не истекших) секретов",
 			},
 		),
+		PassphraseFailuresTotal: promauto.NewCounter(
+			prometheus.CounterOpts{
+				Name: "ares_passphrase_failures_total",
+				Help: "Количество попыток прочитать секрет с неверной парольной фразой",
+			},
+		),
+		SecretsBurnedTotal: promauto.NewCounter(
+			prometheus.CounterOpts{
+				Name: "ares_secrets_burned_total",
+				Help: "Количество секретов, уничтоженных после исчерпания попыток ввода парольной фразы",
+			},
+		),
+		BotRequestsBlockedTotal: promauto.NewCounter(
+			prometheus.CounterOpts{
+				Name: "ares_bot_requests_blocked_total",
+				Help: "Количество запросов к секретам от ботов предпросмотра ссылок",
+			},
+		),
+		SecretsRevokedTotal: promauto.NewCounter(
+			prometheus.CounterOpts{
+				Name: "ares_secrets_revoked_total",
+				Help: "Количество секретов, уничтоженных создателем",
+			},
+		),
+		ExpiryChangesTotal: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "ares_secret_expiry_changes_total",
+				Help: "Изменения срока действия секретов создателем (direction: extend, shorten)",
+			},
+			[]string{"direction"},
+		),
+		FileBytesTotal: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "ares_secret_file_bytes_total",
+				Help: "Объём файлов секретов в байтах по направлению (upload, download)",
+			},
+			[]string{"direction"},
+		),
+
+		// Метрики хранилища блобов
+		BlobsOrphanedDeletedTotal: promauto.NewCounter(
+			prometheus.CounterOpts{
+				Name: "ares_blobs_orphaned_deleted_total",
+				Help: "Количество удалённых блобов, на которые не ссылался ни один секрет",
+			},
+		),
 
 		// Метрики шифрования
 		EncryptionErrorsTotal: promauto.NewCounter(
@@ -89,10 +163,50 @@ func New() *Metrics {
 				Help: "Общее количество ошибок шифрования",
 			},
 		),
-		DecryptionErrorsTotal: promauto.NewCounter(
+		DecryptionErrorsTotal: promauto.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "ares_decryption_errors_total",
-				Help: "Общее количество ошибок расшифровки",
+				Help: "Общее количество ошибок расшифровки (reason: auth_failed - шифртекст не прошёл проверку подлинности, error - прочие ошибки)",
+			},
+			[]string{"reason"},
+		),
+
+		// Метрики уведомлений (webhooks)
+		WebhookDeliveriesTotal: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "ares_webhook_deliveries_total",
+				Help: "Попытки доставки уведомлений по событию и результату (delivered, retry, failed)",
+			},
+			[]string{"event", "result"},
+		),
+		WebhookPendingGauge: promauto.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "ares_webhook_pending",
+				Help: "Количество уведомлений, ожидающих доставки",
+			},
+		),
+
+		// Метрики писем
+		EmailsSentTotal: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "ares_emails_sent_total",
+				Help: "Отправка писем по типу (secret_link, secret_opened) и результату (sent, failed)",
+			},
+			[]string{"kind", "result"},
+		),
+
+		// Метрики перешифрования (rekey)
+		RekeySecretsTotal: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "ares_rekey_secrets_total",
+				Help: "Количество секретов, обработанных при перешифровании (result: reencrypted, skipped, failed)",
+			},
+			[]string{"result"},
+		),
+		RekeyRemainingSecrets: promauto.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "ares_rekey_remaining_secrets",
+				Help: "Количество секретов, ещё не перешифрованных активным ключом",
 			},
 		),
 	}
@@ -103,3 +217,57 @@ func New() *Metrics {
 func (m *Metrics) UpdateActiveSecretsGauge(count int64) {
 	m.ActiveSecretsGauge.Set(float64(count))
 }
+
+// RegisterDBPool регистрирует метрики пула подключений к PostgreSQL.
+// Значения считываются из статистики пула при каждом сборе метрик.
+func (m *Metrics) RegisterDBPool(pool *pgxpool.Pool) {
+	promauto.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "ares_db_pool_acquired_connections",
+			Help: "Количество подключений к БД, занятых запросами",
+		},
+		func() float64 { return float64(pool.Stat().AcquiredConns()) },
+	)
+	promauto.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "ares_db_pool_idle_connections",
+			Help: "Количество простаивающих подключений к БД",
+		},
+		func() float64 { return float64(pool.Stat().IdleConns()) },
+	)
+	promauto.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "ares_db_pool_total_connections",
+			Help: "Общее количество открытых подключений к БД",
+		},
+		func() float64 { return float64(pool.Stat().TotalConns()) },
+	)
+	promauto.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "ares_db_pool_max_connections",
+			Help: "Максимальный размер пула подключений к БД",
+		},
+		func() float64 { return float64(pool.Stat().MaxConns()) },
+	)
+	promauto.NewCounterFunc(
+		prometheus.CounterOpts{
+			Name: "ares_db_pool_acquires_total",
+			Help: "Общее количество получений подключения из пула",
+		},
+		func() float64 { return float64(pool.Stat().AcquireCount()) },
+	)
+	promauto.NewCounterFunc(
+		prometheus.CounterOpts{
+			Name: "ares_db_pool_empty_acquires_total",
+			Help: "Количество получений подключения, которым пришлось ждать освобождения подключения",
+		},
+		func() float64 { return float64(pool.Stat().EmptyAcquireCount()) },
+	)
+	promauto.NewCounterFunc(
+		prometheus.CounterOpts{
+			Name: "ares_db_pool_acquire_wait_seconds_total",
+			Help: "Суммарное время ожидания свободного подключения в секундах",
+		},
+		func() float64 { return pool.Stat().EmptyAcquireWaitTime().Seconds() },
+	)
+}
@@ -0,0 +1,296 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/savo4ka/ares-api/internal/database"
+)
+
+// lockID - ключ advisory lock, под которым выполняются миграции: экземпляры
+// сервиса, запущенные одновременно с AUTO_MIGRATE, применяют миграции по очереди
+const lockID = 4_172_901_337
+
+// fileNamePattern - имя файла миграции в формате golang-migrate
+var fileNamePattern = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// ErrDirty возвращается, если предыдущая миграция (например, внешним golang-migrate)
+// завершилась с ошибкой и схему нужно исправить вручную
+var ErrDirty = errors.New("database schema is dirty")
+
+// Migration - одна миграция схемы
+type Migration struct {
+	Version uint
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Migrator применяет встроенные миграции к PostgreSQL. Версия схемы хранится
+// в таблице schema_migrations в формате golang-migrate, поэтому базы, которые
+// раньше мигрировались внешним инструментом, продолжают работать без изменений.
+// Каждая миграция выполняется в транзакции вместе с записью новой версии.
+type Migrator struct {
+	db         *database.DB
+	migrations []Migration
+}
+
+// New создаёт мигратор с миграциями из files
+func New(db *database.DB, files fs.FS) (*Migrator, error) {
+	entries, err := fs.ReadDir(files, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[uint]*Migration)
+	for _, entry := range entries {
+		match := fileNamePattern.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil || version == 0 {
+			return nil, fmt.Errorf("invalid migration version in %s", entry.Name())
+		}
+
+		content, err := fs.ReadFile(files, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		migration, ok := byVersion[uint(version)]
+		if !ok {
+			migration = &Migration{Version: uint(version), Name: match[2]}
+			byVersion[uint(version)] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Up применяет все ещё не применённые миграции и возвращает их количество
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	applied := 0
+
+	err := m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		current, err := currentVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if migration.Version <= current {
+				continue
+			}
+
+			if err := apply(ctx, conn, migration.Up, migration.Version); err != nil {
+				return fmt.Errorf("failed to apply migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			log.Printf("Applied migration %d_%s", migration.Version, migration.Name)
+			applied++
+		}
+
+		return nil
+	})
+
+	return applied, err
+}
+
+// Down откатывает последние steps миграций и возвращает количество откаченных
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	reverted := 0
+
+	err := m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		current, err := currentVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for reverted < steps && current > 0 {
+			index := m.find(current)
+			if index < 0 {
+				return fmt.Errorf("migration %d is applied but not known to this build", current)
+			}
+
+			migration := m.migrations[index]
+			if migration.Down == "" {
+				return fmt.Errorf("migration %d_%s has no down file", migration.Version, migration.Name)
+			}
+
+			var previous uint
+			if index > 0 {
+				previous = m.migrations[index-1].Version
+			}
+
+			if err := apply(ctx, conn, migration.Down, previous); err != nil {
+				return fmt.Errorf("failed to revert migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			log.Printf("Reverted migration %d_%s", migration.Version, migration.Name)
+
+			current = previous
+			reverted++
+		}
+
+		return nil
+	})
+
+	return reverted, err
+}
+
+// Version возвращает текущую версию схемы (0, если миграции не применялись)
+// и признак незавершённой миграции
+func (m *Migrator) Version(ctx context.Context) (uint, bool, error) {
+	conn, err := m.db.Acquire(ctx)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Release()
+
+	if err := ensureVersionTable(ctx, conn); err != nil {
+		return 0, false, err
+	}
+
+	return readVersion(ctx, conn)
+}
+
+// Latest возвращает версию последней встроенной миграции
+func (m *Migrator) Latest() uint {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// find возвращает индекс миграции с версией version или -1
+func (m *Migrator) find(version uint) int {
+	for i, migration := range m.migrations {
+		if migration.Version == version {
+			return i
+		}
+	}
+	return -1
+}
+
+// withLock выполняет fn на отдельном подключении под advisory lock
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *pgxpool.Conn) error) error {
+	conn, err := m.db.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, lockID); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer func() {
+		if _, err := conn.Exec(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1)`, lockID); err != nil {
+			log.Printf("Failed to release migration lock: %v", err)
+		}
+	}()
+
+	if err := ensureVersionTable(ctx, conn); err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+// ensureVersionTable создаёт таблицу версии схемы (совместима с golang-migrate)
+func ensureVersionTable(ctx context.Context, conn *pgxpool.Conn) error {
+	_, err := conn.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version BIGINT NOT NULL PRIMARY KEY, dirty BOOLEAN NOT NULL)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+	return nil
+}
+
+// readVersion читает версию схемы
+func readVersion(ctx context.Context, conn *pgxpool.Conn) (uint, bool, error) {
+	var version int64
+	var dirty bool
+	err := conn.QueryRow(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	// golang-migrate записывает -1, если все миграции откачены
+	if version < 0 {
+		version = 0
+	}
+	return uint(version), dirty, nil
+}
+
+// currentVersion возвращает версию схемы, если она не помечена незавершённой
+func currentVersion(ctx context.Context, conn *pgxpool.Conn) (uint, error) {
+	version, dirty, err := readVersion(ctx, conn)
+	if err != nil {
+		return 0, err
+	}
+
+	if dirty {
+		return 0, fmt.Errorf("%w at version %d: fix the schema manually, then set schema_migrations.dirty to false", ErrDirty, version)
+	}
+	return version, nil
+}
+
+// apply выполняет SQL миграции и записывает новую версию в одной транзакции
+func apply(ctx context.Context, conn *pgxpool.Conn, query string, version uint) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, query); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM schema_migrations`); err != nil {
+		return fmt.Errorf("failed to update schema version: %w", err)
+	}
+
+	if version > 0 {
+		if _, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version, dirty) VALUES ($1, FALSE)`, int64(version)); err != nil {
+			return fmt.Errorf("failed to update schema version: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,260 @@
+package migrate
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/savo4ka/ares-api/internal/database"
+	"github.com/savo4ka/ares-api/migrations"
+)
+
+// testDatabaseURLEnv - переменная окружения с адресом PostgreSQL для тестов;
+// без неё тесты PostgreSQL пропускаются
+const testDatabaseURLEnv = "TEST_DATABASE_URL"
+
+func file(content string) *fstest.MapFile {
+	return &fstest.MapFile{Data: []byte(content)}
+}
+
+func TestNew(t *testing.T) {
+	files := fstest.MapFS{
+		"10_third.up.sql":   file("SELECT 3"),
+		"10_third.down.sql": file("SELECT -3"),
+		"2_second.up.sql":   file("SELECT 2"),
+		"2_second.down.sql": file("SELECT -2"),
+		"1_first.up.sql":    file("SELECT 1"),
+		"1_first.down.sql":  file("SELECT -1"),
+		"README.md":         file("not a migration"),
+		"3_draft.sql":       file("not a migration"),
+		"4_nested.up.sql/x": file("directory"),
+	}
+
+	migrator, err := New(nil, files)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	want := []Migration{
+		{Version: 1, Name: "first", Up: "SELECT 1", Down: "SELECT -1"},
+		{Version: 2, Name: "second", Up: "SELECT 2", Down: "SELECT -2"},
+		{Version: 10, Name: "third", Up: "SELECT 3", Down: "SELECT -3"},
+	}
+	if len(migrator.migrations) != len(want) {
+		t.Fatalf("migrations = %+v, want %+v", migrator.migrations, want)
+	}
+	for i, migration := range migrator.migrations {
+		if migration != want[i] {
+			t.Errorf("migration %d = %+v, want %+v", i, migration, want[i])
+		}
+	}
+	if latest := migrator.Latest(); latest != 10 {
+		t.Errorf("Latest = %d, want 10", latest)
+	}
+}
+
+func TestNewRejectsInvalidMigrations(t *testing.T) {
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+	}{
+		{"zero version", fstest.MapFS{"0_init.up.sql": file("SELECT 1")}},
+		{"version overflow", fstest.MapFS{"99999999999999999999_init.up.sql": file("SELECT 1")}},
+		{"conflicting names", fstest.MapFS{"1_init.up.sql": file("SELECT 1"), "1_other.down.sql": file("SELECT 1")}},
+		{"missing up file", fstest.MapFS{"1_init.up.sql": file("SELECT 1"), "2_next.down.sql": file("SELECT 1")}},
+		{"empty up file", fstest.MapFS{"1_init.up.sql": file("")}},
+	}
+
+	for _, tt := range tests {
+		if _, err := New(nil, tt.files); err == nil {
+			t.Errorf("%s: New accepted invalid migrations", tt.name)
+		}
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrator, err := New(nil, migrations.FS)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	for i, migration := range migrator.migrations {
+		if migration.Version != uint(i+1) {
+			t.Errorf("migration %d_%s: versions must be sequential", migration.Version, migration.Name)
+		}
+		if migration.Down == "" {
+			t.Errorf("migration %d_%s has no down file", migration.Version, migration.Name)
+		}
+	}
+}
+
+// newTestDB возвращает подключение к отдельной пустой схеме; схема удаляется
+// после теста. Без TEST_DATABASE_URL тест пропускается.
+func newTestDB(t *testing.T) *database.DB {
+	t.Helper()
+	databaseURL := os.Getenv(testDatabaseURLEnv)
+	if databaseURL == "" {
+		t.Skipf("%s is not set", testDatabaseURLEnv)
+	}
+	ctx := context.Background()
+
+	admin, err := database.New(databaseURL, database.PoolConfig{})
+	if err != nil {
+		t.Fatalf("database.New: %v", err)
+	}
+	t.Cleanup(admin.Close)
+
+	suffix := make([]byte, 6)
+	rand.Read(suffix)
+	schema := "ares_test_" + hex.EncodeToString(suffix)
+	if _, err := admin.Exec(ctx, "CREATE SCHEMA "+schema); err != nil {
+		t.Fatalf("CREATE SCHEMA: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec(context.Background(), "DROP SCHEMA "+schema+" CASCADE"); err != nil {
+			t.Errorf("DROP SCHEMA: %v", err)
+		}
+	})
+
+	// search_path задаётся в адресе в формате URL или key=value
+	if strings.Contains(databaseURL, "://") {
+		u, err := url.Parse(databaseURL)
+		if err != nil {
+			t.Fatalf("invalid %s: %v", testDatabaseURLEnv, err)
+		}
+		query := u.Query()
+		query.Set("search_path", schema)
+		u.RawQuery = query.Encode()
+		databaseURL = u.String()
+	} else {
+		databaseURL += " search_path=" + schema
+	}
+
+	db, err := database.New(databaseURL, database.PoolConfig{})
+	if err != nil {
+		t.Fatalf("database.New: %v", err)
+	}
+	t.Cleanup(db.Close)
+	return db
+}
+
+func TestMigratorUpDown(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	migrator, err := New(db, migrations.FS)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	latest := migrator.Latest()
+
+	if version, dirty, err := migrator.Version(ctx); err != nil || version != 0 || dirty {
+		t.Fatalf("Version of an empty schema = %d, %t, %v", version, dirty, err)
+	}
+
+	applied, err := migrator.Up(ctx)
+	if err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if applied != len(migrator.migrations) {
+		t.Errorf("Up applied %d migrations, want %d", applied, len(migrator.migrations))
+	}
+	if version, dirty, err := migrator.Version(ctx); err != nil || version != latest || dirty {
+		t.Errorf("Version after Up = %d, %t, %v, want %d", version, dirty, err, latest)
+	}
+
+	if applied, err := migrator.Up(ctx); err != nil || applied != 0 {
+		t.Errorf("second Up = %d, %v, want 0", applied, err)
+	}
+
+	if reverted, err := migrator.Down(ctx, 1); err != nil || reverted != 1 {
+		t.Fatalf("Down(1) = %d, %v", reverted, err)
+	}
+	if version, _, err := migrator.Version(ctx); err != nil || version != latest-1 {
+		t.Errorf("Version after Down(1) = %d, %v, want %d", version, err, latest-1)
+	}
+
+	if applied, err := migrator.Up(ctx); err != nil || applied != 1 {
+		t.Errorf("Up after Down(1) = %d, %v, want 1", applied, err)
+	}
+
+	// Откат всех миграций удаляет схему целиком
+	reverted, err := migrator.Down(ctx, len(migrator.migrations)+1)
+	if err != nil || reverted != len(migrator.migrations) {
+		t.Fatalf("Down(all) = %d, %v, want %d", reverted, err, len(migrator.migrations))
+	}
+	if version, _, err := migrator.Version(ctx); err != nil || version != 0 {
+		t.Errorf("Version after Down(all) = %d, %v, want 0", version, err)
+	}
+	var tables int
+	if err := db.QueryRow(ctx, `SELECT count(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name <> 'schema_migrations'`).Scan(&tables); err != nil {
+		t.Fatalf("count tables: %v", err)
+	}
+	if tables != 0 {
+		t.Errorf("%d tables left after Down(all)", tables)
+	}
+
+	if applied, err := migrator.Up(ctx); err != nil || applied != len(migrator.migrations) {
+		t.Errorf("Up after Down(all) = %d, %v", applied, err)
+	}
+}
+
+func TestMigratorRefusesDirtySchema(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	migrator, err := New(db, migrations.FS)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+
+	// Так оставляет схему golang-migrate после неудачной миграции
+	if _, err := db.Exec(ctx, `UPDATE schema_migrations SET dirty = TRUE`); err != nil {
+		t.Fatalf("UPDATE schema_migrations: %v", err)
+	}
+
+	if version, dirty, err := migrator.Version(ctx); err != nil || version != migrator.Latest() || !dirty {
+		t.Errorf("Version = %d, %t, %v, want %d, true", version, dirty, err, migrator.Latest())
+	}
+	if _, err := migrator.Up(ctx); !errors.Is(err, ErrDirty) {
+		t.Errorf("Up = %v, want ErrDirty", err)
+	}
+	if _, err := migrator.Down(ctx, 1); !errors.Is(err, ErrDirty) {
+		t.Errorf("Down = %v, want ErrDirty", err)
+	}
+}
+
+func TestMigratorReadsFullyRevertedGolangMigrateVersion(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	migrator, err := New(db, migrations.FS)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	// Version создаёт таблицу schema_migrations
+	if _, _, err := migrator.Version(ctx); err != nil {
+		t.Fatalf("Version: %v", err)
+	}
+	// golang-migrate записывает версию -1 после отката всех миграций
+	if _, err := db.Exec(ctx, `INSERT INTO schema_migrations (version, dirty) VALUES (-1, FALSE)`); err != nil {
+		t.Fatalf("INSERT schema_migrations: %v", err)
+	}
+
+	if version, dirty, err := migrator.Version(ctx); err != nil || version != 0 || dirty {
+		t.Errorf("Version = %d, %t, %v, want 0", version, dirty, err)
+	}
+	if applied, err := migrator.Up(ctx); err != nil || applied != len(migrator.migrations) {
+		t.Errorf("Up = %d, %v, want %d", applied, err, len(migrator.migrations))
+	}
+}
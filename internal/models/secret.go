@@ -4,15 +4,60 @@ import (
 	"time"
 )
 
+// Режимы шифрования секрета
+const (
+	EncryptionModeServer  = "server"   // Сервер может расшифровать секрет
+	EncryptionModeClient  = "client"   // Контент зашифрован клиентом, сервер хранит непрозрачный блоб
+	EncryptionModeLinkKey = "link_key" // Ключ генерирует сервер и возвращает только во фрагменте ссылки
+)
+
+// Состояния секрета
+const (
+	SecretStatusPending  = "pending"  // Секрет ещё не активирован (available_at в будущем)
+	SecretStatusActive   = "active"   // Секрет можно прочитать
+	SecretStatusExpired  = "expired"  // Истёк срок действия
+	SecretStatusAccessed = "accessed" // Просмотры исчерпаны
+)
+
 // Secret представляет секрет в базе данных
 type Secret struct {
-	ID               string     `json:"id" db:"id"`
-	EncryptedContent string     `json:"-" db:"encrypted_content"`         // Зашифрованный контент (не отдаём в JSON)
-	IV               string     `json:"-" db:"iv"`                        // Initialization vector для расшифровки
-	ExpiresAt        time.Time  `json:"expires_at" db:"expires_at"`       // Время истечения срока действия
-	CreatedAt        time.Time  `json:"created_at" db:"created_at"`       // Время создания
-	AccessedAt       *time.Time `json:"accessed_at,omitempty" db:"accessed_at"` // Время первого доступа (nullable)
-	IsAccessed       bool       `json:"is_accessed" db:"is_accessed"`     // Был ли прочитан
+	ID                  string     `json:"id" db:"id"`
+	EncryptedContent    string     `json:"-" db:"encrypted_content"`                 // Зашифрованный контент (не отдаём в JSON)
+	IV                  string     `json:"-" db:"iv"`                                // Initialization vector для расшифровки
+	KeyID               string     `json:"-" db:"key_id"`                            // ID мастер-ключа, которым обёрнут ключ данных
+	WrappedKey          string     `json:"-" db:"wrapped_key"`                       // Ключ данных секрета, обёрнутый мастер-ключом
+	EncryptionMode      string     `json:"encryption_mode" db:"encryption_mode"`     // Режим шифрования (server, client, link_key)
+	PassphraseKDF       string     `json:"-" db:"passphrase_kdf"`                    // Параметры и соль Argon2id (пусто, если парольной фразы нет)
+	FailedAttempts      int        `json:"-" db:"failed_attempts"`                   // Количество неверных парольных фраз
+	ManagementTokenHash string     `json:"-" db:"management_token_hash"`             // SHA-256 хеш токена управления (пусто у старых секретов)
+	NotifyWebhookURL    string     `json:"-" db:"notify_webhook_url"`                // URL для уведомлений о событиях секрета
+	NotifyEmail         string     `json:"-" db:"notify_email"`                      // Адрес для писем о прочтении
+	MaxViews            int        `json:"max_views" db:"max_views"`                 // Сколько раз секрет можно открыть
+	ViewsRemaining      int        `json:"views_remaining" db:"views_remaining"`     // Сколько просмотров осталось
+	AvailableAt         *time.Time `json:"available_at,omitempty" db:"available_at"` // Время, с которого секрет можно прочитать (nullable)
+	ExpiresAt           time.Time  `json:"expires_at" db:"expires_at"`               // Время истечения срока действия
+	CreatedAt           time.Time  `json:"created_at" db:"created_at"`               // Время создания
+	AccessedAt          *time.Time `json:"accessed_at,omitempty" db:"accessed_at"`   // Время первого доступа (nullable)
+	IsAccessed          bool       `json:"is_accessed" db:"is_accessed"`             // Исчерпаны ли просмотры
+	ReaderIP            string     `json:"-" db:"reader_ip"`                         // IP получателя при первом просмотре (если сохраняется)
+	ReaderUserAgent     string     `json:"-" db:"reader_user_agent"`                 // User-Agent получателя при первом просмотре (если сохраняется)
+	FileName            string     `json:"file_name,omitempty" db:"file_name"`       // Имя файла (пусто у текстового секрета)
+	FileMIME            string     `json:"file_mime,omitempty" db:"file_mime"`       // MIME тип файла
+	FileSize            int64      `json:"file_size,omitempty" db:"file_size"`       // Размер файла в байтах
+	BlobKey             string     `json:"-" db:"blob_key"`                          // Ключ блоба с зашифрованным содержимым файла
+}
+
+// FileInfo описывает файл секрета
+type FileInfo struct {
+	Name string `json:"name"`
+	MIME string `json:"mime"`
+	Size int64  `json:"size"`
+}
+
+// ReaderInfo содержит сведения о получателе, сохраняемые при первом просмотре
+type ReaderInfo struct {
+	IP        string
+	UserAgent string
 }
 
 // IsExpired проверяет, истёк ли срок действия секрета
@@ -25,22 +70,143 @@ func (s *Secret) IsValid() bool {
 	return !s.IsExpired() && !s.IsAccessed
 }
 
+// IsAvailable проверяет, наступило ли время активации секрета
+func (s *Secret) IsAvailable() bool {
+	return s.AvailableAt == nil || !time.Now().Before(*s.AvailableAt)
+}
+
+// IsFile сообщает, что секрет - файл
+func (s *Secret) IsFile() bool {
+	return s.FileName != ""
+}
+
+// File возвращает описание файла секрета или nil для текстового секрета
+func (s *Secret) File() *FileInfo {
+	if !s.IsFile() {
+		return nil
+	}
+	return &FileInfo{Name: s.FileName, MIME: s.FileMIME, Size: s.FileSize}
+}
+
+// Status возвращает состояние секрета: pending, active, expired или accessed
+func (s *Secret) Status() string {
+	switch {
+	case s.IsAccessed:
+		return SecretStatusAccessed
+	case s.IsExpired():
+		return SecretStatusExpired
+	case !s.IsAvailable():
+		return SecretStatusPending
+	default:
+		return SecretStatusActive
+	}
+}
+
+// ExpirySpec задаёт время истечения секрета: можно указать только одно из полей
+type ExpirySpec struct {
+	TTL             string     `json:"ttl,omitempty"`              // Время жизни: "10m", "36h", "7d"
+	ExpirationHours int        `json:"expiration_hours,omitempty"` // Время жизни в часах (для совместимости)
+	ExpiresAt       *time.Time `json:"expires_at,omitempty"`       // Абсолютное время истечения
+}
+
+// IsEmpty сообщает, что время истечения не указано
+func (e *ExpirySpec) IsEmpty() bool {
+	return e.TTL == "" && e.ExpirationHours == 0 && e.ExpiresAt == nil
+}
+
 // CreateSecretRequest представляет запрос на создание секрета
 type CreateSecretRequest struct {
-	Content        string `json:"content" binding:"required"`          // Текст секрета
-	ExpirationHours int    `json:"expiration_hours" binding:"required,oneof=24 48 72"` // Время жизни: 24, 48 или 72 часа
+	ExpirySpec                  // Время жизни (опционально, по умолчанию DEFAULT_TTL)
+	Content          string     `json:"content" binding:"required"`   // Текст секрета
+	AvailableAt      *time.Time `json:"available_at,omitempty"`       // Секрет можно прочитать не раньше этого времени (опционально)
+	EncryptionMode   string     `json:"encryption_mode,omitempty"`    // Режим шифрования: server (по умолчанию), client или link_key
+	Passphrase       string     `json:"passphrase,omitempty"`         // Парольная фраза для дополнительного шифрования (опционально)
+	MaxViews         int        `json:"max_views,omitempty"`          // Сколько раз секрет можно открыть (по умолчанию 1)
+	NotifyWebhookURL string     `json:"notify_webhook_url,omitempty"` // URL для уведомлений о прочтении, истечении и отзыве (опционально)
+	RecipientEmail   string     `json:"recipient_email,omitempty"`    // Отправить ссылку на этот адрес (опционально)
+	NotifyEmail      string     `json:"notify_email,omitempty"`       // Сообщить о прочтении на этот адрес (опционально)
 }
 
-// CreateSecretResponse представляет ответ после создания секрета
-type CreateSecretResponse struct {
+// UpdateSecretRequest представляет запрос на изменение срока действия секрета
+type UpdateSecretRequest struct {
+	ExpirySpec // Новое время жизни, отсчитывается от текущего момента
+}
+
+// UpdateSecretResponse представляет ответ после изменения срока действия секрета
+type UpdateSecretResponse struct {
 	ID        string    `json:"id"`
-	URL       string    `json:"url"`
 	ExpiresAt time.Time `json:"expires_at"`
 }
 
+// CreateSecretResponse представляет ответ после создания секрета
+type CreateSecretResponse struct {
+	ID               string     `json:"id"`
+	URL              string     `json:"url"`
+	ManagementToken  string     `json:"management_token"`            // Токен для управления секретом; показывается только один раз
+	RecipientEmailed *bool      `json:"recipient_emailed,omitempty"` // Отправлена ли ссылка на recipient_email
+	MaxViews         int        `json:"max_views"`
+	AvailableAt      *time.Time `json:"available_at,omitempty"`
+	File             *FileInfo  `json:"file,omitempty"` // Описание файла (для секрета-файла)
+	ExpiresAt        time.Time  `json:"expires_at"`
+}
+
 // GetSecretResponse представляет ответ при получении секрета
 type GetSecretResponse struct {
-	Content   string    `json:"content"`
-	ExpiresAt time.Time `json:"expires_at"`
-	CreatedAt time.Time `json:"created_at"`
+	Content        string    `json:"content"`
+	EncryptionMode string    `json:"encryption_mode"` // Для client контент нужно расшифровать ключом из ссылки на клиенте
+	ViewsRemaining int       `json:"views_remaining"` // Сколько просмотров осталось (0 - секрет уничтожен)
+	ExpiresAt      time.Time `json:"expires_at"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// RevealChallengeResponse возвращается на GET, если включено двухшаговое открытие:
+// контент выдаётся только по POST /api/secrets/{id}/reveal с полученным nonce
+type RevealChallengeResponse struct {
+	RevealRequired     bool      `json:"reveal_required"`
+	Nonce              string    `json:"nonce"`
+	NonceExpiresAt     time.Time `json:"nonce_expires_at"`
+	RequiresPassphrase bool      `json:"requires_passphrase"`
+	EncryptionMode     string    `json:"encryption_mode"`
+	File               *FileInfo `json:"file,omitempty"`
+	ViewsRemaining     int       `json:"views_remaining"`
+	ExpiresAt          time.Time `json:"expires_at"`
+}
+
+// RevealSecretRequest представляет запрос на открытие секрета
+type RevealSecretRequest struct {
+	Nonce      string `json:"nonce"`                // Nonce из ответа GET /api/secrets/{id}
+	Passphrase string `json:"passphrase,omitempty"` // Парольная фраза (или заголовок X-Secret-Passphrase)
+	Key        string `json:"key,omitempty"`        // Ключ из ссылки для link_key (или заголовок X-Secret-Key)
+}
+
+// SecretStatusResponse представляет состояние секрета без его содержимого
+type SecretStatusResponse struct {
+	ID                 string     `json:"id"`
+	Exists             bool       `json:"exists"`
+	Status             string     `json:"status,omitempty"` // pending, active, expired или accessed
+	Expired            bool       `json:"expired"`
+	Accessed           bool       `json:"accessed"`
+	ViewsRemaining     int        `json:"views_remaining"`
+	RequiresPassphrase bool       `json:"requires_passphrase"`
+	EncryptionMode     string     `json:"encryption_mode,omitempty"`
+	File               *FileInfo  `json:"file,omitempty"`
+	AvailableAt        *time.Time `json:"available_at,omitempty"`
+	ExpiresAt          *time.Time `json:"expires_at,omitempty"`
+	CreatedAt          *time.Time `json:"created_at,omitempty"`
+}
+
+// SecretReceiptResponse представляет подтверждение прочтения для создателя секрета
+type SecretReceiptResponse struct {
+	ID              string     `json:"id"`
+	Status          string     `json:"status"` // pending, active, expired или accessed
+	CreatedAt       time.Time  `json:"created_at"`
+	AvailableAt     *time.Time `json:"available_at,omitempty"`
+	ExpiresAt       time.Time  `json:"expires_at"`
+	AccessedAt      *time.Time `json:"accessed_at"` // Время первого просмотра (null, если секрет не открывали)
+	MaxViews        int        `json:"max_views"`
+	Views           int        `json:"views"` // Сколько раз секрет открыли
+	ViewsRemaining  int        `json:"views_remaining"`
+	FailedAttempts  int        `json:"failed_attempts"` // Неверные парольные фразы
+	ReaderIP        string     `json:"reader_ip,omitempty"`
+	ReaderUserAgent string     `json:"reader_user_agent,omitempty"`
 }
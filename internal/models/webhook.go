@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+)
+
+// События секрета, о которых отправляются уведомления
+const (
+	WebhookEventRead    = "secret.read"    // Секрет открыт получателем
+	WebhookEventExpired = "secret.expired" // Секрет удалён непрочитанным после истечения срока
+	WebhookEventRevoked = "secret.revoked" // Секрет уничтожен создателем
+	WebhookEventBurned  = "secret.burned"  // Секрет уничтожен после исчерпания попыток ввода парольной фразы
+)
+
+// WebhookDelivery представляет уведомление в очереди исходящих уведомлений
+type WebhookDelivery struct {
+	ID         int64     `db:"id"`
+	SecretID   string    `db:"secret_id"`
+	Event      string    `db:"event"`
+	URL        string    `db:"url"`
+	OccurredAt time.Time `db:"occurred_at"`
+	Attempts   int       `db:"attempts"` // Количество неудачных попыток доставки
+}
+
+// WebhookPayload представляет тело уведомления. Содержимое секрета
+// в уведомление никогда не попадает.
+type WebhookPayload struct {
+	DeliveryID int64     `json:"delivery_id"`
+	Event      string    `json:"event"`
+	SecretID   string    `json:"secret_id"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
@@ -0,0 +1,46 @@
+package notify
+
+import (
+	"context"
+	"time"
+)
+
+// SecretLink - письмо получателю со ссылкой на секрет
+type SecretLink struct {
+	To        string
+	URL       string
+	ExpiresAt time.Time
+	MaxViews  int
+}
+
+// SecretOpened - письмо создателю о том, что секрет открыли
+type SecretOpened struct {
+	To             string
+	SecretID       string
+	OpenedAt       time.Time
+	ViewsRemaining int
+}
+
+// Notifier отправляет уведомления о секретах по электронной почте
+type Notifier interface {
+	// Enabled сообщает, настроена ли отправка уведомлений
+	Enabled() bool
+
+	// SendSecretLink отправляет получателю ссылку на секрет
+	SendSecretLink(ctx context.Context, msg SecretLink) error
+
+	// SendSecretOpened сообщает создателю, что секрет открыли
+	SendSecretOpened(ctx context.Context, msg SecretOpened) error
+}
+
+// NoopNotifier используется, когда отправка писем не настроена
+type NoopNotifier struct{}
+
+// Enabled всегда возвращает false
+func (NoopNotifier) Enabled() bool { return false }
+
+// SendSecretLink ничего не делает
+func (NoopNotifier) SendSecretLink(context.Context, SecretLink) error { return nil }
+
+// SendSecretOpened ничего не делает
+func (NoopNotifier) SendSecretOpened(context.Context, SecretOpened) error { return nil }
@@ -0,0 +1,184 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Шаблоны писем: первая строка - тема, остальное - текст письма
+var (
+	secretLinkTemplate = template.Must(template.New("secret_link").Parse(`Someone shared a secret with you
+Someone has shared a secret with you via Ares.
+
+Open it here:
+{{.URL}}
+
+The secret can be opened {{if eq .MaxViews 1}}only once{{else}}{{.MaxViews}} times{{end}} and will be destroyed after that.
+It expires at {{.ExpiresAt.UTC.Format "2006-01-02 15:04 MST"}}.
+
+If you were not expecting this message, you can safely ignore it.
+`))
+
+	secretOpenedTemplate = template.Must(template.New("secret_opened").Parse(`Your secret has been opened
+Your secret {{.SecretID}} was opened at {{.OpenedAt.UTC.Format "2006-01-02 15:04 MST"}}.
+{{if gt .ViewsRemaining 0}}
+It can be opened {{.ViewsRemaining}} more time(s).
+{{else}}
+It has been destroyed and can no longer be opened.
+{{end}}`))
+)
+
+// SMTPConfig содержит параметры SMTP сервера
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string // Пусто - без аутентификации
+	Password string
+	From     string
+	Timeout  time.Duration
+}
+
+// SMTPNotifier отправляет письма через SMTP сервер. Если сервер поддерживает
+// STARTTLS, соединение шифруется; аутентификация выполняется только по TLS
+// (или на localhost, например с MailHog).
+type SMTPNotifier struct {
+	cfg  SMTPConfig
+	from *mail.Address
+}
+
+// NewSMTPNotifier создаёт уведомитель с отправкой через SMTP
+func NewSMTPNotifier(cfg SMTPConfig) (*SMTPNotifier, error) {
+	if cfg.Host == "" {
+		return nil, fmt.Errorf("SMTP host is required")
+	}
+
+	from, err := mail.ParseAddress(cfg.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender address: %w", err)
+	}
+
+	return &SMTPNotifier{
+		cfg:  cfg,
+		from: from,
+	}, nil
+}
+
+// Enabled всегда возвращает true
+func (n *SMTPNotifier) Enabled() bool {
+	return true
+}
+
+// SendSecretLink отправляет получателю ссылку на секрет
+func (n *SMTPNotifier) SendSecretLink(ctx context.Context, msg SecretLink) error {
+	return n.send(ctx, msg.To, secretLinkTemplate, msg)
+}
+
+// SendSecretOpened сообщает создателю, что секрет открыли
+func (n *SMTPNotifier) SendSecretOpened(ctx context.Context, msg SecretOpened) error {
+	return n.send(ctx, msg.To, secretOpenedTemplate, msg)
+}
+
+// send формирует письмо по шаблону и отправляет его
+func (n *SMTPNotifier) send(ctx context.Context, to string, tmpl *template.Template, data any) error {
+	recipient, err := mail.ParseAddress(to)
+	if err != nil {
+		return fmt.Errorf("invalid recipient address: %w", err)
+	}
+
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, data); err != nil {
+		return fmt.Errorf("failed to render %s email: %w", tmpl.Name(), err)
+	}
+	subject, body, _ := strings.Cut(rendered.String(), "\n")
+
+	message := n.buildMessage(recipient, subject, body)
+	if err := n.deliver(ctx, recipient.Address, message); err != nil {
+		return fmt.Errorf("failed to send %s email: %w", tmpl.Name(), err)
+	}
+
+	return nil
+}
+
+// buildMessage формирует письмо в формате RFC 5322
+func (n *SMTPNotifier) buildMessage(to *mail.Address, subject, body string) []byte {
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", n.from.String())
+	fmt.Fprintf(&msg, "To: %s\r\n", to.String())
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	msg.WriteString("\r\n")
+	msg.WriteString(normalizeNewlines(body))
+	return msg.Bytes()
+}
+
+// normalizeNewlines приводит переводы строк (LF, CR, CRLF) к CRLF, как требует RFC 5322
+func normalizeNewlines(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	return strings.ReplaceAll(text, "\n", "\r\n")
+}
+
+// deliver передаёт письмо SMTP серверу
+func (n *SMTPNotifier) deliver(ctx context.Context, to string, message []byte) error {
+	address := net.JoinHostPort(n.cfg.Host, strconv.Itoa(n.cfg.Port))
+
+	dialer := &net.Dialer{Timeout: n.cfg.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	conn.SetDeadline(time.Now().Add(n.cfg.Timeout))
+
+	client, err := smtp.NewClient(conn, n.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to start SMTP session: %w", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: n.cfg.Host}); err != nil {
+			return fmt.Errorf("failed to start TLS: %w", err)
+		}
+	}
+
+	if n.cfg.Username != "" {
+		// PlainAuth сам отказывается передавать пароль без TLS (кроме localhost)
+		if err := client.Auth(smtp.PlainAuth("", n.cfg.Username, n.cfg.Password, n.cfg.Host)); err != nil {
+			return fmt.Errorf("SMTP authentication failed: %w", err)
+		}
+	}
+
+	if err := client.Mail(n.from.Address); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(message); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
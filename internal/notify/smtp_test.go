@@ -0,0 +1,207 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"mime"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestNotifier(t *testing.T, host string, port int) *SMTPNotifier {
+	t.Helper()
+	notifier, err := NewSMTPNotifier(SMTPConfig{
+		Host:    host,
+		Port:    port,
+		From:    "Ares <noreply@example.com>",
+		Timeout: 5 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("NewSMTPNotifier: %v", err)
+	}
+	return notifier
+}
+
+// parseMessage разбирает письмо и возвращает заголовки и тело
+func parseMessage(t *testing.T, message []byte) (*mail.Message, string) {
+	t.Helper()
+	parsed, err := mail.ReadMessage(strings.NewReader(string(message)))
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	body := new(strings.Builder)
+	if _, err := bufio.NewReader(parsed.Body).WriteTo(body); err != nil {
+		t.Fatalf("failed to read body: %v", err)
+	}
+	return parsed, body.String()
+}
+
+func TestBuildMessage(t *testing.T) {
+	notifier := newTestNotifier(t, "localhost", 1025)
+	to := &mail.Address{Name: "Иван Петров", Address: "ivan@example.com"}
+
+	message := notifier.buildMessage(to, "Секрет открыт", "line one\nline two\r\nline three\rend")
+	text := string(message)
+
+	// Каждый перевод строки - CRLF, одиночных CR и LF нет
+	if strings.Count(text, "\n") != strings.Count(text, "\r\n") || strings.Count(text, "\r") != strings.Count(text, "\r\n") {
+		t.Errorf("message contains bare CR or LF: %q", text)
+	}
+
+	parsed, body := parseMessage(t, message)
+
+	if got := parsed.Header.Get("From"); got != `"Ares" <noreply@example.com>` {
+		t.Errorf("From = %q", got)
+	}
+	if got := parsed.Header.Get("To"); !strings.HasPrefix(got, "=?utf-8?") || !strings.HasSuffix(got, " <ivan@example.com>") {
+		t.Errorf("To = %q, want encoded name and address", got)
+	}
+	recipients, err := parsed.Header.AddressList("To")
+	if err != nil || len(recipients) != 1 || recipients[0].Name != "Иван Петров" {
+		t.Errorf("To decodes to %v, %v", recipients, err)
+	}
+
+	subject := parsed.Header.Get("Subject")
+	if !strings.HasPrefix(subject, "=?utf-8?q?") {
+		t.Errorf("Subject = %q, want Q-encoded", subject)
+	}
+	decoded, err := new(mime.WordDecoder).DecodeHeader(subject)
+	if err != nil || decoded != "Секрет открыт" {
+		t.Errorf("Subject decodes to %q, %v", decoded, err)
+	}
+
+	if _, err := parsed.Header.Date(); err != nil {
+		t.Errorf("Date: %v", err)
+	}
+	if got := parsed.Header.Get("Content-Type"); got != "text/plain; charset=utf-8" {
+		t.Errorf("Content-Type = %q", got)
+	}
+
+	if want := "line one\r\nline two\r\nline three\r\nend"; body != want {
+		t.Errorf("body = %q, want %q", body, want)
+	}
+}
+
+func TestBuildMessageASCIISubject(t *testing.T) {
+	notifier := newTestNotifier(t, "localhost", 1025)
+	message := notifier.buildMessage(&mail.Address{Address: "bob@example.com"}, "Your secret has been opened", "body")
+
+	parsed, _ := parseMessage(t, message)
+	if got := parsed.Header.Get("Subject"); got != "Your secret has been opened" {
+		t.Errorf("Subject = %q, want it unencoded", got)
+	}
+	if got := parsed.Header.Get("To"); got != "<bob@example.com>" {
+		t.Errorf("To = %q", got)
+	}
+}
+
+// smtpSink - минимальный SMTP сервер, принимающий одно письмо (как MailHog)
+type smtpSink struct {
+	listener net.Listener
+	from     string
+	to       string
+	data     string
+	done     chan struct{}
+}
+
+func newSMTPSink(t *testing.T) *smtpSink {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	sink := &smtpSink{listener: listener, done: make(chan struct{})}
+	go sink.serve(t)
+	return sink
+}
+
+func (s *smtpSink) serve(t *testing.T) {
+	defer close(s.done)
+
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 sink ready")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+
+		command := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			text.PrintfLine("250 sink")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			s.from = line[len("MAIL FROM:"):]
+			text.PrintfLine("250 OK")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			s.to = line[len("RCPT TO:"):]
+			text.PrintfLine("250 OK")
+		case command == "DATA":
+			text.PrintfLine("354 send data")
+			lines, err := text.ReadDotLines()
+			if err != nil {
+				t.Errorf("failed to read DATA: %v", err)
+				return
+			}
+			s.data = strings.Join(lines, "\n")
+			text.PrintfLine("250 queued")
+		case command == "QUIT":
+			text.PrintfLine("221 bye")
+			return
+		default:
+			text.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+func TestSendSecretOpenedToSink(t *testing.T) {
+	sink := newSMTPSink(t)
+	host, portStr, _ := net.SplitHostPort(sink.listener.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+	notifier := newTestNotifier(t, host, port)
+
+	err := notifier.SendSecretOpened(context.Background(), SecretOpened{
+		To:             "owner@example.com",
+		SecretID:       "abc123",
+		OpenedAt:       time.Date(2026, 1, 2, 3, 4, 0, 0, time.UTC),
+		ViewsRemaining: 0,
+	})
+	if err != nil {
+		t.Fatalf("SendSecretOpened: %v", err)
+	}
+	<-sink.done
+
+	if sink.from != "<noreply@example.com>" || sink.to != "<owner@example.com>" {
+		t.Errorf("envelope = %s -> %s", sink.from, sink.to)
+	}
+	for _, want := range []string{
+		"Subject: Your secret has been opened",
+		"Your secret abc123 was opened at 2026-01-02 03:04 UTC.",
+		"It has been destroyed and can no longer be opened.",
+	} {
+		if !strings.Contains(sink.data, want) {
+			t.Errorf("message does not contain %q:\n%s", want, sink.data)
+		}
+	}
+}
+
+func TestSendRejectsInvalidRecipient(t *testing.T) {
+	notifier := newTestNotifier(t, "127.0.0.1", 1)
+	err := notifier.SendSecretLink(context.Background(), SecretLink{To: "not an address"})
+	if err == nil || !strings.Contains(err.Error(), "invalid recipient address") {
+		t.Errorf("SendSecretLink: err = %v, want invalid recipient address", err)
+	}
+}
@@ -0,0 +1,137 @@
+package rekey
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/savo4ka/ares-api/internal/crypto"
+	"github.com/savo4ka/ares-api/internal/metrics"
+	"github.com/savo4ka/ares-api/internal/models"
+	"github.com/savo4ka/ares-api/internal/repository"
+)
+
+// Job переводит секреты на активный мастер-ключ: для секретов с ключом данных
+// перешифровывается только ключ данных, секреты, зашифрованные мастер-ключом
+// напрямую, шифруются заново с собственным ключом данных.
+// Секреты обрабатываются порциями по возрастанию ID; каждая порция сохраняется
+// в отдельной транзакции, поэтому прерванный запуск можно просто повторить:
+// уже перешифрованные секреты больше не попадают в выборку.
+type Job struct {
+	repo              repository.SecretStore
+	encryptionService *crypto.EncryptionService
+	metrics           *metrics.Metrics
+	batchSize         int
+}
+
+// Result содержит итоги перешифрования
+type Result struct {
+	Reencrypted int64 // Перешифровано активным ключом
+	Skipped     int64 // Изменены параллельно (например, удалены) и пропущены
+	Failed      int64 // Не удалось расшифровать (например, ключ отсутствует в связке)
+}
+
+// NewJob создаёт задачу перешифрования
+func NewJob(repo repository.SecretStore, encryptionService *crypto.EncryptionService, m *metrics.Metrics, batchSize int) *Job {
+	return &Job{
+		repo:              repo,
+		encryptionService: encryptionService,
+		metrics:           m,
+		batchSize:         batchSize,
+	}
+}
+
+// Run обходит таблицу секретов и перешифровывает их активным ключом.
+// Отмена ctx останавливает задачу после текущей порции.
+func (j *Job) Run(ctx context.Context) (*Result, error) {
+	activeKeyID := j.encryptionService.ActiveKeyID()
+	result := &Result{}
+
+	remaining, err := j.repo.CountForRekey(ctx, activeKeyID)
+	if err != nil {
+		return result, err
+	}
+	j.metrics.RekeyRemainingSecrets.Set(float64(remaining))
+	log.Printf("Rekey started: %d secret(s) to re-encrypt with key %q", remaining, activeKeyID)
+
+	afterID := ""
+	for {
+		if err := ctx.Err(); err != nil {
+			return result, fmt.Errorf("rekey interrupted after secret %q: %w", afterID, err)
+		}
+
+		batch, err := j.repo.ListForRekey(ctx, activeKeyID, afterID, j.batchSize)
+		if err != nil {
+			return result, err
+		}
+
+		if len(batch) == 0 {
+			break
+		}
+		afterID = batch[len(batch)-1].ID
+
+		if err := j.processBatch(ctx, batch, result); err != nil {
+			return result, err
+		}
+
+		if remaining, err := j.repo.CountForRekey(ctx, activeKeyID); err == nil {
+			j.metrics.RekeyRemainingSecrets.Set(float64(remaining))
+		}
+
+		log.Printf("Rekey progress: %d re-encrypted, %d skipped, %d failed (last ID %s)",
+			result.Reencrypted, result.Skipped, result.Failed, afterID)
+	}
+
+	return result, nil
+}
+
+// processBatch перешифровывает порцию секретов и сохраняет её одной транзакцией
+func (j *Job) processBatch(ctx context.Context, batch []*models.Secret, result *Result) error {
+	updates := make([]repository.EncryptionUpdate, 0, len(batch))
+
+	for _, secret := range batch {
+		encryptedData, err := j.encryptionService.Reencrypt(ctx, &crypto.EncryptedData{
+			Ciphertext: secret.EncryptedContent,
+			IV:         secret.IV,
+			KeyID:      secret.KeyID,
+			WrappedKey: secret.WrappedKey,
+		}, secret.ID)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			result.Failed++
+			j.metrics.RekeySecretsTotal.WithLabelValues("failed").Inc()
+			log.Printf("Rekey: failed to re-encrypt secret %s (key %q): %v", secret.ID, secret.KeyID, err)
+			continue
+		}
+
+		updates = append(updates, repository.EncryptionUpdate{
+			Previous: secret,
+			Updated: &models.Secret{
+				ID:               secret.ID,
+				EncryptedContent: encryptedData.Ciphertext,
+				IV:               encryptedData.IV,
+				KeyID:            encryptedData.KeyID,
+				WrappedKey:       encryptedData.WrappedKey,
+			},
+		})
+	}
+
+	if len(updates) == 0 {
+		return nil
+	}
+
+	updated, err := j.repo.UpdateEncryption(ctx, updates)
+	if err != nil {
+		return err
+	}
+
+	skipped := int64(len(updates)) - updated
+	result.Reencrypted += updated
+	result.Skipped += skipped
+	j.metrics.RekeySecretsTotal.WithLabelValues("reencrypted").Add(float64(updated))
+	j.metrics.RekeySecretsTotal.WithLabelValues("skipped").Add(float64(skipped))
+
+	return nil
+}
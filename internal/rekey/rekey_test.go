@@ -0,0 +1,169 @@
+package rekey
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/savo4ka/ares-api/internal/crypto"
+	"github.com/savo4ka/ares-api/internal/metrics"
+	"github.com/savo4ka/ares-api/internal/models"
+	"github.com/savo4ka/ares-api/internal/repository"
+)
+
+// metrics.New регистрирует метрики глобально, поэтому создаётся один раз
+var testMetrics = metrics.New()
+
+var testKeys = map[string]string{
+	"old": "0123456789abcdef0123456789abcdef",
+	"new": "fedcba9876543210fedcba9876543210",
+}
+
+func newTestEncryptionService(t *testing.T, keys map[string]string, activeKeyID string) *crypto.EncryptionService {
+	t.Helper()
+	provider, err := crypto.NewLocalKeyProvider(keys, activeKeyID)
+	if err != nil {
+		t.Fatalf("NewLocalKeyProvider: %v", err)
+	}
+	return crypto.NewEncryptionService(provider)
+}
+
+// createSecret шифрует plaintext и сохраняет секрет в хранилище
+func createSecret(t *testing.T, store repository.SecretStore, enc *crypto.EncryptionService, id, plaintext string) {
+	t.Helper()
+	ctx := context.Background()
+
+	encrypted, err := enc.Encrypt(ctx, plaintext, id)
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	now := time.Now()
+	err = store.Create(ctx, &models.Secret{
+		ID:               id,
+		EncryptedContent: encrypted.Ciphertext,
+		IV:               encrypted.IV,
+		KeyID:            encrypted.KeyID,
+		WrappedKey:       encrypted.WrappedKey,
+		ViewsRemaining:   1,
+		ExpiresAt:        now.Add(time.Hour),
+		CreatedAt:        now,
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+}
+
+// revokingStore удаляет один из выбранных секретов между ListForRekey и
+// UpdateEncryption, как если бы его отозвали во время перешифрования
+type revokingStore struct {
+	*repository.MemoryStore
+	revokeID string
+}
+
+func (s *revokingStore) ListForRekey(ctx context.Context, activeKeyID, afterID string, limit int) ([]*models.Secret, error) {
+	secrets, err := s.MemoryStore.ListForRekey(ctx, activeKeyID, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	for _, secret := range secrets {
+		if secret.ID == s.revokeID {
+			if err := s.Revoke(ctx, secret.ID); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return secrets, nil
+}
+
+func TestRunReencryptsWithActiveKey(t *testing.T) {
+	ctx := context.Background()
+	store := &revokingStore{MemoryStore: repository.NewMemoryStore(), revokeID: "s3"}
+
+	before := newTestEncryptionService(t, testKeys, "old")
+	for i := 1; i <= 5; i++ {
+		createSecret(t, store, before, fmt.Sprintf("s%d", i), fmt.Sprintf("секрет %d", i))
+	}
+
+	after := newTestEncryptionService(t, testKeys, "new")
+	if count, err := store.CountForRekey(ctx, "new"); err != nil || count != 5 {
+		t.Fatalf("CountForRekey = %d, %v, want 5", count, err)
+	}
+
+	result, err := NewJob(store, after, testMetrics, 2).Run(ctx)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if result.Reencrypted != 4 || result.Skipped != 1 || result.Failed != 0 {
+		t.Errorf("result = %+v, want 4 re-encrypted, 1 skipped", *result)
+	}
+	if count, err := store.CountForRekey(ctx, "new"); err != nil || count != 0 {
+		t.Errorf("CountForRekey after Run = %d, %v, want 0", count, err)
+	}
+
+	// Перешифрованные секреты расшифровываются без старого ключа
+	onlyNew := newTestEncryptionService(t, map[string]string{"new": testKeys["new"]}, "new")
+	for i := 1; i <= 5; i++ {
+		id := fmt.Sprintf("s%d", i)
+		secret, err := store.GetByID(ctx, id)
+		if id == "s3" {
+			if err == nil {
+				t.Errorf("revoked secret %s restored by rekey", id)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("GetByID(%s): %v", id, err)
+		}
+		if secret.KeyID != "new" {
+			t.Errorf("%s: KeyID = %q, want new", id, secret.KeyID)
+		}
+		decrypted, err := onlyNew.Decrypt(ctx, &crypto.EncryptedData{
+			Ciphertext: secret.EncryptedContent,
+			IV:         secret.IV,
+			KeyID:      secret.KeyID,
+			WrappedKey: secret.WrappedKey,
+		}, id)
+		if want := fmt.Sprintf("секрет %d", i); err != nil || decrypted != want {
+			t.Errorf("%s: Decrypt = %q, %v, want %q", id, decrypted, err, want)
+		}
+	}
+
+	// Повторный запуск ничего не меняет
+	again, err := NewJob(store, after, testMetrics, 2).Run(ctx)
+	if err != nil {
+		t.Fatalf("second Run: %v", err)
+	}
+	if *again != (Result{}) {
+		t.Errorf("second Run result = %+v, want nothing to do", *again)
+	}
+}
+
+func TestRunCountsUndecryptableSecrets(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore()
+
+	retired := newTestEncryptionService(t, map[string]string{"retired": testKeys["old"]}, "retired")
+	createSecret(t, store, retired, "s1", "секрет")
+	createSecret(t, store, newTestEncryptionService(t, testKeys, "old"), "s2", "секрет")
+
+	result, err := NewJob(store, newTestEncryptionService(t, testKeys, "new"), testMetrics, 10).Run(ctx)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if result.Reencrypted != 1 || result.Failed != 1 || result.Skipped != 0 {
+		t.Errorf("result = %+v, want 1 re-encrypted, 1 failed", *result)
+	}
+
+	// Секрет с ключом вне связки остаётся в выборке
+	if count, err := store.CountForRekey(ctx, "new"); err != nil || count != 1 {
+		t.Errorf("CountForRekey = %d, %v, want 1", count, err)
+	}
+	secret, err := store.GetByID(ctx, "s1")
+	if err != nil {
+		t.Fatalf("GetByID(s1): %v", err)
+	}
+	if secret.KeyID != "retired" {
+		t.Errorf("s1: KeyID = %q, want it unchanged", secret.KeyID)
+	}
+}
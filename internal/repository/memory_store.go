@@ -0,0 +1,292 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/savo4ka/ares-api/internal/models"
+)
+
+// MemoryStore хранит секреты в памяти процесса. Подходит для разработки и тестов:
+// при перезапуске все секреты теряются. Уведомления (webhooks) не поддерживаются.
+type MemoryStore struct {
+	mu      sync.Mutex
+	secrets map[string]*models.Secret
+}
+
+// NewMemoryStore создаёт хранилище секретов в памяти
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		secrets: make(map[string]*models.Secret),
+	}
+}
+
+// Create сохраняет новый секрет
+func (s *MemoryStore) Create(_ context.Context, secret *models.Secret) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.secrets[secret.ID]; exists {
+		return fmt.Errorf("failed to create secret: duplicate id %s", secret.ID)
+	}
+
+	s.secrets[secret.ID] = cloneSecret(secret)
+	return nil
+}
+
+// GetByID возвращает копию секрета
+func (s *MemoryStore) GetByID(_ context.Context, id string) (*models.Secret, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	secret, ok := s.secrets[id]
+	if !ok {
+		return nil, ErrSecretNotFound
+	}
+	return cloneSecret(secret), nil
+}
+
+// GetMetadata возвращает копию секрета без шифртекста
+func (s *MemoryStore) GetMetadata(_ context.Context, id string) (*models.Secret, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	secret, ok := s.secrets[id]
+	if !ok {
+		return nil, ErrSecretNotFound
+	}
+
+	metadata := cloneSecret(secret)
+	metadata.EncryptedContent = ""
+	metadata.IV = ""
+	metadata.WrappedKey = ""
+	return metadata, nil
+}
+
+// Claim засчитывает просмотр (см. SecretRepository.Claim)
+func (s *MemoryStore) Claim(_ context.Context, id string, reader models.ReaderInfo) (*models.Secret, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	secret, ok := s.secrets[id]
+	if !ok || secret.IsAccessed || secret.ViewsRemaining <= 0 || !secret.ExpiresAt.After(now) ||
+		(secret.AvailableAt != nil && secret.AvailableAt.After(now)) {
+		return nil, ErrSecretUnavailable
+	}
+
+	if secret.AccessedAt == nil {
+		secret.ReaderIP = reader.IP
+		secret.ReaderUserAgent = reader.UserAgent
+		secret.AccessedAt = &now
+	}
+
+	secret.ViewsRemaining--
+	if secret.ViewsRemaining == 0 {
+		secret.IsAccessed = true
+		secret.EncryptedContent = ""
+		secret.IV = ""
+		secret.WrappedKey = ""
+		secret.PassphraseKDF = ""
+	}
+
+	return cloneSecret(secret), nil
+}
+
+// UpdateExpiry меняет время истечения (см. SecretRepository.UpdateExpiry)
+func (s *MemoryStore) UpdateExpiry(_ context.Context, id string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	secret, ok := s.secrets[id]
+	if !ok || secret.IsAccessed || !secret.ExpiresAt.After(time.Now()) ||
+		(secret.AvailableAt != nil && !secret.AvailableAt.Before(expiresAt)) {
+		return ErrSecretUnavailable
+	}
+
+	secret.ExpiresAt = expiresAt
+	return nil
+}
+
+// RegisterFailedAttempt учитывает неверную парольную фразу (см. SecretRepository.RegisterFailedAttempt)
+func (s *MemoryStore) RegisterFailedAttempt(_ context.Context, id string, maxAttempts int) (int, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	secret, ok := s.secrets[id]
+	if !ok || secret.IsAccessed {
+		return 0, false, ErrSecretUnavailable
+	}
+
+	secret.FailedAttempts++
+	burned := secret.FailedAttempts >= maxAttempts
+	if burned {
+		secret.IsAccessed = true
+		secret.ViewsRemaining = 0
+		secret.EncryptedContent = ""
+		secret.IV = ""
+		secret.WrappedKey = ""
+		secret.PassphraseKDF = ""
+	}
+
+	return secret.FailedAttempts, burned, nil
+}
+
+// Revoke удаляет секрет
+func (s *MemoryStore) Revoke(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.secrets[id]; !ok {
+		return ErrSecretNotFound
+	}
+
+	delete(s.secrets, id)
+	return nil
+}
+
+// CleanupExpired удаляет истёкшие секреты
+func (s *MemoryStore) CleanupExpired(_ context.Context) (int64, []string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var deleted int64
+	var blobKeys []string
+	for id, secret := range s.secrets {
+		if !secret.ExpiresAt.Before(now) {
+			continue
+		}
+
+		deleted++
+		if secret.BlobKey != "" {
+			blobKeys = append(blobKeys, secret.BlobKey)
+		}
+		delete(s.secrets, id)
+	}
+
+	return deleted, blobKeys, nil
+}
+
+// ReferencedBlobKeys возвращает те из keys, на которые ссылаются секреты
+func (s *MemoryStore) ReferencedBlobKeys(_ context.Context, keys []string) (map[string]bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	wanted := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		wanted[key] = true
+	}
+
+	referenced := make(map[string]bool)
+	for _, secret := range s.secrets {
+		if wanted[secret.BlobKey] {
+			referenced[secret.BlobKey] = true
+		}
+	}
+
+	return referenced, nil
+}
+
+// GetActiveSecretsCount возвращает количество активных секретов
+func (s *MemoryStore) GetActiveSecretsCount(_ context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var count int64
+	for _, secret := range s.secrets {
+		if !secret.IsAccessed && secret.ExpiresAt.After(now) {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// ListForRekey возвращает следующую порцию секретов для перешифрования
+func (s *MemoryStore) ListForRekey(_ context.Context, activeKeyID, afterID string, limit int) ([]*models.Secret, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var secrets []*models.Secret
+	for _, secret := range s.secrets {
+		if needsRekey(secret, activeKeyID) && secret.ID > afterID {
+			secrets = append(secrets, &models.Secret{
+				ID:               secret.ID,
+				EncryptedContent: secret.EncryptedContent,
+				IV:               secret.IV,
+				KeyID:            secret.KeyID,
+				WrappedKey:       secret.WrappedKey,
+			})
+		}
+	}
+
+	sort.Slice(secrets, func(i, j int) bool {
+		return secrets[i].ID < secrets[j].ID
+	})
+
+	if len(secrets) > limit {
+		secrets = secrets[:limit]
+	}
+	return secrets, nil
+}
+
+// CountForRekey возвращает количество секретов, которые нужно перешифровать
+func (s *MemoryStore) CountForRekey(_ context.Context, activeKeyID string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var count int64
+	for _, secret := range s.secrets {
+		if needsRekey(secret, activeKeyID) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// UpdateEncryption сохраняет перешифрованные секреты, если их ключ не изменился
+// и они ещё не прочитаны
+func (s *MemoryStore) UpdateEncryption(_ context.Context, updates []EncryptionUpdate) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var updated int64
+	for _, update := range updates {
+		secret, ok := s.secrets[update.Previous.ID]
+		if !ok || secret.IsAccessed || secret.KeyID != update.Previous.KeyID || secret.WrappedKey != update.Previous.WrappedKey {
+			continue
+		}
+
+		secret.EncryptedContent = update.Updated.EncryptedContent
+		secret.IV = update.Updated.IV
+		secret.KeyID = update.Updated.KeyID
+		secret.WrappedKey = update.Updated.WrappedKey
+		updated++
+	}
+
+	return updated, nil
+}
+
+// needsRekey сообщает, зашифрован ли непрочитанный секрет не активным ключом
+func needsRekey(secret *models.Secret, activeKeyID string) bool {
+	return !secret.IsAccessed && (secret.KeyID != activeKeyID || secret.WrappedKey == "")
+}
+
+// cloneSecret копирует секрет вместе со значениями указателей на время
+func cloneSecret(secret *models.Secret) *models.Secret {
+	clone := *secret
+	if secret.AvailableAt != nil {
+		availableAt := *secret.AvailableAt
+		clone.AvailableAt = &availableAt
+	}
+	if secret.AccessedAt != nil {
+		accessedAt := *secret.AccessedAt
+		clone.AccessedAt = &accessedAt
+	}
+	return &clone
+}
@@ -0,0 +1,665 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/savo4ka/ares-api/internal/models"
+)
+
+// redisScanBatch - сколько ID из индекса читать за один запрос
+const redisScanBatch = 500
+
+// RedisStore хранит секреты в Redis. Каждый секрет - хеш {prefix}secret:{id},
+// который Redis сам удаляет по TTL в момент expires_at, поэтому фоновая очистка
+// истёкших секретов для этого хранилища не нужна.
+//
+// Дополнительно ведутся индексы:
+//   - {prefix}unread - ID непрочитанных секретов (sorted set, упорядочен по ID)
+//     для подсчёта активных секретов и перешифрования;
+//   - {prefix}blob:{key} - ID секрета, ссылающегося на блоб (с тем же TTL).
+//
+// Изменяющие операции выполняются Lua-скриптами и атомарны. Скрипты обращаются
+// к ключам, вычисленным внутри скрипта, поэтому Redis Cluster не поддерживается.
+// Время хранится в микросекундах Unix (как в PostgreSQL), чтобы числа точно
+// представлялись в Lua.
+type RedisStore struct {
+	client       *redis.Client
+	prefix       string
+	queryTimeout time.Duration
+}
+
+// NewRedisStore подключается к Redis по URL вида redis://[:password@]host:port/db.
+// prefix добавляется ко всем ключам и позволяет делить один Redis между установками,
+// queryTimeout ограничивает время одной операции (0 - без ограничения).
+func NewRedisStore(url, prefix string, queryTimeout time.Duration) (*RedisStore, error) {
+	options, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse redis url: %w", err)
+	}
+
+	client := redis.NewClient(options)
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to ping redis: %w", err)
+	}
+
+	return &RedisStore{client: client, prefix: prefix, queryTimeout: queryTimeout}, nil
+}
+
+// Close закрывает подключение к Redis
+func (s *RedisStore) Close() error {
+	return s.client.Close()
+}
+
+func (s *RedisStore) secretKey(id string) string {
+	return s.prefix + "secret:" + id
+}
+
+func (s *RedisStore) unreadKey() string {
+	return s.prefix + "unread"
+}
+
+func (s *RedisStore) blobPrefix() string {
+	return s.prefix + "blob:"
+}
+
+// Create сохраняет новый секрет с TTL до expires_at
+func (s *RedisStore) Create(ctx context.Context, secret *models.Secret) error {
+	ctx, cancel := withTimeout(ctx, s.queryTimeout)
+	defer cancel()
+
+	key := s.secretKey(secret.ID)
+
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, secretToHash(secret))
+		pipe.PExpireAt(ctx, key, secret.ExpiresAt)
+		if !secret.IsAccessed {
+			pipe.ZAdd(ctx, s.unreadKey(), redis.Z{Member: secret.ID})
+		}
+		if secret.BlobKey != "" {
+			blobKey := s.blobPrefix() + secret.BlobKey
+			pipe.Set(ctx, blobKey, secret.ID, 0)
+			pipe.PExpireAt(ctx, blobKey, secret.ExpiresAt)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create secret: %w", err)
+	}
+
+	return nil
+}
+
+// GetByID получает секрет по ID
+func (s *RedisStore) GetByID(ctx context.Context, id string) (*models.Secret, error) {
+	ctx, cancel := withTimeout(ctx, s.queryTimeout)
+	defer cancel()
+
+	fields, err := s.client.HGetAll(ctx, s.secretKey(id)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get secret: %w", err)
+	}
+
+	if len(fields) == 0 {
+		return nil, ErrSecretNotFound
+	}
+
+	secret, err := secretFromHash(fields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get secret: %w", err)
+	}
+
+	return secret, nil
+}
+
+// GetMetadata получает метаданные секрета по ID без шифртекста
+func (s *RedisStore) GetMetadata(ctx context.Context, id string) (*models.Secret, error) {
+	secret, err := s.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	secret.EncryptedContent = ""
+	secret.IV = ""
+	secret.WrappedKey = ""
+	return secret, nil
+}
+
+// redisClaimScript засчитывает просмотр: KEYS - хеш секрета и индекс непрочитанных,
+// ARGV - ID, текущее время, IP и User-Agent получателя. На последнем просмотре
+// шифртекст удаляется из хеша. Возвращает хеш секрета или nil, если секрет недоступен.
+var redisClaimScript = redis.NewScript(`
+local now = tonumber(ARGV[2])
+local f = redis.call('HMGET', KEYS[1], 'is_accessed', 'views_remaining', 'expires_at', 'available_at', 'accessed_at')
+if not f[2] or f[1] == '1' or tonumber(f[2]) <= 0 or tonumber(f[3]) <= now
+	or (f[4] and tonumber(f[4]) > now) then
+	return false
+end
+
+local remaining = redis.call('HINCRBY', KEYS[1], 'views_remaining', -1)
+if not f[5] then
+	redis.call('HSET', KEYS[1], 'accessed_at', ARGV[2], 'reader_ip', ARGV[3], 'reader_user_agent', ARGV[4])
+end
+if remaining == 0 then
+	redis.call('HSET', KEYS[1], 'is_accessed', '1')
+	redis.call('HDEL', KEYS[1], 'encrypted_content', 'iv', 'wrapped_key', 'passphrase_kdf')
+	redis.call('ZREM', KEYS[2], ARGV[1])
+end
+
+return redis.call('HGETALL', KEYS[1])
+`)
+
+// Claim атомарно засчитывает просмотр секрета (см. SecretRepository.Claim)
+func (s *RedisStore) Claim(ctx context.Context, id string, reader models.ReaderInfo) (*models.Secret, error) {
+	ctx, cancel := withTimeout(ctx, s.queryTimeout)
+	defer cancel()
+
+	result, err := redisClaimScript.Run(ctx, s.client,
+		[]string{s.secretKey(id), s.unreadKey()},
+		id, time.Now().UnixMicro(), reader.IP, reader.UserAgent,
+	).StringSlice()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrSecretUnavailable
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim secret: %w", err)
+	}
+
+	fields := make(map[string]string, len(result)/2)
+	for i := 0; i+1 < len(result); i += 2 {
+		fields[result[i]] = result[i+1]
+	}
+
+	secret, err := secretFromHash(fields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim secret: %w", err)
+	}
+
+	return secret, nil
+}
+
+// redisUpdateExpiryScript меняет expires_at и TTL непрочитанного секрета и его блоба.
+// ARGV - новое время истечения (мкс), текущее время (мкс), новое время истечения (мс), префикс индекса блобов.
+var redisUpdateExpiryScript = redis.NewScript(`
+local f = redis.call('HMGET', KEYS[1], 'is_accessed', 'expires_at', 'available_at', 'blob_key')
+if not f[2] or f[1] == '1' or tonumber(f[2]) <= tonumber(ARGV[2])
+	or (f[3] and tonumber(f[3]) >= tonumber(ARGV[1])) then
+	return 0
+end
+
+redis.call('HSET', KEYS[1], 'expires_at', ARGV[1])
+redis.call('PEXPIREAT', KEYS[1], ARGV[3])
+if f[4] then
+	redis.call('PEXPIREAT', ARGV[4] .. f[4], ARGV[3])
+end
+return 1
+`)
+
+// UpdateExpiry изменяет срок действия секрета (см. SecretRepository.UpdateExpiry)
+func (s *RedisStore) UpdateExpiry(ctx context.Context, id string, expiresAt time.Time) error {
+	ctx, cancel := withTimeout(ctx, s.queryTimeout)
+	defer cancel()
+
+	updated, err := redisUpdateExpiryScript.Run(ctx, s.client,
+		[]string{s.secretKey(id)},
+		expiresAt.UnixMicro(), time.Now().UnixMicro(), expiresAt.UnixMilli(), s.blobPrefix(),
+	).Int()
+	if err != nil {
+		return fmt.Errorf("failed to update secret expiry: %w", err)
+	}
+
+	if updated == 0 {
+		return ErrSecretUnavailable
+	}
+
+	return nil
+}
+
+// redisFailedAttemptScript учитывает неверную парольную фразу и после ARGV[2] попыток
+// сжигает секрет так же, как последний просмотр. Возвращает {попытки, сожжён} или nil,
+// если секрет недоступен.
+var redisFailedAttemptScript = redis.NewScript(`
+local f = redis.call('HMGET', KEYS[1], 'is_accessed', 'views_remaining')
+if not f[2] or f[1] == '1' then
+	return false
+end
+
+local attempts = redis.call('HINCRBY', KEYS[1], 'failed_attempts', 1)
+if attempts < tonumber(ARGV[2]) then
+	return {attempts, 0}
+end
+
+redis.call('HSET', KEYS[1], 'is_accessed', '1', 'views_remaining', '0')
+redis.call('HDEL', KEYS[1], 'encrypted_content', 'iv', 'wrapped_key', 'passphrase_kdf')
+redis.call('ZREM', KEYS[2], ARGV[1])
+return {attempts, 1}
+`)
+
+// RegisterFailedAttempt учитывает неверную парольную фразу (см. SecretRepository.RegisterFailedAttempt)
+func (s *RedisStore) RegisterFailedAttempt(ctx context.Context, id string, maxAttempts int) (int, bool, error) {
+	ctx, cancel := withTimeout(ctx, s.queryTimeout)
+	defer cancel()
+
+	result, err := redisFailedAttemptScript.Run(ctx, s.client,
+		[]string{s.secretKey(id), s.unreadKey()},
+		id, maxAttempts,
+	).Int64Slice()
+	if errors.Is(err, redis.Nil) {
+		return 0, false, ErrSecretUnavailable
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to register failed attempt: %w", err)
+	}
+
+	return int(result[0]), result[1] == 1, nil
+}
+
+// redisDeleteScript удаляет секрет вместе с записями в индексах.
+// Возвращает 0, если секрета нет.
+var redisDeleteScript = redis.NewScript(`
+local blob = redis.call('HGET', KEYS[1], 'blob_key')
+if redis.call('DEL', KEYS[1]) == 0 then
+	return 0
+end
+
+redis.call('ZREM', KEYS[2], ARGV[1])
+if blob then
+	redis.call('DEL', ARGV[2] .. blob)
+end
+return 1
+`)
+
+// Revoke удаляет секрет по запросу создателя
+func (s *RedisStore) Revoke(ctx context.Context, id string) error {
+	ctx, cancel := withTimeout(ctx, s.queryTimeout)
+	defer cancel()
+
+	deleted, err := redisDeleteScript.Run(ctx, s.client,
+		[]string{s.secretKey(id), s.unreadKey()},
+		id, s.blobPrefix(),
+	).Int()
+	if err != nil {
+		return fmt.Errorf("failed to revoke secret: %w", err)
+	}
+
+	if deleted == 0 {
+		return ErrSecretNotFound
+	}
+
+	return nil
+}
+
+// CleanupExpired убирает из индекса непрочитанных секреты, которые Redis уже удалил по TTL,
+// и возвращает их количество. Ключи блобов не возвращаются: после истечения TTL они
+// неизвестны, такие блобы удаляет очистка осиротевших блобов.
+func (s *RedisStore) CleanupExpired(ctx context.Context) (int64, []string, error) {
+	ctx, cancel := withTimeout(ctx, s.queryTimeout)
+	defer cancel()
+
+	var expired []string
+	err := s.scanUnread(ctx, func(ids []string) error {
+		pipe := s.client.Pipeline()
+		exists := make([]*redis.IntCmd, len(ids))
+		for i, id := range ids {
+			exists[i] = pipe.Exists(ctx, s.secretKey(id))
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			return err
+		}
+
+		for i, id := range ids {
+			if exists[i].Val() == 0 {
+				expired = append(expired, id)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to cleanup expired secrets: %w", err)
+	}
+
+	if len(expired) == 0 {
+		return 0, nil, nil
+	}
+
+	members := make([]any, len(expired))
+	for i, id := range expired {
+		members[i] = id
+	}
+	if err := s.client.ZRem(ctx, s.unreadKey(), members...).Err(); err != nil {
+		return 0, nil, fmt.Errorf("failed to cleanup expired secrets: %w", err)
+	}
+
+	return int64(len(expired)), nil, nil
+}
+
+// ReferencedBlobKeys возвращает те из keys, на которые ссылаются секреты
+func (s *RedisStore) ReferencedBlobKeys(ctx context.Context, keys []string) (map[string]bool, error) {
+	ctx, cancel := withTimeout(ctx, s.queryTimeout)
+	defer cancel()
+
+	referenced := make(map[string]bool)
+	if len(keys) == 0 {
+		return referenced, nil
+	}
+
+	pipe := s.client.Pipeline()
+	exists := make([]*redis.IntCmd, len(keys))
+	for i, key := range keys {
+		exists[i] = pipe.Exists(ctx, s.blobPrefix()+key)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to check blob references: %w", err)
+	}
+
+	for i, key := range keys {
+		if exists[i].Val() > 0 {
+			referenced[key] = true
+		}
+	}
+
+	return referenced, nil
+}
+
+// GetActiveSecretsCount возвращает количество непрочитанных секретов. Секреты,
+// истёкшие после последнего вызова CleanupExpired, ещё учитываются.
+func (s *RedisStore) GetActiveSecretsCount(ctx context.Context) (int64, error) {
+	ctx, cancel := withTimeout(ctx, s.queryTimeout)
+	defer cancel()
+
+	count, err := s.client.ZCard(ctx, s.unreadKey()).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get active secrets count: %w", err)
+	}
+
+	return count, nil
+}
+
+// ListForRekey возвращает следующую порцию секретов для перешифрования (см. SecretRepository.ListForRekey)
+func (s *RedisStore) ListForRekey(ctx context.Context, activeKeyID, afterID string, limit int) ([]*models.Secret, error) {
+	ctx, cancel := withTimeout(ctx, s.queryTimeout)
+	defer cancel()
+
+	var secrets []*models.Secret
+	start := "-"
+	if afterID != "" {
+		start = "(" + afterID
+	}
+
+	for len(secrets) < limit {
+		ids, err := s.client.ZRangeByLex(ctx, s.unreadKey(), &redis.ZRangeBy{Min: start, Max: "+", Count: redisScanBatch}).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list secrets for rekey: %w", err)
+		}
+		if len(ids) == 0 {
+			break
+		}
+		start = "(" + ids[len(ids)-1]
+
+		batch, err := s.encryptionFields(ctx, ids)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list secrets for rekey: %w", err)
+		}
+
+		for _, secret := range batch {
+			if len(secrets) == limit {
+				break
+			}
+			if needsRekey(secret, activeKeyID) {
+				secrets = append(secrets, secret)
+			}
+		}
+	}
+
+	return secrets, nil
+}
+
+// CountForRekey возвращает количество секретов, которые нужно перевести на активный ключ
+func (s *RedisStore) CountForRekey(ctx context.Context, activeKeyID string) (int64, error) {
+	ctx, cancel := withTimeout(ctx, s.queryTimeout)
+	defer cancel()
+
+	var count int64
+	err := s.scanUnread(ctx, func(ids []string) error {
+		batch, err := s.encryptionFields(ctx, ids)
+		if err != nil {
+			return err
+		}
+
+		for _, secret := range batch {
+			if needsRekey(secret, activeKeyID) {
+				count++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count secrets for rekey: %w", err)
+	}
+
+	return count, nil
+}
+
+// redisUpdateEncryptionScript сохраняет перешифрованный секрет, если его ключ не изменился.
+// ARGV - прежние ID ключа и обёрнутый ключ, новые шифртекст, IV, ID ключа и обёрнутый ключ.
+var redisUpdateEncryptionScript = redis.NewScript(`
+local f = redis.call('HMGET', KEYS[1], 'is_accessed', 'key_id', 'wrapped_key')
+if not f[2] or f[1] == '1' or f[2] ~= ARGV[1] or (f[3] or '') ~= ARGV[2] then
+	return 0
+end
+
+redis.call('HSET', KEYS[1], 'encrypted_content', ARGV[3], 'iv', ARGV[4], 'key_id', ARGV[5])
+if ARGV[6] == '' then
+	redis.call('HDEL', KEYS[1], 'wrapped_key')
+else
+	redis.call('HSET', KEYS[1], 'wrapped_key', ARGV[6])
+end
+return 1
+`)
+
+// UpdateEncryption сохраняет перешифрованные секреты (см. SecretRepository.UpdateEncryption).
+// Каждый секрет обновляется атомарно, но порция целиком - нет.
+func (s *RedisStore) UpdateEncryption(ctx context.Context, updates []EncryptionUpdate) (int64, error) {
+	ctx, cancel := withTimeout(ctx, s.queryTimeout)
+	defer cancel()
+
+	var updated int64
+	for _, update := range updates {
+		n, err := redisUpdateEncryptionScript.Run(ctx, s.client,
+			[]string{s.secretKey(update.Previous.ID)},
+			update.Previous.KeyID,
+			update.Previous.WrappedKey,
+			update.Updated.EncryptedContent,
+			update.Updated.IV,
+			update.Updated.KeyID,
+			update.Updated.WrappedKey,
+		).Int64()
+		if err != nil {
+			return updated, fmt.Errorf("failed to update secret encryption: %w", err)
+		}
+		updated += n
+	}
+
+	return updated, nil
+}
+
+// scanUnread передаёт в fn ID из индекса непрочитанных порциями по redisScanBatch
+func (s *RedisStore) scanUnread(ctx context.Context, fn func(ids []string) error) error {
+	start := "-"
+	for {
+		ids, err := s.client.ZRangeByLex(ctx, s.unreadKey(), &redis.ZRangeBy{Min: start, Max: "+", Count: redisScanBatch}).Result()
+		if err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		if err := fn(ids); err != nil {
+			return err
+		}
+		start = "(" + ids[len(ids)-1]
+	}
+}
+
+// encryptionFields читает поля шифрования секретов; секреты, удалённые по TTL, пропускаются
+func (s *RedisStore) encryptionFields(ctx context.Context, ids []string) ([]*models.Secret, error) {
+	pipe := s.client.Pipeline()
+	cmds := make([]*redis.SliceCmd, len(ids))
+	for i, id := range ids {
+		cmds[i] = pipe.HMGet(ctx, s.secretKey(id), "encrypted_content", "iv", "key_id", "wrapped_key", "is_accessed")
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	secrets := make([]*models.Secret, 0, len(ids))
+	for i, id := range ids {
+		values := cmds[i].Val()
+		if values[2] == nil {
+			continue
+		}
+
+		secrets = append(secrets, &models.Secret{
+			ID:               id,
+			EncryptedContent: stringValue(values[0]),
+			IV:               stringValue(values[1]),
+			KeyID:            stringValue(values[2]),
+			WrappedKey:       stringValue(values[3]),
+			IsAccessed:       stringValue(values[4]) == "1",
+		})
+	}
+
+	return secrets, nil
+}
+
+// secretToHash переводит секрет в поля хеша Redis. Пустые необязательные поля не сохраняются.
+func secretToHash(secret *models.Secret) map[string]any {
+	fields := map[string]any{
+		"id":                secret.ID,
+		"encrypted_content": secret.EncryptedContent,
+		"iv":                secret.IV,
+		"key_id":            secret.KeyID,
+		"encryption_mode":   secret.EncryptionMode,
+		"failed_attempts":   secret.FailedAttempts,
+		"max_views":         secret.MaxViews,
+		"views_remaining":   secret.ViewsRemaining,
+		"expires_at":        secret.ExpiresAt.UnixMicro(),
+		"created_at":        secret.CreatedAt.UnixMicro(),
+		"is_accessed":       "0",
+		"file_size":         secret.FileSize,
+	}
+	if secret.IsAccessed {
+		fields["is_accessed"] = "1"
+	}
+
+	optional := map[string]string{
+		"wrapped_key":           secret.WrappedKey,
+		"passphrase_kdf":        secret.PassphraseKDF,
+		"management_token_hash": secret.ManagementTokenHash,
+		"notify_webhook_url":    secret.NotifyWebhookURL,
+		"notify_email":          secret.NotifyEmail,
+		"file_name":             secret.FileName,
+		"file_mime":             secret.FileMIME,
+		"blob_key":              secret.BlobKey,
+	}
+	for name, value := range optional {
+		if value != "" {
+			fields[name] = value
+		}
+	}
+
+	if secret.AvailableAt != nil {
+		fields["available_at"] = secret.AvailableAt.UnixMicro()
+	}
+	if secret.AccessedAt != nil {
+		fields["accessed_at"] = secret.AccessedAt.UnixMicro()
+	}
+
+	return fields
+}
+
+// secretFromHash восстанавливает секрет из полей хеша Redis
+func secretFromHash(fields map[string]string) (*models.Secret, error) {
+	secret := &models.Secret{
+		ID:                  fields["id"],
+		EncryptedContent:    fields["encrypted_content"],
+		IV:                  fields["iv"],
+		KeyID:               fields["key_id"],
+		WrappedKey:          fields["wrapped_key"],
+		EncryptionMode:      fields["encryption_mode"],
+		PassphraseKDF:       fields["passphrase_kdf"],
+		ManagementTokenHash: fields["management_token_hash"],
+		NotifyWebhookURL:    fields["notify_webhook_url"],
+		NotifyEmail:         fields["notify_email"],
+		IsAccessed:          fields["is_accessed"] == "1",
+		ReaderIP:            fields["reader_ip"],
+		ReaderUserAgent:     fields["reader_user_agent"],
+		FileName:            fields["file_name"],
+		FileMIME:            fields["file_mime"],
+		BlobKey:             fields["blob_key"],
+	}
+
+	var err error
+	integers := []struct {
+		name  string
+		value *int
+	}{
+		{"failed_attempts", &secret.FailedAttempts},
+		{"max_views", &secret.MaxViews},
+		{"views_remaining", &secret.ViewsRemaining},
+	}
+	for _, field := range integers {
+		if *field.value, err = strconv.Atoi(fields[field.name]); err != nil {
+			return nil, fmt.Errorf("invalid field %s: %w", field.name, err)
+		}
+	}
+
+	if secret.FileSize, err = strconv.ParseInt(fields["file_size"], 10, 64); err != nil {
+		return nil, fmt.Errorf("invalid field file_size: %w", err)
+	}
+
+	times := []struct {
+		name  string
+		value **time.Time
+	}{
+		{"available_at", &secret.AvailableAt},
+		{"accessed_at", &secret.AccessedAt},
+	}
+	for _, field := range times {
+		raw, ok := fields[field.name]
+		if !ok {
+			continue
+		}
+		micros, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid field %s: %w", field.name, err)
+		}
+		t := time.UnixMicro(micros)
+		*field.value = &t
+	}
+
+	expiresAt, err := strconv.ParseInt(fields["expires_at"], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid field expires_at: %w", err)
+	}
+	createdAt, err := strconv.ParseInt(fields["created_at"], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid field created_at: %w", err)
+	}
+	secret.ExpiresAt = time.UnixMicro(expiresAt)
+	secret.CreatedAt = time.UnixMicro(createdAt)
+
+	return secret, nil
+}
+
+// stringValue возвращает строку из ответа HMGET (nil для отсутствующего поля)
+func stringValue(value any) string {
+	s, _ := value.(string)
+	return s
+}
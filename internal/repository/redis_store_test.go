@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/savo4ka/ares-api/internal/models"
+)
+
+const testRedisPrefix = "ares:"
+
+// newTestRedisStore возвращает RedisStore поверх miniredis
+func newTestRedisStore(t *testing.T) (*RedisStore, *miniredis.Miniredis) {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	store, err := NewRedisStore("redis://"+server.Addr(), testRedisPrefix, 5*time.Second)
+	if err != nil {
+		t.Fatalf("NewRedisStore: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	return store, server
+}
+
+func TestRedisClaimDecrementsViews(t *testing.T) {
+	store, server := newTestRedisStore(t)
+	ctx := context.Background()
+
+	if err := store.Create(ctx, newTestSecret("secret1", 3)); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	reader := models.ReaderInfo{IP: "192.0.2.1", UserAgent: "first"}
+	for want := 2; want >= 1; want-- {
+		claimed, err := store.Claim(ctx, "secret1", reader)
+		if err != nil {
+			t.Fatalf("Claim: %v", err)
+		}
+		if claimed.ViewsRemaining != want {
+			t.Errorf("views_remaining = %d, want %d", claimed.ViewsRemaining, want)
+		}
+		if claimed.EncryptedContent == "" {
+			t.Errorf("content wiped with %d views remaining", want)
+		}
+		reader = models.ReaderInfo{IP: "192.0.2.2", UserAgent: "second"}
+	}
+
+	key := testRedisPrefix + "secret:secret1"
+	if got := server.HGet(key, "views_remaining"); got != "1" {
+		t.Errorf("stored views_remaining = %q, want 1", got)
+	}
+	// Сохраняется получатель первого просмотра
+	if got := server.HGet(key, "reader_ip"); got != "192.0.2.1" {
+		t.Errorf("reader_ip = %q, want the first reader", got)
+	}
+	if !server.Exists(testRedisPrefix + "unread") {
+		t.Errorf("secret with views remaining removed from the unread index")
+	}
+}
+
+func TestRedisClaimWipesLastView(t *testing.T) {
+	store, server := newTestRedisStore(t)
+	ctx := context.Background()
+
+	secret := newTestSecret("secret1", 1)
+	secret.PassphraseKDF = "argon2id$salt"
+	if err := store.Create(ctx, secret); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	claimed, err := store.Claim(ctx, "secret1", models.ReaderInfo{IP: "192.0.2.1"})
+	if err != nil {
+		t.Fatalf("Claim: %v", err)
+	}
+	if claimed.ViewsRemaining != 0 || !claimed.IsAccessed {
+		t.Errorf("claimed: views_remaining = %d, is_accessed = %t", claimed.ViewsRemaining, claimed.IsAccessed)
+	}
+
+	key := testRedisPrefix + "secret:secret1"
+	for _, field := range []string{"encrypted_content", "iv", "wrapped_key", "passphrase_kdf"} {
+		if got := server.HGet(key, field); got != "" {
+			t.Errorf("%s = %q after the last view, want it deleted", field, got)
+		}
+	}
+
+	// Метаданные остаются до истечения срока, секрет исключён из непрочитанных
+	metadata, err := store.GetMetadata(ctx, "secret1")
+	if err != nil {
+		t.Fatalf("GetMetadata: %v", err)
+	}
+	if !metadata.IsAccessed || metadata.AccessedAt == nil {
+		t.Errorf("metadata: is_accessed = %t, accessed_at = %v", metadata.IsAccessed, metadata.AccessedAt)
+	}
+	if count, err := store.GetActiveSecretsCount(ctx); err != nil || count != 0 {
+		t.Errorf("GetActiveSecretsCount = %d, %v, want 0", count, err)
+	}
+
+	if _, err := store.Claim(ctx, "secret1", models.ReaderInfo{}); !errors.Is(err, ErrSecretUnavailable) {
+		t.Errorf("second Claim: err = %v, want ErrSecretUnavailable", err)
+	}
+}
+
+func TestRedisSecretExpires(t *testing.T) {
+	store, server := newTestRedisStore(t)
+	ctx := context.Background()
+
+	secret := newTestSecret("secret1", 2)
+	secret.ExpiresAt = time.Now().Add(time.Minute)
+	if err := store.Create(ctx, secret); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	key := testRedisPrefix + "secret:secret1"
+	if ttl := server.TTL(key); ttl <= 0 || ttl > time.Minute {
+		t.Fatalf("TTL = %v, want up to a minute", ttl)
+	}
+
+	server.FastForward(2 * time.Minute)
+
+	if server.Exists(key) {
+		t.Errorf("secret hash still exists after expires_at")
+	}
+	if _, err := store.GetMetadata(ctx, "secret1"); !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("GetMetadata: err = %v, want ErrSecretNotFound", err)
+	}
+	if _, err := store.Claim(ctx, "secret1", models.ReaderInfo{}); !errors.Is(err, ErrSecretUnavailable) {
+		t.Errorf("Claim: err = %v, want ErrSecretUnavailable", err)
+	}
+}
+
+func TestRedisClaimRejectsExpiredBeforeTTL(t *testing.T) {
+	store, server := newTestRedisStore(t)
+	ctx := context.Background()
+
+	if err := store.Create(ctx, newTestSecret("secret1", 1)); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	// Ключ ещё не удалён по TTL, но expires_at уже прошёл
+	key := testRedisPrefix + "secret:secret1"
+	server.HSet(key, "expires_at", strconv.FormatInt(time.Now().Add(-time.Second).UnixMicro(), 10))
+
+	if _, err := store.Claim(ctx, "secret1", models.ReaderInfo{}); !errors.Is(err, ErrSecretUnavailable) {
+		t.Errorf("Claim: err = %v, want ErrSecretUnavailable", err)
+	}
+	if got := server.HGet(key, "views_remaining"); got != "1" {
+		t.Errorf("views_remaining = %q, want it unchanged", got)
+	}
+}
@@ -1,41 +1,74 @@
 package repository
 
 import (
-	"database/sql"
+	"context"
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/savo4ka/ares-api/internal/database"
 	"github.com/savo4ka/ares-api/internal/models"
 )
 
+var (
+	// ErrSecretNotFound возвращается, когда секрета с таким ID нет
+	ErrSecretNotFound = errors.New("secret not found")
+
+	// ErrSecretUnavailable возвращается, когда секрет уже прочитан или истёк
+	ErrSecretUnavailable = errors.New("secret is no longer available")
+)
+
 // SecretRepository предоставляет методы для работы с секретами в БД
 type SecretRepository struct {
-	db *database.DB
+	db           *database.DB
+	queryTimeout time.Duration
 }
 
-// NewSecretRepository создаёт новый репозиторий секретов
-func NewSecretRepository(db *database.DB) *SecretRepository {
+// NewSecretRepository создаёт новый репозиторий секретов.
+// queryTimeout ограничивает время одного запроса (0 - без ограничения).
+func NewSecretRepository(db *database.DB, queryTimeout time.Duration) *SecretRepository {
 	return &SecretRepository{
-		db: db,
+		db:           db,
+		queryTimeout: queryTimeout,
 	}
 }
 
 // Create создаёт новый секрет в базе данных
-func (r *SecretRepository) Create(secret *models.Secret) error {
+func (r *SecretRepository) Create(ctx context.Context, secret *models.Secret) error {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	query := `
-		INSERT INTO secrets (id, encrypted_content, iv, expires_at, created_at, is_accessed)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO secrets (id, encrypted_content, iv, key_id, wrapped_key, encryption_mode, passphrase_kdf,
+			management_token_hash, notify_webhook_url, notify_email, max_views, views_remaining, available_at,
+			expires_at, created_at, is_accessed, file_name, file_mime, file_size, blob_key)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, NULLIF($7, ''), NULLIF($8, ''), NULLIF($9, ''), NULLIF($10, ''),
+			$11, $12, $13, $14, $15, $16, NULLIF($17, ''), NULLIF($18, ''), $19, NULLIF($20, ''))
 	`
 
-	_, err := r.db.Exec(
+	_, err := r.db.Exec(ctx,
 		query,
 		secret.ID,
 		secret.EncryptedContent,
 		secret.IV,
+		secret.KeyID,
+		secret.WrappedKey,
+		secret.EncryptionMode,
+		secret.PassphraseKDF,
+		secret.ManagementTokenHash,
+		secret.NotifyWebhookURL,
+		secret.NotifyEmail,
+		secret.MaxViews,
+		secret.ViewsRemaining,
+		secret.AvailableAt,
 		secret.ExpiresAt,
 		secret.CreatedAt,
 		secret.IsAccessed,
+		secret.FileName,
+		secret.FileMIME,
+		secret.FileSize,
+		secret.BlobKey,
 	)
 
 	if err != nil {
@@ -46,26 +79,44 @@ func (r *SecretRepository) Create(secret *models.Secret) error {
 }
 
 // GetByID получает секрет по ID
-func (r *SecretRepository) GetByID(id string) (*models.Secret, error) {
+func (r *SecretRepository) GetByID(ctx context.Context, id string) (*models.Secret, error) {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	query := `
-		SELECT id, encrypted_content, iv, expires_at, created_at, accessed_at, is_accessed
+		SELECT id, encrypted_content, iv, key_id, COALESCE(wrapped_key, ''), encryption_mode,
+			COALESCE(passphrase_kdf, ''), failed_attempts, max_views, views_remaining,
+			available_at, expires_at, created_at, accessed_at, is_accessed,
+			COALESCE(file_name, ''), COALESCE(file_mime, ''), file_size, COALESCE(blob_key, '')
 		FROM secrets
 		WHERE id = $1
 	`
 
 	secret := &models.Secret{}
-	err := r.db.QueryRow(query, id).Scan(
+	err := r.db.QueryRow(ctx, query, id).Scan(
 		&secret.ID,
 		&secret.EncryptedContent,
 		&secret.IV,
+		&secret.KeyID,
+		&secret.WrappedKey,
+		&secret.EncryptionMode,
+		&secret.PassphraseKDF,
+		&secret.FailedAttempts,
+		&secret.MaxViews,
+		&secret.ViewsRemaining,
+		&secret.AvailableAt,
 		&secret.ExpiresAt,
 		&secret.CreatedAt,
 		&secret.AccessedAt,
 		&secret.IsAccessed,
+		&secret.FileName,
+		&secret.FileMIME,
+		&secret.FileSize,
+		&secret.BlobKey,
 	)
 
-	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("secret not found")
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrSecretNotFound
 	}
 
 	if err != nil {
@@ -75,76 +126,410 @@ func (r *SecretRepository) GetByID(id string) (*models.Secret, error) {
 	return secret, nil
 }
 
-// MarkAsAccessed помечает секрет как прочитанный
-func (r *SecretRepository) MarkAsAccessed(id string) error {
+// GetMetadata получает метаданные секрета по ID, не читая шифртекст
+func (r *SecretRepository) GetMetadata(ctx context.Context, id string) (*models.Secret, error) {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	query := `
-		UPDATE secrets
-		SET is_accessed = TRUE, accessed_at = $1
-		WHERE id = $2
+		SELECT id, encryption_mode, COALESCE(passphrase_kdf, ''), failed_attempts, COALESCE(management_token_hash, ''),
+			max_views, views_remaining, available_at, expires_at, created_at, accessed_at, is_accessed,
+			COALESCE(reader_ip, ''), COALESCE(reader_user_agent, ''),
+			COALESCE(file_name, ''), COALESCE(file_mime, ''), file_size, COALESCE(blob_key, '')
+		FROM secrets
+		WHERE id = $1
 	`
 
-	now := time.Now()
-	result, err := r.db.Exec(query, now, id)
+	secret := &models.Secret{}
+	err := r.db.QueryRow(ctx, query, id).Scan(
+		&secret.ID,
+		&secret.EncryptionMode,
+		&secret.PassphraseKDF,
+		&secret.FailedAttempts,
+		&secret.ManagementTokenHash,
+		&secret.MaxViews,
+		&secret.ViewsRemaining,
+		&secret.AvailableAt,
+		&secret.ExpiresAt,
+		&secret.CreatedAt,
+		&secret.AccessedAt,
+		&secret.IsAccessed,
+		&secret.ReaderIP,
+		&secret.ReaderUserAgent,
+		&secret.FileName,
+		&secret.FileMIME,
+		&secret.FileSize,
+		&secret.BlobKey,
+	)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrSecretNotFound
+	}
+
 	if err != nil {
-		return fmt.Errorf("failed to mark secret as accessed: %w", err)
+		return nil, fmt.Errorf("failed to get secret metadata: %w", err)
+	}
+
+	return secret, nil
+}
+
+// Claim атомарно засчитывает просмотр секрета, если он уже активирован, ещё не прочитан и не истёк.
+// На последнем просмотре секрет помечается прочитанным, а шифртекст уничтожается
+// в том же запросе: в БД остаётся только запись-надгробие с метаданными.
+// Из нескольких параллельных вызовов успешны не больше, чем осталось просмотров,
+// остальные получают ErrSecretUnavailable.
+// Сведения о получателе сохраняются при первом просмотре вместе с accessed_at,
+// уведомление о прочтении ставится в очередь в том же запросе.
+func (r *SecretRepository) Claim(ctx context.Context, id string, reader models.ReaderInfo) (*models.Secret, error) {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		WITH claimed AS (
+			UPDATE secrets
+			SET views_remaining = views_remaining - 1,
+				reader_ip = CASE WHEN accessed_at IS NULL THEN NULLIF($3, '') ELSE reader_ip END,
+				reader_user_agent = CASE WHEN accessed_at IS NULL THEN NULLIF($4, '') ELSE reader_user_agent END,
+				accessed_at = COALESCE(accessed_at, $2),
+				is_accessed = views_remaining <= 1,
+				encrypted_content = CASE WHEN views_remaining <= 1 THEN '' ELSE encrypted_content END,
+				iv = CASE WHEN views_remaining <= 1 THEN '' ELSE iv END,
+				wrapped_key = CASE WHEN views_remaining <= 1 THEN NULL ELSE wrapped_key END,
+				passphrase_kdf = CASE WHEN views_remaining <= 1 THEN NULL ELSE passphrase_kdf END
+			WHERE id = $1 AND is_accessed = FALSE AND views_remaining > 0 AND expires_at > $2
+				AND (available_at IS NULL OR available_at <= $2)
+			RETURNING id, max_views, views_remaining, expires_at, created_at, accessed_at, is_accessed,
+				notify_webhook_url, notify_email
+		), events AS (
+			INSERT INTO webhook_outbox (secret_id, event, url, occurred_at, next_attempt_at)
+			SELECT id, $5::VARCHAR, notify_webhook_url, $2, $2 FROM claimed WHERE notify_webhook_url IS NOT NULL
+		)
+		SELECT id, max_views, views_remaining, expires_at, created_at, accessed_at, is_accessed,
+			COALESCE(notify_email, '')
+		FROM claimed
+	`
+
+	secret := &models.Secret{}
+	err := r.db.QueryRow(ctx, query, id, time.Now(), reader.IP, reader.UserAgent, models.WebhookEventRead).Scan(
+		&secret.ID,
+		&secret.MaxViews,
+		&secret.ViewsRemaining,
+		&secret.ExpiresAt,
+		&secret.CreatedAt,
+		&secret.AccessedAt,
+		&secret.IsAccessed,
+		&secret.NotifyEmail,
+	)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrSecretUnavailable
 	}
 
-	rows, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+		return nil, fmt.Errorf("failed to claim secret: %w", err)
 	}
 
-	if rows == 0 {
-		return fmt.Errorf("secret not found")
+	return secret, nil
+}
+
+// UpdateExpiry изменяет срок действия секрета, который ещё можно прочитать.
+// Возвращает ErrSecretUnavailable, если секрет уже прочитан или истёк,
+// или новый срок наступает раньше активации секрета.
+func (r *SecretRepository) UpdateExpiry(ctx context.Context, id string, expiresAt time.Time) error {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		UPDATE secrets
+		SET expires_at = $2
+		WHERE id = $1 AND is_accessed = FALSE AND expires_at > $3
+			AND (available_at IS NULL OR available_at < $2)
+	`
+
+	result, err := r.db.Exec(ctx, query, id, expiresAt, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to update secret expiry: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrSecretUnavailable
 	}
 
 	return nil
 }
 
-// CleanupExpired удаляет истёкшие секреты из базы данных
-func (r *SecretRepository) CleanupExpired() (int64, error) {
+// RegisterFailedAttempt учитывает неверную парольную фразу. Когда количество
+// неверных попыток достигает maxAttempts, секрет сжигается так же, как после
+// последнего просмотра: шифртекст уничтожается, секрет помечается прочитанным
+// (повторный запрос получает 410 Gone), уведомление ставится в очередь в том же запросе.
+// Возвращает количество неверных попыток и признак того, что секрет сожжён.
+func (r *SecretRepository) RegisterFailedAttempt(ctx context.Context, id string, maxAttempts int) (int, bool, error) {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	query := `
-		DELETE FROM secrets
-		WHERE expires_at < $1
+		WITH attempt AS (
+			UPDATE secrets
+			SET failed_attempts = failed_attempts + 1,
+				is_accessed = failed_attempts + 1 >= $2,
+				views_remaining = CASE WHEN failed_attempts + 1 >= $2 THEN 0 ELSE views_remaining END,
+				encrypted_content = CASE WHEN failed_attempts + 1 >= $2 THEN '' ELSE encrypted_content END,
+				iv = CASE WHEN failed_attempts + 1 >= $2 THEN '' ELSE iv END,
+				wrapped_key = CASE WHEN failed_attempts + 1 >= $2 THEN NULL ELSE wrapped_key END,
+				passphrase_kdf = CASE WHEN failed_attempts + 1 >= $2 THEN NULL ELSE passphrase_kdf END
+			WHERE id = $1 AND is_accessed = FALSE
+			RETURNING id, failed_attempts, is_accessed, notify_webhook_url
+		), events AS (
+			INSERT INTO webhook_outbox (secret_id, event, url, occurred_at, next_attempt_at)
+			SELECT id, $3::VARCHAR, notify_webhook_url, $4, $4 FROM attempt
+			WHERE is_accessed AND notify_webhook_url IS NOT NULL
+		)
+		SELECT failed_attempts, is_accessed FROM attempt
 	`
 
-	result, err := r.db.Exec(query, time.Now())
+	var attempts int
+	var burned bool
+	err := r.db.QueryRow(ctx, query, id, maxAttempts, models.WebhookEventBurned, time.Now()).Scan(&attempts, &burned)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, false, ErrSecretUnavailable
+	}
 	if err != nil {
-		return 0, fmt.Errorf("failed to cleanup expired secrets: %w", err)
+		return 0, false, fmt.Errorf("failed to register failed attempt: %w", err)
+	}
+
+	return attempts, burned, nil
+}
+
+// CleanupExpired удаляет истёкшие секреты из базы данных и ставит в очередь
+// уведомления об истечении для секретов, которые так и не были прочитаны.
+// Возвращает количество удалённых секретов и ключи их блобов (блобы удаляет вызывающий).
+func (r *SecretRepository) CleanupExpired(ctx context.Context) (int64, []string, error) {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		WITH deleted AS (
+			DELETE FROM secrets
+			WHERE expires_at < $1
+			RETURNING id, is_accessed, notify_webhook_url, blob_key
+		), events AS (
+			INSERT INTO webhook_outbox (secret_id, event, url, occurred_at, next_attempt_at)
+			SELECT id, $2::VARCHAR, notify_webhook_url, $1, $1 FROM deleted
+			WHERE is_accessed = FALSE AND notify_webhook_url IS NOT NULL
+		)
+		SELECT COALESCE(blob_key, '') FROM deleted
+	`
+
+	rows, err := r.db.Query(ctx, query, time.Now(), models.WebhookEventExpired)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to cleanup expired secrets: %w", err)
+	}
+	defer rows.Close()
+
+	var deleted int64
+	var blobKeys []string
+	for rows.Next() {
+		var blobKey string
+		if err := rows.Scan(&blobKey); err != nil {
+			return 0, nil, fmt.Errorf("failed to scan expired secret: %w", err)
+		}
+		deleted++
+		if blobKey != "" {
+			blobKeys = append(blobKeys, blobKey)
+		}
 	}
 
-	rows, err := result.RowsAffected()
+	if err := rows.Err(); err != nil {
+		return 0, nil, fmt.Errorf("failed to cleanup expired secrets: %w", err)
+	}
+
+	return deleted, blobKeys, nil
+}
+
+// ReferencedBlobKeys возвращает те из keys, на которые ссылаются секреты
+func (r *SecretRepository) ReferencedBlobKeys(ctx context.Context, keys []string) (map[string]bool, error) {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	rows, err := r.db.Query(ctx, `SELECT blob_key FROM secrets WHERE blob_key = ANY($1)`, keys)
 	if err != nil {
-		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+		return nil, fmt.Errorf("failed to check blob references: %w", err)
+	}
+	defer rows.Close()
+
+	referenced := make(map[string]bool)
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, fmt.Errorf("failed to scan blob key: %w", err)
+		}
+		referenced[key] = true
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to check blob references: %w", err)
 	}
 
-	return rows, nil
+	return referenced, nil
 }
 
-// DeleteByID удаляет секрет по ID (для тестирования или админских функций)
-func (r *SecretRepository) DeleteByID(id string) error {
+// Revoke удаляет секрет по запросу создателя и ставит в очередь уведомление об отзыве
+func (r *SecretRepository) Revoke(ctx context.Context, id string) error {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		WITH deleted AS (
+			DELETE FROM secrets
+			WHERE id = $1
+			RETURNING id, notify_webhook_url
+		), events AS (
+			INSERT INTO webhook_outbox (secret_id, event, url, occurred_at, next_attempt_at)
+			SELECT id, $2::VARCHAR, notify_webhook_url, $3::TIMESTAMPTZ, $3::TIMESTAMPTZ FROM deleted WHERE notify_webhook_url IS NOT NULL
+		)
+		SELECT COUNT(*) FROM deleted
+	`
+
+	var rows int64
+	if err := r.db.QueryRow(ctx, query, id, models.WebhookEventRevoked, time.Now()).Scan(&rows); err != nil {
+		return fmt.Errorf("failed to revoke secret: %w", err)
+	}
+
+	if rows == 0 {
+		return ErrSecretNotFound
+	}
+
+	return nil
+}
+
+// DeleteByID удаляет секрет по ID
+func (r *SecretRepository) DeleteByID(ctx context.Context, id string) error {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	query := `DELETE FROM secrets WHERE id = $1`
 
-	result, err := r.db.Exec(query, id)
+	result, err := r.db.Exec(ctx, query, id)
 	if err != nil {
 		return fmt.Errorf("failed to delete secret: %w", err)
 	}
 
-	rows, err := result.RowsAffected()
+	if result.RowsAffected() == 0 {
+		return ErrSecretNotFound
+	}
+
+	return nil
+}
+
+// EncryptionUpdate описывает перешифрование секрета
+type EncryptionUpdate struct {
+	Previous *models.Secret // Секрет в том виде, в котором он был прочитан
+	Updated  *models.Secret // Новые шифртекст, IV, ID ключа и обёрнутый ключ данных
+}
+
+// ListForRekey возвращает следующую порцию непрочитанных секретов, которые нужно перевести
+// на активный ключ: зашифрованных другим мастер-ключом или мастер-ключом напрямую (без ключа данных).
+// Секреты упорядочены по ID: afterID - последний ID предыдущей порции (пустая строка для первой)
+func (r *SecretRepository) ListForRekey(ctx context.Context, activeKeyID, afterID string, limit int) ([]*models.Secret, error) {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT id, encrypted_content, iv, key_id, COALESCE(wrapped_key, '')
+		FROM secrets
+		WHERE (key_id <> $1 OR wrapped_key IS NULL) AND is_accessed = FALSE AND id > $2
+		ORDER BY id
+		LIMIT $3
+	`
+
+	rows, err := r.db.Query(ctx, query, activeKeyID, afterID, limit)
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+		return nil, fmt.Errorf("failed to list secrets for rekey: %w", err)
 	}
+	defer rows.Close()
 
-	if rows == 0 {
-		return fmt.Errorf("secret not found")
+	var secrets []*models.Secret
+	for rows.Next() {
+		secret := &models.Secret{}
+		if err := rows.Scan(&secret.ID, &secret.EncryptedContent, &secret.IV, &secret.KeyID, &secret.WrappedKey); err != nil {
+			return nil, fmt.Errorf("failed to scan secret: %w", err)
+		}
+		secrets = append(secrets, secret)
 	}
 
-	return nil
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list secrets for rekey: %w", err)
+	}
+
+	return secrets, nil
+}
+
+// CountForRekey возвращает количество секретов, которые нужно перевести на активный ключ
+func (r *SecretRepository) CountForRekey(ctx context.Context, activeKeyID string) (int64, error) {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `SELECT COUNT(*) FROM secrets WHERE (key_id <> $1 OR wrapped_key IS NULL) AND is_accessed = FALSE`
+
+	var count int64
+	if err := r.db.QueryRow(ctx, query, activeKeyID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count secrets for rekey: %w", err)
+	}
+
+	return count, nil
+}
+
+// UpdateEncryption сохраняет перешифрованные секреты в одной транзакции.
+// Запись обновляется, только если её ключ не изменился с момента чтения и
+// секрет ещё не прочитан: иначе стёртое содержимое было бы восстановлено.
+// Возвращает количество обновлённых записей.
+func (r *SecretRepository) UpdateEncryption(ctx context.Context, updates []EncryptionUpdate) (int64, error) {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		UPDATE secrets
+		SET encrypted_content = $1, iv = $2, key_id = $3, wrapped_key = NULLIF($4, '')
+		WHERE id = $5 AND key_id = $6 AND COALESCE(wrapped_key, '') = $7 AND is_accessed = FALSE
+	`
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var updated int64
+	for _, update := range updates {
+		result, err := tx.Exec(ctx,
+			query,
+			update.Updated.EncryptedContent,
+			update.Updated.IV,
+			update.Updated.KeyID,
+			update.Updated.WrappedKey,
+			update.Previous.ID,
+			update.Previous.KeyID,
+			update.Previous.WrappedKey,
+		)
+		if err != nil {
+			return 0, fmt.Errorf("failed to update secret encryption: %w", err)
+		}
+		updated += result.RowsAffected()
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return updated, nil
 }
 
 // GetActiveSecretsCount возвращает количество активных секретов
 // Активные секреты - это секреты, которые не были прочитаны и не истекли
-func (r *SecretRepository) GetActiveSecretsCount() (int64, error) {
+func (r *SecretRepository) GetActiveSecretsCount(ctx context.Context) (int64, error) {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	query := `
 		SELECT COUNT(*)
 		FROM secrets
@@ -152,7 +537,7 @@ func (r *SecretRepository) GetActiveSecretsCount() (int64, error) {
 	`
 
 	var count int64
-	err := r.db.QueryRow(query, time.Now()).Scan(&count)
+	err := r.db.QueryRow(ctx, query, time.Now()).Scan(&count)
 	if err != nil {
 		return 0, fmt.Errorf("failed to get active secrets count: %w", err)
 	}
@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/savo4ka/ares-api/internal/database"
+	"github.com/savo4ka/ares-api/internal/migrate"
+	"github.com/savo4ka/ares-api/internal/models"
+	"github.com/savo4ka/ares-api/migrations"
+)
+
+// testDatabaseURLEnv - переменная окружения с адресом PostgreSQL для тестов;
+// без неё тесты PostgreSQL пропускаются
+const testDatabaseURLEnv = "TEST_DATABASE_URL"
+
+// newTestPostgresStore возвращает SecretRepository в отдельной схеме с
+// применёнными миграциями; схема удаляется после теста
+func newTestPostgresStore(t *testing.T, databaseURL string) *SecretRepository {
+	t.Helper()
+	ctx := context.Background()
+
+	admin, err := database.New(databaseURL, database.PoolConfig{})
+	if err != nil {
+		t.Fatalf("database.New: %v", err)
+	}
+	t.Cleanup(admin.Close)
+
+	suffix := make([]byte, 6)
+	rand.Read(suffix)
+	schema := "ares_test_" + hex.EncodeToString(suffix)
+	if _, err := admin.Exec(ctx, "CREATE SCHEMA "+schema); err != nil {
+		t.Fatalf("CREATE SCHEMA: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec(context.Background(), "DROP SCHEMA "+schema+" CASCADE"); err != nil {
+			t.Errorf("DROP SCHEMA: %v", err)
+		}
+	})
+
+	db, err := database.New(withSearchPath(t, databaseURL, schema), database.PoolConfig{})
+	if err != nil {
+		t.Fatalf("database.New: %v", err)
+	}
+	t.Cleanup(db.Close)
+
+	migrator, err := migrate.New(db, migrations.FS)
+	if err != nil {
+		t.Fatalf("migrate.New: %v", err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("migrate Up: %v", err)
+	}
+
+	return NewSecretRepository(db, 5*time.Second)
+}
+
+// withSearchPath добавляет search_path к адресу в формате URL или key=value
+func withSearchPath(t *testing.T, databaseURL, schema string) string {
+	t.Helper()
+	if !strings.Contains(databaseURL, "://") {
+		return databaseURL + " search_path=" + schema
+	}
+
+	u, err := url.Parse(databaseURL)
+	if err != nil {
+		t.Fatalf("invalid %s: %v", testDatabaseURLEnv, err)
+	}
+	query := u.Query()
+	query.Set("search_path", schema)
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+func TestPostgresClaimEnqueuesWebhook(t *testing.T) {
+	databaseURL := os.Getenv(testDatabaseURLEnv)
+	if databaseURL == "" {
+		t.Skipf("%s is not set", testDatabaseURLEnv)
+	}
+	store := newTestPostgresStore(t, databaseURL)
+	ctx := context.Background()
+
+	secret := newTestSecret("secret1", 1)
+	secret.NotifyWebhookURL = "https://example.com/hook"
+	if err := store.Create(ctx, secret); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := store.Claim(ctx, "secret1", models.ReaderInfo{IP: "192.0.2.1"}); err != nil {
+		t.Fatalf("Claim: %v", err)
+	}
+
+	var events int
+	err := store.db.QueryRow(ctx, `SELECT COUNT(*) FROM webhook_outbox WHERE secret_id = $1 AND event = $2`, "secret1", models.WebhookEventRead).Scan(&events)
+	if err != nil {
+		t.Fatalf("count outbox: %v", err)
+	}
+	if events != 1 {
+		t.Errorf("%d %s event(s) in the outbox, want 1", events, models.WebhookEventRead)
+	}
+}
+
+func TestPostgresBurnEnqueuesWebhook(t *testing.T) {
+	databaseURL := os.Getenv(testDatabaseURLEnv)
+	if databaseURL == "" {
+		t.Skipf("%s is not set", testDatabaseURLEnv)
+	}
+	store := newTestPostgresStore(t, databaseURL)
+	ctx := context.Background()
+
+	secret := newTestSecret("secret1", 1)
+	secret.PassphraseKDF = "argon2id$salt"
+	secret.NotifyWebhookURL = "https://example.com/hook"
+	if err := store.Create(ctx, secret); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, burned, err := store.RegisterFailedAttempt(ctx, "secret1", 1); err != nil || !burned {
+		t.Fatalf("RegisterFailedAttempt: burned = %t, %v", burned, err)
+	}
+
+	var events int
+	err := store.db.QueryRow(ctx, `SELECT COUNT(*) FROM webhook_outbox WHERE secret_id = $1 AND event = $2`, "secret1", models.WebhookEventBurned).Scan(&events)
+	if err != nil {
+		t.Fatalf("count outbox: %v", err)
+	}
+	if events != 1 {
+		t.Errorf("%d %s event(s) in the outbox, want 1", events, models.WebhookEventBurned)
+	}
+}
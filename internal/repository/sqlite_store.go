@@ -0,0 +1,585 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/savo4ka/ares-api/internal/models"
+
+	_ "modernc.org/sqlite" // SQLite driver
+)
+
+// sqliteSchema - схема встроенной БД. Повторяет таблицу secrets в PostgreSQL;
+// время хранится в наносекундах Unix, чтобы сравнения выполнялись по числам.
+const sqliteSchema = `
+	CREATE TABLE IF NOT EXISTS secrets (
+		id TEXT PRIMARY KEY,
+		encrypted_content TEXT NOT NULL,
+		iv TEXT NOT NULL,
+		key_id TEXT NOT NULL,
+		wrapped_key TEXT,
+		encryption_mode TEXT NOT NULL,
+		passphrase_kdf TEXT,
+		failed_attempts INTEGER NOT NULL DEFAULT 0,
+		management_token_hash TEXT,
+		notify_webhook_url TEXT,
+		notify_email TEXT,
+		max_views INTEGER NOT NULL,
+		views_remaining INTEGER NOT NULL,
+		available_at INTEGER,
+		expires_at INTEGER NOT NULL,
+		created_at INTEGER NOT NULL,
+		accessed_at INTEGER,
+		is_accessed INTEGER NOT NULL DEFAULT 0,
+		reader_ip TEXT,
+		reader_user_agent TEXT,
+		file_name TEXT,
+		file_mime TEXT,
+		file_size INTEGER NOT NULL DEFAULT 0,
+		blob_key TEXT
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_secrets_expires_at ON secrets(expires_at);
+	CREATE INDEX IF NOT EXISTS idx_secrets_blob_key ON secrets(blob_key);
+`
+
+// SQLiteStore хранит секреты во встроенной БД SQLite. Подходит для одного
+// экземпляра сервиса без отдельного сервера БД. Уведомления (webhooks) не поддерживаются.
+type SQLiteStore struct {
+	db           *sql.DB
+	queryTimeout time.Duration
+}
+
+// NewSQLiteStore открывает (или создаёт) БД SQLite по пути path.
+// queryTimeout ограничивает время одного запроса (0 - без ограничения).
+func NewSQLiteStore(path string, queryTimeout time.Duration) (*SQLiteStore, error) {
+	if path == "" {
+		return nil, fmt.Errorf("sqlite path is required")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create sqlite directory: %w", err)
+	}
+
+	db, err := sql.Open("sqlite", sqliteDSN(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+	}
+
+	// SQLite допускает одного писателя: одно подключение исключает ошибки SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create sqlite schema: %w", err)
+	}
+
+	return &SQLiteStore{db: db, queryTimeout: queryTimeout}, nil
+}
+
+// sqliteDSN формирует URI БД. SQLite разбирает путь как URI: символы '?', '#'
+// и '%' в имени файла экранируются.
+func sqliteDSN(path string) string {
+	dsn := url.URL{
+		Scheme: "file",
+		// Clean убирает ведущие "//", иначе начало пути было бы разобрано как хост
+		Opaque: (&url.URL{Path: filepath.Clean(path)}).EscapedPath(),
+		RawQuery: url.Values{
+			"_pragma": {"busy_timeout(5000)", "journal_mode(WAL)", "synchronous(NORMAL)"},
+		}.Encode(),
+	}
+	return dsn.String()
+}
+
+// Close закрывает БД
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// Create создаёт новый секрет
+func (s *SQLiteStore) Create(ctx context.Context, secret *models.Secret) error {
+	ctx, cancel := withTimeout(ctx, s.queryTimeout)
+	defer cancel()
+
+	query := `
+		INSERT INTO secrets (id, encrypted_content, iv, key_id, wrapped_key, encryption_mode, passphrase_kdf,
+			management_token_hash, notify_webhook_url, notify_email, max_views, views_remaining, available_at,
+			expires_at, created_at, is_accessed, file_name, file_mime, file_size, blob_key)
+		VALUES (?1, ?2, ?3, ?4, NULLIF(?5, ''), ?6, NULLIF(?7, ''), NULLIF(?8, ''), NULLIF(?9, ''), NULLIF(?10, ''),
+			?11, ?12, ?13, ?14, ?15, ?16, NULLIF(?17, ''), NULLIF(?18, ''), ?19, NULLIF(?20, ''))
+	`
+
+	_, err := s.db.ExecContext(ctx,
+		query,
+		secret.ID,
+		secret.EncryptedContent,
+		secret.IV,
+		secret.KeyID,
+		secret.WrappedKey,
+		secret.EncryptionMode,
+		secret.PassphraseKDF,
+		secret.ManagementTokenHash,
+		secret.NotifyWebhookURL,
+		secret.NotifyEmail,
+		secret.MaxViews,
+		secret.ViewsRemaining,
+		toNullUnix(secret.AvailableAt),
+		secret.ExpiresAt.UnixNano(),
+		secret.CreatedAt.UnixNano(),
+		secret.IsAccessed,
+		secret.FileName,
+		secret.FileMIME,
+		secret.FileSize,
+		secret.BlobKey,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to create secret: %w", err)
+	}
+
+	return nil
+}
+
+// GetByID получает секрет по ID
+func (s *SQLiteStore) GetByID(ctx context.Context, id string) (*models.Secret, error) {
+	ctx, cancel := withTimeout(ctx, s.queryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT id, encrypted_content, iv, key_id, COALESCE(wrapped_key, ''), encryption_mode,
+			COALESCE(passphrase_kdf, ''), failed_attempts, max_views, views_remaining,
+			available_at, expires_at, created_at, accessed_at, is_accessed,
+			COALESCE(file_name, ''), COALESCE(file_mime, ''), file_size, COALESCE(blob_key, '')
+		FROM secrets
+		WHERE id = ?1
+	`
+
+	secret := &models.Secret{}
+	var availableAt, accessedAt sql.NullInt64
+	var expiresAt, createdAt int64
+	err := s.db.QueryRowContext(ctx, query, id).Scan(
+		&secret.ID,
+		&secret.EncryptedContent,
+		&secret.IV,
+		&secret.KeyID,
+		&secret.WrappedKey,
+		&secret.EncryptionMode,
+		&secret.PassphraseKDF,
+		&secret.FailedAttempts,
+		&secret.MaxViews,
+		&secret.ViewsRemaining,
+		&availableAt,
+		&expiresAt,
+		&createdAt,
+		&accessedAt,
+		&secret.IsAccessed,
+		&secret.FileName,
+		&secret.FileMIME,
+		&secret.FileSize,
+		&secret.BlobKey,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrSecretNotFound
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get secret: %w", err)
+	}
+
+	secret.AvailableAt = fromNullUnix(availableAt)
+	secret.ExpiresAt = time.Unix(0, expiresAt)
+	secret.CreatedAt = time.Unix(0, createdAt)
+	secret.AccessedAt = fromNullUnix(accessedAt)
+
+	return secret, nil
+}
+
+// GetMetadata получает метаданные секрета по ID, не читая шифртекст
+func (s *SQLiteStore) GetMetadata(ctx context.Context, id string) (*models.Secret, error) {
+	ctx, cancel := withTimeout(ctx, s.queryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT id, encryption_mode, COALESCE(passphrase_kdf, ''), failed_attempts, COALESCE(management_token_hash, ''),
+			max_views, views_remaining, available_at, expires_at, created_at, accessed_at, is_accessed,
+			COALESCE(reader_ip, ''), COALESCE(reader_user_agent, ''),
+			COALESCE(file_name, ''), COALESCE(file_mime, ''), file_size, COALESCE(blob_key, '')
+		FROM secrets
+		WHERE id = ?1
+	`
+
+	secret := &models.Secret{}
+	var availableAt, accessedAt sql.NullInt64
+	var expiresAt, createdAt int64
+	err := s.db.QueryRowContext(ctx, query, id).Scan(
+		&secret.ID,
+		&secret.EncryptionMode,
+		&secret.PassphraseKDF,
+		&secret.FailedAttempts,
+		&secret.ManagementTokenHash,
+		&secret.MaxViews,
+		&secret.ViewsRemaining,
+		&availableAt,
+		&expiresAt,
+		&createdAt,
+		&accessedAt,
+		&secret.IsAccessed,
+		&secret.ReaderIP,
+		&secret.ReaderUserAgent,
+		&secret.FileName,
+		&secret.FileMIME,
+		&secret.FileSize,
+		&secret.BlobKey,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrSecretNotFound
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get secret metadata: %w", err)
+	}
+
+	secret.AvailableAt = fromNullUnix(availableAt)
+	secret.ExpiresAt = time.Unix(0, expiresAt)
+	secret.CreatedAt = time.Unix(0, createdAt)
+	secret.AccessedAt = fromNullUnix(accessedAt)
+
+	return secret, nil
+}
+
+// Claim атомарно засчитывает просмотр секрета (см. SecretRepository.Claim)
+func (s *SQLiteStore) Claim(ctx context.Context, id string, reader models.ReaderInfo) (*models.Secret, error) {
+	ctx, cancel := withTimeout(ctx, s.queryTimeout)
+	defer cancel()
+
+	query := `
+		UPDATE secrets
+		SET views_remaining = views_remaining - 1,
+			reader_ip = CASE WHEN accessed_at IS NULL THEN NULLIF(?3, '') ELSE reader_ip END,
+			reader_user_agent = CASE WHEN accessed_at IS NULL THEN NULLIF(?4, '') ELSE reader_user_agent END,
+			accessed_at = COALESCE(accessed_at, ?2),
+			is_accessed = views_remaining <= 1,
+			encrypted_content = CASE WHEN views_remaining <= 1 THEN '' ELSE encrypted_content END,
+			iv = CASE WHEN views_remaining <= 1 THEN '' ELSE iv END,
+			wrapped_key = CASE WHEN views_remaining <= 1 THEN NULL ELSE wrapped_key END,
+			passphrase_kdf = CASE WHEN views_remaining <= 1 THEN NULL ELSE passphrase_kdf END
+		WHERE id = ?1 AND is_accessed = 0 AND views_remaining > 0 AND expires_at > ?2
+			AND (available_at IS NULL OR available_at <= ?2)
+		RETURNING id, max_views, views_remaining, expires_at, created_at, accessed_at, is_accessed,
+			COALESCE(notify_email, '')
+	`
+
+	secret := &models.Secret{}
+	var expiresAt, createdAt int64
+	var accessedAt sql.NullInt64
+	err := s.db.QueryRowContext(ctx, query, id, time.Now().UnixNano(), reader.IP, reader.UserAgent).Scan(
+		&secret.ID,
+		&secret.MaxViews,
+		&secret.ViewsRemaining,
+		&expiresAt,
+		&createdAt,
+		&accessedAt,
+		&secret.IsAccessed,
+		&secret.NotifyEmail,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrSecretUnavailable
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim secret: %w", err)
+	}
+
+	secret.ExpiresAt = time.Unix(0, expiresAt)
+	secret.CreatedAt = time.Unix(0, createdAt)
+	secret.AccessedAt = fromNullUnix(accessedAt)
+
+	return secret, nil
+}
+
+// UpdateExpiry изменяет срок действия секрета (см. SecretRepository.UpdateExpiry)
+func (s *SQLiteStore) UpdateExpiry(ctx context.Context, id string, expiresAt time.Time) error {
+	ctx, cancel := withTimeout(ctx, s.queryTimeout)
+	defer cancel()
+
+	query := `
+		UPDATE secrets
+		SET expires_at = ?2
+		WHERE id = ?1 AND is_accessed = 0 AND expires_at > ?3
+			AND (available_at IS NULL OR available_at < ?2)
+	`
+
+	result, err := s.db.ExecContext(ctx, query, id, expiresAt.UnixNano(), time.Now().UnixNano())
+	if err != nil {
+		return fmt.Errorf("failed to update secret expiry: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return ErrSecretUnavailable
+	}
+
+	return nil
+}
+
+// RegisterFailedAttempt учитывает неверную парольную фразу (см. SecretRepository.RegisterFailedAttempt)
+func (s *SQLiteStore) RegisterFailedAttempt(ctx context.Context, id string, maxAttempts int) (int, bool, error) {
+	ctx, cancel := withTimeout(ctx, s.queryTimeout)
+	defer cancel()
+
+	query := `
+		UPDATE secrets
+		SET failed_attempts = failed_attempts + 1,
+			is_accessed = failed_attempts + 1 >= ?2,
+			views_remaining = CASE WHEN failed_attempts + 1 >= ?2 THEN 0 ELSE views_remaining END,
+			encrypted_content = CASE WHEN failed_attempts + 1 >= ?2 THEN '' ELSE encrypted_content END,
+			iv = CASE WHEN failed_attempts + 1 >= ?2 THEN '' ELSE iv END,
+			wrapped_key = CASE WHEN failed_attempts + 1 >= ?2 THEN NULL ELSE wrapped_key END,
+			passphrase_kdf = CASE WHEN failed_attempts + 1 >= ?2 THEN NULL ELSE passphrase_kdf END
+		WHERE id = ?1 AND is_accessed = 0
+		RETURNING failed_attempts, is_accessed
+	`
+
+	var attempts int
+	var burned bool
+	err := s.db.QueryRowContext(ctx, query, id, maxAttempts).Scan(&attempts, &burned)
+	if err == sql.ErrNoRows {
+		return 0, false, ErrSecretUnavailable
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to register failed attempt: %w", err)
+	}
+
+	return attempts, burned, nil
+}
+
+// CleanupExpired удаляет истёкшие секреты и возвращает их количество и ключи блобов
+func (s *SQLiteStore) CleanupExpired(ctx context.Context) (int64, []string, error) {
+	ctx, cancel := withTimeout(ctx, s.queryTimeout)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `DELETE FROM secrets WHERE expires_at < ?1 RETURNING COALESCE(blob_key, '')`, time.Now().UnixNano())
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to cleanup expired secrets: %w", err)
+	}
+	defer rows.Close()
+
+	var deleted int64
+	var blobKeys []string
+	for rows.Next() {
+		var blobKey string
+		if err := rows.Scan(&blobKey); err != nil {
+			return 0, nil, fmt.Errorf("failed to scan expired secret: %w", err)
+		}
+		deleted++
+		if blobKey != "" {
+			blobKeys = append(blobKeys, blobKey)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return 0, nil, fmt.Errorf("failed to cleanup expired secrets: %w", err)
+	}
+
+	return deleted, blobKeys, nil
+}
+
+// ReferencedBlobKeys возвращает те из keys, на которые ссылаются секреты
+func (s *SQLiteStore) ReferencedBlobKeys(ctx context.Context, keys []string) (map[string]bool, error) {
+	ctx, cancel := withTimeout(ctx, s.queryTimeout)
+	defer cancel()
+
+	referenced := make(map[string]bool)
+	if len(keys) == 0 {
+		return referenced, nil
+	}
+
+	args := make([]any, len(keys))
+	for i, key := range keys {
+		args[i] = key
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(keys)), ",")
+
+	rows, err := s.db.QueryContext(ctx, `SELECT blob_key FROM secrets WHERE blob_key IN (`+placeholders+`)`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check blob references: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, fmt.Errorf("failed to scan blob key: %w", err)
+		}
+		referenced[key] = true
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to check blob references: %w", err)
+	}
+
+	return referenced, nil
+}
+
+// Revoke удаляет секрет по запросу создателя
+func (s *SQLiteStore) Revoke(ctx context.Context, id string) error {
+	ctx, cancel := withTimeout(ctx, s.queryTimeout)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, `DELETE FROM secrets WHERE id = ?1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to revoke secret: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return ErrSecretNotFound
+	}
+
+	return nil
+}
+
+// ListForRekey возвращает следующую порцию секретов для перешифрования (см. SecretRepository.ListForRekey)
+func (s *SQLiteStore) ListForRekey(ctx context.Context, activeKeyID, afterID string, limit int) ([]*models.Secret, error) {
+	ctx, cancel := withTimeout(ctx, s.queryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT id, encrypted_content, iv, key_id, COALESCE(wrapped_key, '')
+		FROM secrets
+		WHERE (key_id <> ?1 OR wrapped_key IS NULL) AND is_accessed = 0 AND id > ?2
+		ORDER BY id
+		LIMIT ?3
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, activeKeyID, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets for rekey: %w", err)
+	}
+	defer rows.Close()
+
+	var secrets []*models.Secret
+	for rows.Next() {
+		secret := &models.Secret{}
+		if err := rows.Scan(&secret.ID, &secret.EncryptedContent, &secret.IV, &secret.KeyID, &secret.WrappedKey); err != nil {
+			return nil, fmt.Errorf("failed to scan secret: %w", err)
+		}
+		secrets = append(secrets, secret)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list secrets for rekey: %w", err)
+	}
+
+	return secrets, nil
+}
+
+// CountForRekey возвращает количество секретов, которые нужно перевести на активный ключ
+func (s *SQLiteStore) CountForRekey(ctx context.Context, activeKeyID string) (int64, error) {
+	ctx, cancel := withTimeout(ctx, s.queryTimeout)
+	defer cancel()
+
+	query := `SELECT COUNT(*) FROM secrets WHERE (key_id <> ?1 OR wrapped_key IS NULL) AND is_accessed = 0`
+
+	var count int64
+	if err := s.db.QueryRowContext(ctx, query, activeKeyID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count secrets for rekey: %w", err)
+	}
+
+	return count, nil
+}
+
+// UpdateEncryption сохраняет перешифрованные секреты в одной транзакции (см. SecretRepository.UpdateEncryption)
+func (s *SQLiteStore) UpdateEncryption(ctx context.Context, updates []EncryptionUpdate) (int64, error) {
+	ctx, cancel := withTimeout(ctx, s.queryTimeout)
+	defer cancel()
+
+	query := `
+		UPDATE secrets
+		SET encrypted_content = ?1, iv = ?2, key_id = ?3, wrapped_key = NULLIF(?4, '')
+		WHERE id = ?5 AND key_id = ?6 AND COALESCE(wrapped_key, '') = ?7 AND is_accessed = 0
+	`
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var updated int64
+	for _, update := range updates {
+		result, err := tx.ExecContext(ctx,
+			query,
+			update.Updated.EncryptedContent,
+			update.Updated.IV,
+			update.Updated.KeyID,
+			update.Updated.WrappedKey,
+			update.Previous.ID,
+			update.Previous.KeyID,
+			update.Previous.WrappedKey,
+		)
+		if err != nil {
+			return 0, fmt.Errorf("failed to update secret encryption: %w", err)
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return 0, fmt.Errorf("failed to get rows affected: %w", err)
+		}
+		updated += rows
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return updated, nil
+}
+
+// GetActiveSecretsCount возвращает количество непрочитанных неистёкших секретов
+func (s *SQLiteStore) GetActiveSecretsCount(ctx context.Context) (int64, error) {
+	ctx, cancel := withTimeout(ctx, s.queryTimeout)
+	defer cancel()
+
+	var count int64
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM secrets WHERE is_accessed = 0 AND expires_at > ?1`, time.Now().UnixNano()).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get active secrets count: %w", err)
+	}
+
+	return count, nil
+}
+
+// toNullUnix переводит необязательное время в наносекунды Unix
+func toNullUnix(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return t.UnixNano()
+}
+
+// fromNullUnix переводит наносекунды Unix обратно в необязательное время
+func fromNullUnix(v sql.NullInt64) *time.Time {
+	if !v.Valid {
+		return nil
+	}
+	t := time.Unix(0, v.Int64)
+	return &t
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSQLiteStorePathWithURIDelimiters(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data?mode=ro#x")
+	path := filepath.Join(dir, "ares%20db?cache=shared#frag.db")
+
+	store, err := NewSQLiteStore(path, 5*time.Second)
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	if err := store.Create(ctx, newTestSecret("secret1", 1)); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	// БД создана ровно по указанному пути, а не по его части до '?' или '#'
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	found := false
+	for _, entry := range entries {
+		if entry.Name() == filepath.Base(path) {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("database file %q not created, directory contains %v", filepath.Base(path), entries)
+	}
+
+	// Параметры из DSN применяются
+	var journalMode string
+	if err := store.db.QueryRowContext(ctx, "PRAGMA journal_mode").Scan(&journalMode); err != nil {
+		t.Fatalf("PRAGMA journal_mode: %v", err)
+	}
+	if journalMode != "wal" {
+		t.Errorf("journal_mode = %q, want wal", journalMode)
+	}
+
+	store.Close()
+	reopened, err := NewSQLiteStore(path, 5*time.Second)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer reopened.Close()
+	if _, err := reopened.GetMetadata(ctx, "secret1"); err != nil {
+		t.Errorf("GetMetadata after reopen: %v", err)
+	}
+}
+
+func TestSQLiteDSN(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"data/ares.db", "file:data/ares.db?"},
+		{"/var/lib/ares/ares.db", "file:/var/lib/ares/ares.db?"},
+		{"//srv/ares.db", "file:/srv/ares.db?"},
+		{"/tmp/a?b#c%d.db", "file:/tmp/a%3Fb%23c%25d.db?"},
+	}
+
+	for _, tt := range tests {
+		got := sqliteDSN(tt.path)
+		if !strings.HasPrefix(got, tt.want) {
+			t.Errorf("sqliteDSN(%q) = %q, want prefix %q", tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/savo4ka/ares-api/internal/models"
+)
+
+// SecretStore хранит секреты. Реализации: SecretRepository (PostgreSQL),
+// SQLiteStore (встроенная SQLite), RedisStore (Redis) и MemoryStore (в памяти процесса).
+// Все реализации обязаны выполнять Claim атомарно: из параллельных запросов
+// секрет получат не больше, чем осталось просмотров.
+type SecretStore interface {
+	// Create сохраняет новый секрет
+	Create(ctx context.Context, secret *models.Secret) error
+
+	// GetByID возвращает секрет вместе с шифртекстом
+	GetByID(ctx context.Context, id string) (*models.Secret, error)
+
+	// GetMetadata возвращает секрет без шифртекста
+	GetMetadata(ctx context.Context, id string) (*models.Secret, error)
+
+	// Claim засчитывает просмотр и уничтожает шифртекст на последнем просмотре
+	Claim(ctx context.Context, id string, reader models.ReaderInfo) (*models.Secret, error)
+
+	// UpdateExpiry меняет время истечения непрочитанного секрета
+	UpdateExpiry(ctx context.Context, id string, expiresAt time.Time) error
+
+	// RegisterFailedAttempt учитывает неверную парольную фразу и после maxAttempts
+	// попыток сжигает секрет: стирает шифртекст и помечает секрет прочитанным
+	RegisterFailedAttempt(ctx context.Context, id string, maxAttempts int) (int, bool, error)
+
+	// Revoke уничтожает секрет по запросу создателя
+	Revoke(ctx context.Context, id string) error
+
+	// CleanupExpired удаляет истёкшие секреты и возвращает их количество и ключи блобов
+	CleanupExpired(ctx context.Context) (int64, []string, error)
+
+	// ReferencedBlobKeys возвращает те из keys, на которые ссылаются секреты
+	ReferencedBlobKeys(ctx context.Context, keys []string) (map[string]bool, error)
+
+	// GetActiveSecretsCount возвращает количество непрочитанных неистёкших секретов
+	GetActiveSecretsCount(ctx context.Context) (int64, error)
+
+	// ListForRekey, CountForRekey и UpdateEncryption используются командой rekey
+	ListForRekey(ctx context.Context, activeKeyID, afterID string, limit int) ([]*models.Secret, error)
+	CountForRekey(ctx context.Context, activeKeyID string) (int64, error)
+	UpdateEncryption(ctx context.Context, updates []EncryptionUpdate) (int64, error)
+}
+
+// withTimeout ограничивает время запроса к хранилищу; timeout 0 - без ограничения
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+var (
+	_ SecretStore = (*SecretRepository)(nil)
+	_ SecretStore = (*SQLiteStore)(nil)
+	_ SecretStore = (*RedisStore)(nil)
+	_ SecretStore = (*MemoryStore)(nil)
+)
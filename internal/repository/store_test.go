@@ -0,0 +1,263 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/savo4ka/ares-api/internal/models"
+)
+
+// testStores возвращает хранилища, которые можно проверить без внешних сервисов
+// (Redis заменяет miniredis). PostgreSQL проверяется, если задан TEST_DATABASE_URL.
+func testStores(t *testing.T) map[string]SecretStore {
+	t.Helper()
+
+	sqlite, err := NewSQLiteStore(filepath.Join(t.TempDir(), "ares.db"), 5*time.Second)
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	t.Cleanup(func() { sqlite.Close() })
+
+	redis, _ := newTestRedisStore(t)
+
+	stores := map[string]SecretStore{
+		"memory": NewMemoryStore(),
+		"sqlite": sqlite,
+		"redis":  redis,
+	}
+	if databaseURL := os.Getenv(testDatabaseURLEnv); databaseURL != "" {
+		stores["postgres"] = newTestPostgresStore(t, databaseURL)
+	}
+	return stores
+}
+
+func newTestSecret(id string, views int) *models.Secret {
+	now := time.Now()
+	return &models.Secret{
+		ID:               id,
+		EncryptedContent: "v3:Y2lwaGVydGV4dA==",
+		IV:               "bm9uY2U=",
+		KeyID:            "k1",
+		WrappedKey:       "aad:d3JhcHBlZA==",
+		EncryptionMode:   models.EncryptionModeServer,
+		MaxViews:         views,
+		ViewsRemaining:   views,
+		ExpiresAt:        now.Add(time.Hour),
+		CreatedAt:        now,
+	}
+}
+
+// claimConcurrently засчитывает просмотр из readers горутин одновременно и
+// возвращает количество успешных просмотров
+func claimConcurrently(t *testing.T, store SecretStore, id string, readers int) int {
+	t.Helper()
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		successes int
+		start     = make(chan struct{})
+	)
+
+	for i := range readers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+
+			_, err := store.Claim(context.Background(), id, models.ReaderInfo{IP: fmt.Sprintf("10.0.0.%d", i)})
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				successes++
+			case !errors.Is(err, ErrSecretUnavailable):
+				t.Errorf("Claim: unexpected error %v", err)
+			}
+		}()
+	}
+
+	close(start)
+	wg.Wait()
+	return successes
+}
+
+func TestClaimSingleReaderWins(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			if err := store.Create(ctx, newTestSecret("single", 1)); err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+
+			if successes := claimConcurrently(t, store, "single", 20); successes != 1 {
+				t.Fatalf("%d readers claimed the secret, want exactly 1", successes)
+			}
+
+			secret, err := store.GetByID(ctx, "single")
+			if err != nil {
+				t.Fatalf("GetByID: %v", err)
+			}
+			if !secret.IsAccessed || secret.ViewsRemaining != 0 {
+				t.Errorf("is_accessed = %v, views_remaining = %d, want true, 0", secret.IsAccessed, secret.ViewsRemaining)
+			}
+			if secret.EncryptedContent != "" || secret.IV != "" || secret.WrappedKey != "" {
+				t.Errorf("ciphertext was not wiped after the last view")
+			}
+		})
+	}
+}
+
+func TestClaimMultipleViews(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			if err := store.Create(ctx, newTestSecret("multi", 3)); err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+
+			if successes := claimConcurrently(t, store, "multi", 10); successes != 3 {
+				t.Fatalf("%d readers claimed the secret, want 3", successes)
+			}
+
+			secret, err := store.GetMetadata(ctx, "multi")
+			if err != nil {
+				t.Fatalf("GetMetadata: %v", err)
+			}
+			if !secret.IsAccessed || secret.AccessedAt == nil || secret.ReaderIP == "" {
+				t.Errorf("secret state after all views: is_accessed = %v, accessed_at = %v, reader_ip = %q",
+					secret.IsAccessed, secret.AccessedAt, secret.ReaderIP)
+			}
+		})
+	}
+}
+
+func TestClaimUnavailableSecret(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			expired := newTestSecret("expired", 1)
+			expired.ExpiresAt = time.Now().Add(-time.Minute)
+			pending := newTestSecret("pending", 1)
+			availableAt := time.Now().Add(time.Hour)
+			pending.AvailableAt = &availableAt
+			pending.ExpiresAt = availableAt.Add(time.Hour)
+
+			for _, secret := range []*models.Secret{expired, pending} {
+				if err := store.Create(ctx, secret); err != nil {
+					t.Fatalf("Create: %v", err)
+				}
+			}
+
+			for _, id := range []string{"expired", "pending", "missing"} {
+				if _, err := store.Claim(ctx, id, models.ReaderInfo{}); !errors.Is(err, ErrSecretUnavailable) {
+					t.Errorf("Claim(%q): err = %v, want ErrSecretUnavailable", id, err)
+				}
+			}
+		})
+	}
+}
+
+func TestUpdateEncryptionSkipsClaimedSecret(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			// Секрет зашифрован мастер-ключом напрямую: после прочтения его key_id
+			// и пустой wrapped_key не меняются
+			secret := newTestSecret("rekeyed", 1)
+			secret.EncryptedContent = "v2:Y2lwaGVydGV4dA=="
+			secret.WrappedKey = ""
+			if err := store.Create(ctx, secret); err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+
+			batch, err := store.ListForRekey(ctx, "k1", "", 10)
+			if err != nil || len(batch) != 1 {
+				t.Fatalf("ListForRekey = %d secret(s), %v, want 1", len(batch), err)
+			}
+
+			// Секрет прочитан между выборкой и сохранением перешифрованной версии
+			if _, err := store.Claim(ctx, "rekeyed", models.ReaderInfo{IP: "192.0.2.1"}); err != nil {
+				t.Fatalf("Claim: %v", err)
+			}
+
+			updated, err := store.UpdateEncryption(ctx, []EncryptionUpdate{{
+				Previous: batch[0],
+				Updated: &models.Secret{
+					ID:               "rekeyed",
+					EncryptedContent: "v3:bmV3",
+					IV:               "bm9uY2U=",
+					KeyID:            "k1",
+					WrappedKey:       "aad:bmV3",
+				},
+			}})
+			if err != nil {
+				t.Fatalf("UpdateEncryption: %v", err)
+			}
+			if updated != 0 {
+				t.Errorf("UpdateEncryption updated %d secret(s), want 0", updated)
+			}
+
+			stored, err := store.GetByID(ctx, "rekeyed")
+			if err != nil {
+				t.Fatalf("GetByID: %v", err)
+			}
+			if stored.EncryptedContent != "" || stored.WrappedKey != "" {
+				t.Errorf("claimed secret restored: content = %q, wrapped_key = %q", stored.EncryptedContent, stored.WrappedKey)
+			}
+		})
+	}
+}
+
+func TestRegisterFailedAttemptBurnsSecret(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			secret := newTestSecret("guarded", 2)
+			secret.PassphraseKDF = "argon2id$salt"
+			if err := store.Create(ctx, secret); err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+
+			for want := 1; want <= 2; want++ {
+				attempts, burned, err := store.RegisterFailedAttempt(ctx, "guarded", 3)
+				if err != nil || attempts != want || burned {
+					t.Fatalf("RegisterFailedAttempt = %d, %t, %v, want %d, false", attempts, burned, err, want)
+				}
+			}
+			if attempts, burned, err := store.RegisterFailedAttempt(ctx, "guarded", 3); err != nil || attempts != 3 || !burned {
+				t.Fatalf("last RegisterFailedAttempt = %d, %t, %v, want 3, true", attempts, burned, err)
+			}
+
+			// Сожжённый секрет остаётся надгробием, как после последнего просмотра
+			burned, err := store.GetByID(ctx, "guarded")
+			if err != nil {
+				t.Fatalf("GetByID: %v", err)
+			}
+			if !burned.IsAccessed || burned.ViewsRemaining != 0 || burned.FailedAttempts != 3 {
+				t.Errorf("is_accessed = %t, views_remaining = %d, failed_attempts = %d",
+					burned.IsAccessed, burned.ViewsRemaining, burned.FailedAttempts)
+			}
+			if burned.EncryptedContent != "" || burned.IV != "" || burned.WrappedKey != "" || burned.PassphraseKDF != "" {
+				t.Errorf("ciphertext was not wiped after the last failed attempt")
+			}
+			if count, err := store.GetActiveSecretsCount(ctx); err != nil || count != 0 {
+				t.Errorf("GetActiveSecretsCount = %d, %v, want 0", count, err)
+			}
+
+			if _, err := store.Claim(ctx, "guarded", models.ReaderInfo{}); !errors.Is(err, ErrSecretUnavailable) {
+				t.Errorf("Claim after burn: err = %v, want ErrSecretUnavailable", err)
+			}
+			if _, _, err := store.RegisterFailedAttempt(ctx, "guarded", 3); !errors.Is(err, ErrSecretUnavailable) {
+				t.Errorf("RegisterFailedAttempt after burn: err = %v, want ErrSecretUnavailable", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/savo4ka/ares-api/internal/database"
+	"github.com/savo4ka/ares-api/internal/models"
+)
+
+// WebhookRepository предоставляет методы для работы с очередью исходящих уведомлений
+type WebhookRepository struct {
+	db           *database.DB
+	queryTimeout time.Duration
+}
+
+// NewWebhookRepository создаёт новый репозиторий уведомлений.
+// queryTimeout ограничивает время одного запроса (0 - без ограничения).
+func NewWebhookRepository(db *database.DB, queryTimeout time.Duration) *WebhookRepository {
+	return &WebhookRepository{
+		db:           db,
+		queryTimeout: queryTimeout,
+	}
+}
+
+// ClaimDue выбирает до limit уведомлений, готовых к отправке, и откладывает их
+// следующую попытку на lease: пока уведомление отправляется, его не возьмёт
+// другой экземпляр сервиса, а если процесс упадёт, оно будет отправлено повторно.
+func (r *WebhookRepository) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]*models.WebhookDelivery, error) {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		UPDATE webhook_outbox
+		SET next_attempt_at = $2
+		WHERE id IN (
+			SELECT id FROM webhook_outbox
+			WHERE failed_at IS NULL AND next_attempt_at <= $1
+			ORDER BY id
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, secret_id, event, url, occurred_at, attempts
+	`
+
+	now := time.Now()
+	rows, err := r.db.Query(ctx, query, now, now.Add(lease), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []*models.WebhookDelivery
+	for rows.Next() {
+		delivery := &models.WebhookDelivery{}
+		if err := rows.Scan(
+			&delivery.ID,
+			&delivery.SecretID,
+			&delivery.Event,
+			&delivery.URL,
+			&delivery.OccurredAt,
+			&delivery.Attempts,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate webhook deliveries: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+// MarkDelivered удаляет доставленное уведомление из очереди
+func (r *WebhookRepository) MarkDelivered(ctx context.Context, id int64) error {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	if _, err := r.db.Exec(ctx, `DELETE FROM webhook_outbox WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("failed to mark webhook delivered: %w", err)
+	}
+	return nil
+}
+
+// MarkRetry учитывает неудачную попытку и назначает следующую на nextAttemptAt
+func (r *WebhookRepository) MarkRetry(ctx context.Context, id int64, nextAttemptAt time.Time, lastError string) error {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		UPDATE webhook_outbox
+		SET attempts = attempts + 1, next_attempt_at = $2, last_error = $3
+		WHERE id = $1
+	`
+
+	if _, err := r.db.Exec(ctx, query, id, nextAttemptAt, lastError); err != nil {
+		return fmt.Errorf("failed to schedule webhook retry: %w", err)
+	}
+	return nil
+}
+
+// MarkFailed помечает уведомление недоставленным после исчерпания попыток.
+// Такие уведомления остаются в таблице для разбора и больше не отправляются,
+// пока их не удалит DeleteFailedBefore.
+func (r *WebhookRepository) MarkFailed(ctx context.Context, id int64, lastError string) error {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		UPDATE webhook_outbox
+		SET attempts = attempts + 1, failed_at = $2, last_error = $3
+		WHERE id = $1
+	`
+
+	if _, err := r.db.Exec(ctx, query, id, time.Now(), lastError); err != nil {
+		return fmt.Errorf("failed to mark webhook failed: %w", err)
+	}
+	return nil
+}
+
+// DeleteFailedBefore удаляет недоставленные уведомления, помеченные до cutoff
+func (r *WebhookRepository) DeleteFailedBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	result, err := r.db.Exec(ctx, `DELETE FROM webhook_outbox WHERE failed_at < $1`, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete failed webhooks: %w", err)
+	}
+	return result.RowsAffected(), nil
+}
+
+// CountPending возвращает количество уведомлений, ожидающих доставки
+func (r *WebhookRepository) CountPending(ctx context.Context) (int64, error) {
+	ctx, cancel := withTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var count int64
+	if err := r.db.QueryRow(ctx, `SELECT COUNT(*) FROM webhook_outbox WHERE failed_at IS NULL`).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count pending webhooks: %w", err)
+	}
+	return count, nil
+}
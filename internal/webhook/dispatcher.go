@@ -0,0 +1,224 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/savo4ka/ares-api/internal/metrics"
+	"github.com/savo4ka/ares-api/internal/models"
+	"github.com/savo4ka/ares-api/internal/repository"
+)
+
+const (
+	pollInterval = 5 * time.Second // Как часто проверять очередь
+	batchSize    = 50              // Сколько уведомлений брать за раз
+	minBackoff   = 30 * time.Second
+	maxBackoff   = 6 * time.Hour
+)
+
+// ErrPrivateAddress возвращается при попытке доставить уведомление на адрес
+// внутренней сети, если это не разрешено (WEBHOOK_ALLOW_PRIVATE)
+var ErrPrivateAddress = errors.New("webhook address is not public")
+
+// Dispatcher отправляет уведомления из очереди webhook_outbox. Неудачные
+// попытки повторяются с экспоненциальной задержкой, после maxAttempts
+// уведомление помечается недоставленным.
+type Dispatcher struct {
+	repo        *repository.WebhookRepository
+	client      *http.Client
+	secret      []byte
+	maxAttempts int
+	timeout     time.Duration
+	metrics     *metrics.Metrics
+}
+
+// NewDispatcher создаёт диспетчер уведомлений. Без allowPrivate соединения
+// с loopback, частными и link-local адресами запрещены: URL уведомления задаёт
+// создатель секрета, и сервис не должен обращаться к внутренней сети от его имени.
+func NewDispatcher(repo *repository.WebhookRepository, secret string, maxAttempts int, timeout time.Duration, allowPrivate bool, m *metrics.Metrics) *Dispatcher {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivate {
+		dialer.Control = rejectPrivateAddress
+	}
+
+	return &Dispatcher{
+		repo: repo,
+		client: &http.Client{
+			Timeout:   timeout,
+			Transport: &http.Transport{DialContext: dialer.DialContext},
+			// Перенаправления не выполняются: иначе проверку адреса можно обойти
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		secret:      []byte(secret),
+		maxAttempts: maxAttempts,
+		timeout:     timeout,
+		metrics:     m,
+	}
+}
+
+// Run отправляет уведомления, пока не будет отменён ctx
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		d.dispatchDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// dispatchDue отправляет все уведомления, срок которых подошёл
+func (d *Dispatcher) dispatchDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		// Пока уведомление отправляется, его следующая попытка отложена на два таймаута
+		deliveries, err := d.repo.ClaimDue(ctx, batchSize, 2*d.timeout)
+		if err != nil {
+			log.Printf("Failed to load webhook deliveries: %v", err)
+			return
+		}
+
+		for _, delivery := range deliveries {
+			d.process(ctx, delivery)
+		}
+
+		if len(deliveries) < batchSize {
+			break
+		}
+	}
+
+	if count, err := d.repo.CountPending(ctx); err == nil {
+		d.metrics.WebhookPendingGauge.Set(float64(count))
+	}
+}
+
+// process отправляет уведомление и обновляет его состояние в очереди
+func (d *Dispatcher) process(ctx context.Context, delivery *models.WebhookDelivery) {
+	err := d.deliver(ctx, delivery)
+
+	// Результат попытки сохраняется и при остановке сервиса, иначе уведомление уйдёт повторно
+	stateCtx := context.WithoutCancel(ctx)
+	if err == nil {
+		d.metrics.WebhookDeliveriesTotal.WithLabelValues(delivery.Event, "delivered").Inc()
+		if err := d.repo.MarkDelivered(stateCtx, delivery.ID); err != nil {
+			log.Printf("Webhook %d: %v", delivery.ID, err)
+		}
+		return
+	}
+
+	attempts := delivery.Attempts + 1
+	if attempts >= d.maxAttempts {
+		d.metrics.WebhookDeliveriesTotal.WithLabelValues(delivery.Event, "failed").Inc()
+		log.Printf("Webhook %d (%s for secret %s) failed after %d attempts: %v",
+			delivery.ID, delivery.Event, delivery.SecretID, attempts, err)
+		if err := d.repo.MarkFailed(stateCtx, delivery.ID, err.Error()); err != nil {
+			log.Printf("Webhook %d: %v", delivery.ID, err)
+		}
+		return
+	}
+
+	d.metrics.WebhookDeliveriesTotal.WithLabelValues(delivery.Event, "retry").Inc()
+	if err := d.repo.MarkRetry(stateCtx, delivery.ID, time.Now().Add(backoff(attempts)), err.Error()); err != nil {
+		log.Printf("Webhook %d: %v", delivery.ID, err)
+	}
+}
+
+// deliver отправляет подписанное уведомление. Успехом считается любой ответ 2xx.
+func (d *Dispatcher) deliver(ctx context.Context, delivery *models.WebhookDelivery) error {
+	body, err := json.Marshal(models.WebhookPayload{
+		DeliveryID: delivery.ID,
+		Event:      delivery.Event,
+		SecretID:   delivery.SecretID,
+		OccurredAt: delivery.OccurredAt,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "ares-api-webhook")
+	req.Header.Set("X-Ares-Event", delivery.Event)
+	req.Header.Set("X-Ares-Delivery", strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set("X-Ares-Timestamp", timestamp)
+	req.Header.Set("X-Ares-Signature", "sha256="+Sign(d.secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// Sign вычисляет подпись уведомления: hex(HMAC-SHA256(secret, timestamp + "." + body)).
+// Получатель должен вычислить её сам, сравнить с X-Ares-Signature и проверить,
+// что X-Ares-Timestamp не слишком старый.
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// backoff возвращает задержку перед следующей попыткой: 30s, 1m, 2m, ... не больше 6h
+func backoff(attempts int) time.Duration {
+	delay := minBackoff
+	for i := 1; i < attempts && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxBackoff)
+}
+
+// rejectPrivateAddress запрещает соединения с адресами внутренней сети.
+// Проверяется адрес, к которому реально подключается клиент, поэтому
+// подмена DNS-ответа не помогает обойти проверку.
+func rejectPrivateAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() ||
+		sharedAddressSpace.Contains(ip) {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, host)
+	}
+
+	return nil
+}
+
+// sharedAddressSpace - диапазон CGNAT (RFC 6598)
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
@@ -0,0 +1,97 @@
+package webhook
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"event":"secret.read"}`)
+
+	// Ожидаемые значения - hex(HMAC-SHA256(secret, timestamp + "." + body))
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		body      []byte
+		want      string
+	}{
+		{"payload", "whsec", "1700000000", body, "03d8f17e8bacca402567d845370723384013b96e2b5cc660c6b515fda97f79da"},
+		{"other timestamp", "whsec", "1700000001", body, "8a37edc1c96d23483135ba919f87184863899ec17476880a66e607e6d18939e3"},
+		{"other secret", "other", "1700000000", body, "317ca0f9ff6c026f68f472117ffe48aeaa63a76297ccaa28c818fde862cacaa8"},
+		{"empty body", "whsec", "1700000000", nil, "ab5fdf6f7cdf5f7abf2f4d61c6b0376dc6bf75beafc17135e5fd06513ee7afd8"},
+	}
+
+	for _, tt := range tests {
+		if got := Sign([]byte(tt.secret), tt.timestamp, tt.body); got != tt.want {
+			t.Errorf("%s: Sign = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRejectPrivateAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		private bool
+	}{
+		{"93.184.216.34:443", false},
+		{"[2606:2800:220:1:248:1893:25c8:1946]:443", false},
+		{"127.0.0.1:80", true},
+		{"[::1]:80", true},
+		{"10.1.2.3:80", true},
+		{"172.16.0.1:80", true},
+		{"192.168.1.1:80", true},
+		{"[fd00::1]:80", true},
+		{"169.254.169.254:80", true},
+		{"[fe80::1]:80", true},
+		{"100.64.0.1:80", true},
+		{"0.0.0.0:80", true},
+		{"224.0.0.1:80", true},
+		{"[::ffff:127.0.0.1]:80", true},
+		{"[::ffff:10.0.0.1]:80", true},
+		{"[::ffff:169.254.169.254]:80", true},
+		{"[::ffff:93.184.216.34]:443", false},
+	}
+
+	for _, tt := range tests {
+		err := rejectPrivateAddress("tcp", tt.address, nil)
+		if tt.private && !errors.Is(err, ErrPrivateAddress) {
+			t.Errorf("rejectPrivateAddress(%s) = %v, want ErrPrivateAddress", tt.address, err)
+		}
+		if !tt.private && err != nil {
+			t.Errorf("rejectPrivateAddress(%s) = %v, want nil", tt.address, err)
+		}
+	}
+
+	if err := rejectPrivateAddress("tcp", "93.184.216.34", nil); err == nil {
+		t.Errorf("rejectPrivateAddress accepted an address without a port")
+	}
+	// Проверяется только уже разрешённый IP-адрес
+	if err := rejectPrivateAddress("tcp", "example.com:443", nil); !errors.Is(err, ErrPrivateAddress) {
+		t.Errorf("rejectPrivateAddress(example.com:443) = %v, want ErrPrivateAddress", err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, 30 * time.Second},
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{4, 4 * time.Minute},
+		{10, 256 * time.Minute},
+		{11, 6 * time.Hour},
+		{12, 6 * time.Hour},
+		{1000, 6 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
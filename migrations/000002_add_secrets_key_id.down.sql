@@ -0,0 +1,2 @@
+-- Удаление колонки с ID ключа шифрования
+ALTER TABLE secrets DROP COLUMN IF EXISTS key_id;
@@ -0,0 +1,3 @@
+-- ID ключа шифрования, которым зашифрован секрет.
+-- Существующие записи зашифрованы ключом ENCRYPTION_KEY (ID "default")
+ALTER TABLE secrets ADD COLUMN IF NOT EXISTS key_id VARCHAR(64) NOT NULL DEFAULT 'default';
@@ -0,0 +1,2 @@
+-- Удаление колонки с обёрнутым ключом данных
+ALTER TABLE secrets DROP COLUMN IF EXISTS wrapped_key;
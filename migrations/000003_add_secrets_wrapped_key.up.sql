@@ -0,0 +1,3 @@
+-- Ключ данных секрета, обёрнутый мастер-ключом (envelope-шифрование).
+-- NULL для секретов, зашифрованных мастер-ключом напрямую
+ALTER TABLE secrets ADD COLUMN IF NOT EXISTS wrapped_key TEXT;
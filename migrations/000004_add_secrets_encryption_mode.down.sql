@@ -0,0 +1,2 @@
+-- Удаление колонки с режимом шифрования
+ALTER TABLE secrets DROP COLUMN IF EXISTS encryption_mode;
@@ -0,0 +1,3 @@
+-- Режим шифрования секрета: server - сервер может расшифровать секрет,
+-- client - контент зашифрован клиентом, link_key - ключ хранится только во фрагменте ссылки
+ALTER TABLE secrets ADD COLUMN IF NOT EXISTS encryption_mode VARCHAR(16) NOT NULL DEFAULT 'server';
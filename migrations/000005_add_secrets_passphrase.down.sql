@@ -0,0 +1,3 @@
+-- Удаление колонок парольной фразы
+ALTER TABLE secrets DROP COLUMN IF EXISTS failed_attempts;
+ALTER TABLE secrets DROP COLUMN IF EXISTS passphrase_kdf;
@@ -0,0 +1,5 @@
+-- Параметры и соль Argon2id для секретов, защищённых парольной фразой
+ALTER TABLE secrets ADD COLUMN IF NOT EXISTS passphrase_kdf VARCHAR(255);
+
+-- Количество неверных парольных фраз
+ALTER TABLE secrets ADD COLUMN IF NOT EXISTS failed_attempts INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+-- Уничтоженный шифртекст восстановить невозможно
+SELECT 1;
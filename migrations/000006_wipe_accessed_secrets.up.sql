@@ -0,0 +1,5 @@
+-- Уничтожение шифртекста уже прочитанных секретов: после чтения в таблице
+-- остаётся только запись-надгробие с метаданными
+UPDATE secrets
+SET encrypted_content = '', iv = '', wrapped_key = NULL, passphrase_kdf = NULL
+WHERE is_accessed = TRUE;
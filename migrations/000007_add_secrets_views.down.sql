@@ -0,0 +1,3 @@
+-- Удаление колонок количества просмотров
+ALTER TABLE secrets DROP COLUMN IF EXISTS views_remaining;
+ALTER TABLE secrets DROP COLUMN IF EXISTS max_views;
@@ -0,0 +1,6 @@
+-- Количество просмотров секрета: всего и оставшихся.
+-- Существующие секреты можно открыть один раз (прочитанные уже исчерпали просмотр)
+ALTER TABLE secrets ADD COLUMN IF NOT EXISTS max_views INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE secrets ADD COLUMN IF NOT EXISTS views_remaining INTEGER NOT NULL DEFAULT 1;
+
+UPDATE secrets SET views_remaining = 0 WHERE is_accessed = TRUE;
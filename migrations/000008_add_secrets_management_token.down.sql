@@ -0,0 +1,2 @@
+-- Удаление хеша токена управления
+ALTER TABLE secrets DROP COLUMN IF EXISTS management_token_hash;
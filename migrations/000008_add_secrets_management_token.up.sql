@@ -0,0 +1,3 @@
+-- SHA-256 хеш токена управления, который получает создатель секрета.
+-- У секретов, созданных раньше, токена нет
+ALTER TABLE secrets ADD COLUMN IF NOT EXISTS management_token_hash VARCHAR(64);
@@ -0,0 +1,3 @@
+-- Удаление сведений о получателе
+ALTER TABLE secrets DROP COLUMN IF EXISTS reader_user_agent;
+ALTER TABLE secrets DROP COLUMN IF EXISTS reader_ip;
@@ -0,0 +1,4 @@
+-- Сведения о получателе для подтверждения прочтения (заполняются при первом просмотре,
+-- если это разрешено настройкой READER_INFO)
+ALTER TABLE secrets ADD COLUMN IF NOT EXISTS reader_ip VARCHAR(64);
+ALTER TABLE secrets ADD COLUMN IF NOT EXISTS reader_user_agent VARCHAR(512);
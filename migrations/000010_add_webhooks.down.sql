@@ -0,0 +1,4 @@
+-- Удаление очереди уведомлений
+DROP INDEX IF EXISTS idx_webhook_outbox_next_attempt_at;
+DROP TABLE IF EXISTS webhook_outbox;
+ALTER TABLE secrets DROP COLUMN IF EXISTS notify_webhook_url;
@@ -0,0 +1,19 @@
+-- URL для уведомлений о событиях секрета (прочтение, истечение, отзыв)
+ALTER TABLE secrets ADD COLUMN IF NOT EXISTS notify_webhook_url TEXT;
+
+-- Очередь исходящих уведомлений. Событие записывается в той же транзакции,
+-- что и изменение секрета, поэтому не теряется при перезапуске сервиса
+CREATE TABLE IF NOT EXISTS webhook_outbox (
+    id BIGSERIAL PRIMARY KEY,
+    secret_id VARCHAR(36) NOT NULL,
+    event VARCHAR(32) NOT NULL,
+    url TEXT NOT NULL,
+    occurred_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_error TEXT,
+    failed_at TIMESTAMP WITH TIME ZONE
+);
+
+-- Индекс для выборки уведомлений, готовых к отправке
+CREATE INDEX IF NOT EXISTS idx_webhook_outbox_next_attempt_at ON webhook_outbox(next_attempt_at) WHERE failed_at IS NULL;
@@ -0,0 +1,2 @@
+-- Удаление адреса для писем о прочтении
+ALTER TABLE secrets DROP COLUMN IF EXISTS notify_email;
@@ -0,0 +1,2 @@
+-- Адрес, на который отправляется письмо о прочтении секрета
+ALTER TABLE secrets ADD COLUMN IF NOT EXISTS notify_email VARCHAR(254);
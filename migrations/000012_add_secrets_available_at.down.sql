@@ -0,0 +1,2 @@
+-- Удаление времени активации секрета
+ALTER TABLE secrets DROP COLUMN IF EXISTS available_at;
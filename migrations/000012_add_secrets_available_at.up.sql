@@ -0,0 +1,2 @@
+-- Время, с которого секрет можно прочитать (NULL - сразу после создания)
+ALTER TABLE secrets ADD COLUMN IF NOT EXISTS available_at TIMESTAMP WITH TIME ZONE;
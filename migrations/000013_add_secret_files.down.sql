@@ -0,0 +1,5 @@
+-- Удаление секретов-файлов
+DROP TABLE IF EXISTS secret_file_chunks;
+ALTER TABLE secrets DROP COLUMN IF EXISTS file_size;
+ALTER TABLE secrets DROP COLUMN IF EXISTS file_mime;
+ALTER TABLE secrets DROP COLUMN IF EXISTS file_name;
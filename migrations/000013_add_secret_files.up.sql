@@ -0,0 +1,13 @@
+-- Метаданные секрета-файла (file_name IS NULL - текстовый секрет)
+ALTER TABLE secrets ADD COLUMN IF NOT EXISTS file_name VARCHAR(255);
+ALTER TABLE secrets ADD COLUMN IF NOT EXISTS file_mime VARCHAR(255);
+ALTER TABLE secrets ADD COLUMN IF NOT EXISTS file_size BIGINT NOT NULL DEFAULT 0;
+
+-- Зашифрованное содержимое файла по фрагментам. Проверка внешнего ключа отложена
+-- до конца транзакции: фрагменты записываются по мере загрузки, до строки секрета
+CREATE TABLE IF NOT EXISTS secret_file_chunks (
+    secret_id VARCHAR(36) NOT NULL REFERENCES secrets(id) ON DELETE CASCADE DEFERRABLE INITIALLY DEFERRED,
+    seq INTEGER NOT NULL,
+    data BYTEA NOT NULL,
+    PRIMARY KEY (secret_id, seq)
+);
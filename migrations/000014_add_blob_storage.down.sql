@@ -0,0 +1,9 @@
+-- Возврат фрагментов файлов в таблицу, связанную с секретами
+DELETE FROM blob_chunks WHERE blob_key NOT IN (SELECT id FROM secrets);
+ALTER TABLE blob_chunks DROP COLUMN IF EXISTS created_at;
+ALTER TABLE blob_chunks ALTER COLUMN blob_key TYPE VARCHAR(36);
+ALTER TABLE blob_chunks RENAME COLUMN blob_key TO secret_id;
+ALTER TABLE blob_chunks RENAME TO secret_file_chunks;
+ALTER TABLE secret_file_chunks ADD CONSTRAINT secret_file_chunks_secret_id_fkey
+    FOREIGN KEY (secret_id) REFERENCES secrets(id) ON DELETE CASCADE DEFERRABLE INITIALLY DEFERRED;
+ALTER TABLE secrets DROP COLUMN IF EXISTS blob_key;
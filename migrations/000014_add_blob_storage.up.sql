@@ -0,0 +1,13 @@
+-- Содержимое файлов хранится во внешнем хранилище блобов (BLOB_STORE),
+-- строка секрета хранит только ключ блоба
+ALTER TABLE secrets ADD COLUMN IF NOT EXISTS blob_key VARCHAR(255);
+UPDATE secrets SET blob_key = id WHERE file_name IS NOT NULL;
+
+-- Фрагменты файлов становятся хранилищем блобов драйвера postgres и больше
+-- не связаны с секретом внешним ключом: блоб записывается до строки секрета,
+-- а осиротевшие блобы удаляет фоновая очистка
+ALTER TABLE secret_file_chunks DROP CONSTRAINT IF EXISTS secret_file_chunks_secret_id_fkey;
+ALTER TABLE secret_file_chunks RENAME TO blob_chunks;
+ALTER TABLE blob_chunks RENAME COLUMN secret_id TO blob_key;
+ALTER TABLE blob_chunks ALTER COLUMN blob_key TYPE VARCHAR(255);
+ALTER TABLE blob_chunks ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
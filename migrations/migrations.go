@@ -0,0 +1,11 @@
+// Package migrations содержит SQL миграции схемы PostgreSQL, встроенные в бинарник.
+// Файлы именуются {version}_{name}.up.sql / {version}_{name}.down.sql
+// (формат golang-migrate) и применяются командой ares-api migrate.
+package migrations
+
+import "embed"
+
+// FS - файлы миграций
+//
+//go:embed *.sql
+var FS embed.FS